@@ -0,0 +1,148 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ablanchetMD/chirpy/internal/auth"
+	"github.com/ablanchetMD/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+type APIKey struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	// Key is only set in the response that creates the key.
+	Key string `json:"key,omitempty"`
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+func mapAPIKeyStruct(src database.ApiKey) APIKey {
+	return APIKey{
+		ID:         src.ID,
+		CreatedAt:  src.CreatedAt,
+		Name:       src.Name,
+		Prefix:     src.Prefix,
+		Scopes:     src.Scopes,
+		ExpiresAt:  nullTimePtr(src.ExpiresAt),
+		LastUsedAt: nullTimePtr(src.LastUsedAt),
+	}
+}
+
+func handleCreateAPIKey(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	principal, _ := principalFromContext(r.Context())
+
+	var requestData struct {
+		Name      string     `json:"name"`
+		Scopes    []string   `json:"scopes"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&requestData)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	defer r.Body.Close()
+
+	if requestData.Name == "" {
+		respondWithError(w, http.StatusBadRequest, "Missing name field")
+		return
+	}
+	if len(requestData.Scopes) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Missing scopes field")
+		return
+	}
+	for _, scope := range requestData.Scopes {
+		if !contains(auth.APIKeyScopes, scope) {
+			respondWithError(w, http.StatusBadRequest, "Unknown scope: "+scope)
+			return
+		}
+	}
+	expiresAt := sql.NullTime{}
+	if requestData.ExpiresAt != nil {
+		if requestData.ExpiresAt.Before(time.Now()) {
+			respondWithError(w, http.StatusBadRequest, "expires_at must be in the future")
+			return
+		}
+		expiresAt = sql.NullTime{Time: *requestData.ExpiresAt, Valid: true}
+	}
+
+	key, prefix, err := auth.GenerateAPIKey()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error generating API key")
+		return
+	}
+	apiKey, err := c.Db.CreateAPIKey(r.Context(), database.CreateAPIKeyParams{
+		CreatedAt: time.Now(),
+		UserID:    principal.UserID,
+		Name:      requestData.Name,
+		Prefix:    prefix,
+		KeyHash:   auth.HashToken(key),
+		Scopes:    requestData.Scopes,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		fmt.Println("Error creating API key: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error creating API key")
+		return
+	}
+
+	response := mapAPIKeyStruct(apiKey)
+	response.Key = key
+	respondWithJSON(w, http.StatusCreated, response)
+}
+
+func handleListAPIKeys(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	principal, _ := principalFromContext(r.Context())
+
+	keys, err := c.Db.ListAPIKeysByUser(r.Context(), principal.UserID)
+	if err != nil {
+		fmt.Println("Error listing API keys: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error listing API keys")
+		return
+	}
+	keyStructs := []APIKey{}
+	for _, key := range keys {
+		keyStructs = append(keyStructs, mapAPIKeyStruct(key))
+	}
+	respondWithJSON(w, http.StatusOK, keyStructs)
+}
+
+func handleRevokeAPIKey(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	principal, _ := principalFromContext(r.Context())
+
+	keyID, err := uuid.Parse(r.PathValue("keyID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid id field")
+		return
+	}
+	revoked, err := c.Db.RevokeAPIKey(r.Context(), database.RevokeAPIKeyParams{
+		ID:        keyID,
+		UserID:    principal.UserID,
+		RevokedAt: sql.NullTime{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		fmt.Println("Error revoking API key: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error revoking API key")
+		return
+	}
+	if revoked == 0 {
+		respondWithError(w, http.StatusNotFound, "No API key with that id")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
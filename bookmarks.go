@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ablanchetMD/chirpy/internal/database"
+)
+
+// BookmarkedChirp is a chirp in the caller's bookmarks.
+type BookmarkedChirp struct {
+	Chirp
+	BookmarkedAt time.Time `json:"bookmarked_at"`
+}
+
+func bookmarkCursor(chirp BookmarkedChirp) pageCursor {
+	return pageCursor{CreatedAt: chirp.BookmarkedAt, ID: chirp.ID}
+}
+
+// handleBookmark saves a chirp to the caller's bookmarks. Bookmarking it
+// again is not an error.
+func handleBookmark(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	principal, _ := principalFromContext(r.Context())
+
+	chirp, ok := chirpFromPath(c, w, r)
+	if !ok {
+		return
+	}
+	_, visible, err := visibleChirp(c, r, principal.UserID, chirp.ID)
+	if err != nil {
+		fmt.Println("Error getting chirp: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error getting chirp")
+		return
+	}
+	if !visible {
+		respondWithError(w, http.StatusNotFound, "No Chirp with that id")
+		return
+	}
+	err = c.Db.CreateBookmark(r.Context(), database.CreateBookmarkParams{
+		UserID:    principal.UserID,
+		ChirpID:   chirp.ID,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		fmt.Println("Error saving bookmark: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error saving bookmark")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleDeleteBookmark(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	principal, _ := principalFromContext(r.Context())
+
+	chirp, ok := chirpFromPath(c, w, r)
+	if !ok {
+		return
+	}
+	deleted, err := c.Db.DeleteBookmark(r.Context(), database.DeleteBookmarkParams{
+		UserID:  principal.UserID,
+		ChirpID: chirp.ID,
+	})
+	if err != nil {
+		fmt.Println("Error removing bookmark: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error removing bookmark")
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "That chirp is not bookmarked")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleListBookmarks returns the caller's bookmarks, most recently saved
+// first. Chirps deleted since they were saved drop out of the list, as do
+// chirps from private accounts the caller no longer follows.
+func handleListBookmarks(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	principal, _ := principalFromContext(r.Context())
+
+	cursor, limit, err := parsePagination(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid cursor or limit")
+		return
+	}
+	rows, err := c.Db.ListBookmarks(r.Context(), database.ListBookmarksParams{
+		UserID:    principal.UserID,
+		CreatedAt: cursor.CreatedAt,
+		ChirpID:   cursor.ID,
+		Limit:     int32(limit + 1),
+	})
+	if err != nil {
+		fmt.Println("Error listing bookmarks: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error listing bookmarks")
+		return
+	}
+	chirps := []BookmarkedChirp{}
+	for _, row := range rows {
+		chirps = append(chirps, BookmarkedChirp{Chirp: mapChirpStruct(row.Chirp), BookmarkedAt: row.BookmarkedAt})
+	}
+	page := makePage(chirps, limit, bookmarkCursor)
+	ptrs := make([]*Chirp, 0, len(page.Items))
+	for i := range page.Items {
+		ptrs = append(ptrs, &page.Items[i].Chirp)
+	}
+	decorateChirps(c, r, principal.UserID, ptrs)
+	respondWithJSON(w, http.StatusOK, page)
+}
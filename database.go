@@ -7,8 +7,8 @@ import (
 	 "net/http"
 	 "github.com/google/uuid"
 	 "github.com/ablanchetMD/chirpy/internal/database"
+	 "github.com/ablanchetMD/chirpy/internal/entities"
 	 "time"
-	 "database/sql"
 	// "sort"
 	// "strconv"
 	// "strings"
@@ -22,15 +22,44 @@ type Chirp struct {
 	UpdatedAt time.Time `json:"updated_at"`
 	Body      string `json:"body"`
 	UserID    uuid.UUID `json:"user_id"`
+	InReplyToID *uuid.UUID `json:"in_reply_to_id"`
+	ConversationID uuid.UUID `json:"conversation_id"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	ReactionCount int `json:"reaction_count"`
+	Reactions map[string]int `json:"reactions"`
+	ViewerReaction *string `json:"viewer_reaction"`
+	QuotedChirpID *uuid.UUID `json:"quoted_chirp_id"`
+	QuotedChirp *Chirp `json:"quoted_chirp,omitempty"`
+	QuotedChirpUnavailable bool `json:"quoted_chirp_unavailable,omitempty"`
+	Entities ChirpEntities `json:"entities"`
+}
+
+type ChirpEntities struct {
+	Hashtags []entities.Hashtag `json:"hashtags"`
+	Mentions []MentionEntity `json:"mentions"`
 }
 
 func mapChirpStruct(src database.Chirp) Chirp {
+	reactions := map[string]int{}
+	if err := json.Unmarshal(src.ReactionCounts, &reactions); err != nil && len(src.ReactionCounts) > 0 {
+		fmt.Println("Error decoding reaction counts: ", err)
+	}
 	return Chirp{
 		ID:        src.ID,
 		CreatedAt: src.CreatedAt,
 		UpdatedAt: src.UpdatedAt,
 		Body:      src.Body,
 		UserID:    src.UserID,
+		InReplyToID: nullUUIDPtr(src.InReplyToID),
+		ConversationID: src.ConversationID,
+		DeletedAt: nullTimePtr(src.DeletedAt),
+		ReactionCount: int(src.ReactionCount),
+		Reactions: reactions,
+		QuotedChirpID: nullUUIDPtr(src.QuotedChirpID),
+		Entities: ChirpEntities{
+			Hashtags: entities.Hashtags(src.Body),
+			Mentions: []MentionEntity{},
+		},
 	}
 }
 
@@ -56,15 +85,38 @@ func handleCreateChirp(c *apiConfig, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user_id, ok := requestData["user_id"]
-	if !ok {		
-		respondWithError(w, http.StatusBadRequest, "Missing user_id field")
-		return
+	principal, _ := principalFromContext(r.Context())
+	parsed_id := principal.UserID
+
+	var inReplyTo uuid.NullUUID
+	var parentAuthor uuid.UUID
+	if replyID, ok := requestData["in_reply_to_id"]; ok && replyID != "" {
+		parent, ok := replyParent(c, w, r, parsed_id, replyID)
+		if !ok {
+			return
+		}
+		inReplyTo = uuid.NullUUID{UUID: parent.ID, Valid: true}
+		parentAuthor = parent.UserID
 	}
-	parsed_id, err := uuid.Parse(user_id)
-	if err != nil {		
-		respondWithError(w, http.StatusBadRequest, "Invalid user_id field")
-		return
+	var quoted uuid.NullUUID
+	if quotedID, ok := requestData["quoted_chirp_id"]; ok && quotedID != "" {
+		original, ok := quotedChirp(c, w, r, parsed_id, quotedID)
+		if !ok {
+			return
+		}
+		quoted = uuid.NullUUID{UUID: original.ID, Valid: true}
+	}
+
+	if c.RequireEmailVerification {
+		user, err := c.Db.GetUserByID(r.Context(), parsed_id)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, "Invalid or missing credentials")
+			return
+		}
+		if !user.EmailVerifiedAt.Valid {
+			respondWithError(w, http.StatusForbidden, "Email address must be verified before posting chirps")
+			return
+		}
 	}
 
 	replacementWords := []string{"kerfuffle", "sharbert", "fornax"}
@@ -81,6 +133,9 @@ func handleCreateChirp(c *apiConfig, w http.ResponseWriter, r *http.Request) {
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 		UserID: parsed_id,
+		InReplyToID: inReplyTo,
+		QuotedChirpID: quoted,
+		SearchLanguage: c.SearchLanguage,
 	})
 	if err != nil {
 		
@@ -88,17 +143,31 @@ func handleCreateChirp(c *apiConfig, w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusInternalServerError, "Error creating chirp")
 		return
 	}
+	fanOutChirp(c, r, chirp)
+	saveHashtags(c, r, chirp)
+	saveMentions(c, r, chirp)
+	if inReplyTo.Valid {
+		notify(c, r.Context(), parentAuthor, parsed_id, notifyReply, chirp.ID)
+	}
+	enqueueWebhookEvent(c, r.Context(), webhookChirpCreated, chirp.UserID, mapChirpStruct(chirp))
 	
 	// user.Password = nil
-	respondWithJSON(w, http.StatusCreated, mapChirpStruct(chirp))
+	response := mapChirpStruct(chirp)
+	decorateChirps(c, r, parsed_id, []*Chirp{&response})
+	respondWithJSON(w, http.StatusCreated, response)
 }
 
 
 
 func handleGetChirps(c *apiConfig, w http.ResponseWriter, r *http.Request) {
-	
+	viewer, err := optionalPrincipal(c, r)
+	if err != nil {
+		fmt.Println("Error authenticating request: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error authenticating request")
+		return
+	}
 
-	chirps, err := c.Db.GetChirps(r.Context())
+	chirps, err := c.Db.GetChirps(r.Context(), viewer.UserID)
 	if err != nil {
 		fmt.Println("Error getting chirp: ", err)
 		respondWithError(w, http.StatusInternalServerError, "Error getting chirps")
@@ -108,6 +177,7 @@ func handleGetChirps(c *apiConfig, w http.ResponseWriter, r *http.Request) {
 	for _, chirp := range chirps {
 		chirpStructs = append(chirpStructs, mapChirpStruct(chirp))
 	}
+	decorateChirps(c, r, viewer.UserID, chirpRefs(chirpStructs))
 	respondWithJSON(w, http.StatusOK, chirpStructs)
 }
 
@@ -123,20 +193,29 @@ func handleGetChirp(c *apiConfig, w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusBadRequest, "Invalid id field")
 		return
 	}
-    
-	chirp, err := c.Db.GetChirp(r.Context(),parsed_id)
+
+	viewer, err := optionalPrincipal(c, r)
+	if err != nil {
+		fmt.Println("Error authenticating request: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error authenticating request")
+		return
+	}
+	// Chirps the caller may not see are reported as missing, so private
+	// accounts do not even reveal which chirps exist.
+	chirp, visible, err := visibleChirp(c, r, viewer.UserID, parsed_id)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			fmt.Println("No chirp with that id: ", err)
-			respondWithError(w, http.StatusNotFound, "No Chirp with that id")
-			return
-		} 
 		fmt.Println("Error getting chirp: ", err)
 		respondWithError(w, http.StatusInternalServerError, "Error getting chirp")
 		return
 	}
-	 
-	respondWithJSON(w, http.StatusOK, mapChirpStruct(chirp))
+	if !visible {
+		respondWithError(w, http.StatusNotFound, "No Chirp with that id")
+		return
+	}
+
+	response := mapChirpStruct(chirp)
+	decorateChirps(c, r, viewer.UserID, []*Chirp{&response})
+	respondWithJSON(w, http.StatusOK, response)
 }
 
 // func (db *DB) serverGetChirps(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ablanchetMD/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	followStatusAccepted = "accepted"
+	followStatusPending  = "pending"
+)
+
+// FollowUser is an entry in a follower, following or follow request list.
+type FollowUser struct {
+	PublicUser
+	FollowedAt time.Time `json:"followed_at"`
+}
+
+type FollowStatus struct {
+	Status string `json:"status"`
+}
+
+func mapFollowStatus(follow database.Follow) FollowStatus {
+	if follow.Status == followStatusPending {
+		return FollowStatus{Status: "requested"}
+	}
+	return FollowStatus{Status: "following"}
+}
+
+func followUserFromRow(src database.User, followedAt time.Time) FollowUser {
+	return FollowUser{PublicUser: mapPublicUserStruct(src), FollowedAt: followedAt}
+}
+
+func followUserCursor(u FollowUser) pageCursor {
+	return pageCursor{CreatedAt: u.FollowedAt, ID: u.ID}
+}
+
+// userFromHandle loads the user named by the {handle} path value, responding
+// with 404 when there is none.
+func userFromHandle(c *apiConfig, w http.ResponseWriter, r *http.Request) (database.User, bool) {
+	user, err := c.Db.GetUserByHandle(r.Context(), strings.TrimPrefix(r.PathValue("handle"), "@"))
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "No user with that handle")
+		return user, false
+	}
+	if err != nil {
+		fmt.Println("Error getting user: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error getting user")
+		return user, false
+	}
+	return user, true
+}
+
+// canSeeFollowers reports whether viewer may see who follows user and whom
+// they follow: anyone for public accounts, otherwise the owner and accepted
+// followers.
+func canSeeFollowers(c *apiConfig, r *http.Request, viewer uuid.UUID, user database.User) (bool, error) {
+	if !user.IsPrivate || viewer == user.ID {
+		return true, nil
+	}
+	if viewer == uuid.Nil {
+		return false, nil
+	}
+	follow, err := c.Db.GetFollow(r.Context(), database.GetFollowParams{FollowerID: viewer, FolloweeID: user.ID})
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return follow.Status == followStatusAccepted, nil
+}
+
+// handleFollow follows a user, or asks to when their account is private.
+// Following someone twice is not an error.
+func handleFollow(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	principal, _ := principalFromContext(r.Context())
+
+	target, ok := userFromHandle(c, w, r)
+	if !ok {
+		return
+	}
+	if target.ID == principal.UserID {
+		respondWithError(w, http.StatusBadRequest, "You cannot follow yourself")
+		return
+	}
+
+	now := time.Now()
+	params := database.CreateFollowParams{
+		FollowerID: principal.UserID,
+		FolloweeID: target.ID,
+		CreatedAt:  now,
+		Status:     followStatusAccepted,
+		AcceptedAt: sql.NullTime{Time: now, Valid: true},
+	}
+	if target.IsPrivate {
+		params.Status = followStatusPending
+		params.AcceptedAt = sql.NullTime{}
+	}
+	follow, err := c.Db.CreateFollow(r.Context(), params)
+	if err == nil {
+		kind := notifyFollow
+		if follow.Status == followStatusPending {
+			kind = notifyFollowRequest
+		}
+		notify(c, r.Context(), target.ID, principal.UserID, kind, uuid.Nil)
+	}
+	if err == sql.ErrNoRows {
+		follow, err = c.Db.GetFollow(r.Context(), database.GetFollowParams{FollowerID: principal.UserID, FolloweeID: target.ID})
+	}
+	if err != nil {
+		fmt.Println("Error following user: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error following user")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, mapFollowStatus(follow))
+}
+
+// handleUnfollow stops following a user or withdraws a pending request.
+func handleUnfollow(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	principal, _ := principalFromContext(r.Context())
+
+	target, ok := userFromHandle(c, w, r)
+	if !ok {
+		return
+	}
+	deleted, err := c.Db.DeleteFollow(r.Context(), database.DeleteFollowParams{FollowerID: principal.UserID, FolloweeID: target.ID})
+	if err != nil {
+		fmt.Println("Error unfollowing user: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error unfollowing user")
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "You are not following that user")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleListFollowers(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	listFollowGraph(c, w, r, true)
+}
+
+func handleListFollowing(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	listFollowGraph(c, w, r, false)
+}
+
+func listFollowGraph(c *apiConfig, w http.ResponseWriter, r *http.Request, followers bool) {
+	viewer, err := optionalPrincipal(c, r)
+	if err != nil {
+		fmt.Println("Error authenticating request: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error authenticating request")
+		return
+	}
+	user, ok := userFromHandle(c, w, r)
+	if !ok {
+		return
+	}
+	allowed, err := canSeeFollowers(c, r, viewer.UserID, user)
+	if err != nil {
+		fmt.Println("Error checking follow: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error listing users")
+		return
+	}
+	if !allowed {
+		respondWithError(w, http.StatusForbidden, "This account is private")
+		return
+	}
+	cursor, limit, err := parsePagination(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid cursor or limit")
+		return
+	}
+
+	users := []FollowUser{}
+	if followers {
+		rows, err := c.Db.ListFollowers(r.Context(), database.ListFollowersParams{
+			FolloweeID: user.ID,
+			Status:     followStatusAccepted,
+			CreatedAt:  cursor.CreatedAt,
+			FollowerID: cursor.ID,
+			Limit:      int32(limit + 1),
+		})
+		if err != nil {
+			fmt.Println("Error listing followers: ", err)
+			respondWithError(w, http.StatusInternalServerError, "Error listing users")
+			return
+		}
+		for _, row := range rows {
+			users = append(users, followUserFromRow(row.User, row.FollowedAt))
+		}
+	} else {
+		rows, err := c.Db.ListFollowing(r.Context(), database.ListFollowingParams{
+			FollowerID: user.ID,
+			CreatedAt:  cursor.CreatedAt,
+			FolloweeID: cursor.ID,
+			Limit:      int32(limit + 1),
+		})
+		if err != nil {
+			fmt.Println("Error listing following: ", err)
+			respondWithError(w, http.StatusInternalServerError, "Error listing users")
+			return
+		}
+		for _, row := range rows {
+			users = append(users, followUserFromRow(row.User, row.FollowedAt))
+		}
+	}
+	respondWithJSON(w, http.StatusOK, makePage(users, limit, followUserCursor))
+}
+
+// handleListFollowRequests lists the pending requests to follow the caller.
+func handleListFollowRequests(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	principal, _ := principalFromContext(r.Context())
+
+	cursor, limit, err := parsePagination(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid cursor or limit")
+		return
+	}
+	rows, err := c.Db.ListFollowers(r.Context(), database.ListFollowersParams{
+		FolloweeID: principal.UserID,
+		Status:     followStatusPending,
+		CreatedAt:  cursor.CreatedAt,
+		FollowerID: cursor.ID,
+		Limit:      int32(limit + 1),
+	})
+	if err != nil {
+		fmt.Println("Error listing follow requests: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error listing follow requests")
+		return
+	}
+	users := []FollowUser{}
+	for _, row := range rows {
+		users = append(users, followUserFromRow(row.User, row.FollowedAt))
+	}
+	respondWithJSON(w, http.StatusOK, makePage(users, limit, followUserCursor))
+}
+
+func handleApproveFollowRequest(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	principal, _ := principalFromContext(r.Context())
+
+	follower, ok := userFromHandle(c, w, r)
+	if !ok {
+		return
+	}
+	accepted, err := c.Db.AcceptFollowRequest(r.Context(), database.AcceptFollowRequestParams{
+		FollowerID: follower.ID,
+		FolloweeID: principal.UserID,
+		AcceptedAt: sql.NullTime{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		fmt.Println("Error approving follow request: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error approving follow request")
+		return
+	}
+	if accepted == 0 {
+		respondWithError(w, http.StatusNotFound, "No follow request from that user")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleDenyFollowRequest(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	principal, _ := principalFromContext(r.Context())
+
+	follower, ok := userFromHandle(c, w, r)
+	if !ok {
+		return
+	}
+	denied, err := c.Db.DenyFollowRequest(r.Context(), database.DenyFollowRequestParams{
+		FollowerID: follower.ID,
+		FolloweeID: principal.UserID,
+	})
+	if err != nil {
+		fmt.Println("Error denying follow request: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error denying follow request")
+		return
+	}
+	if denied == 0 {
+		respondWithError(w, http.StatusNotFound, "No follow request from that user")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
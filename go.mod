@@ -10,9 +10,15 @@ go 1.22.5
 
 require (
 	github.com/google/uuid v1.6.0
+	github.com/gorilla/websocket v1.5.3
 	github.com/joho/godotenv v1.5.1
 	github.com/lib/pq v1.10.9
 	golang.org/x/crypto v0.26.0
 )
 
 require github.com/golang-jwt/jwt/v5 v5.2.1
+
+require (
+	golang.org/x/net v0.28.0
+	golang.org/x/text v0.17.0
+)
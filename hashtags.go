@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/ablanchetMD/chirpy/internal/database"
+	"github.com/ablanchetMD/chirpy/internal/entities"
+)
+
+// Trending hashtags are scored over a sliding window, each use counting half
+// as much for every half-life that has passed since. Tags need several
+// different authors before they can trend, so one account cannot push its
+// own.
+const (
+	defaultTrendingInterval = 5 * time.Minute
+	defaultTrendingWindow   = 24 * time.Hour
+	defaultTrendingHalfLife = 2 * time.Hour
+	trendingMinAuthors      = 3
+	trendingSize            = 50
+	defaultTrendingLimit    = 10
+)
+
+type TrendingHashtag struct {
+	Tag        string    `json:"tag"`
+	Score      float64   `json:"score"`
+	Uses       int64     `json:"uses"`
+	Authors    int64     `json:"authors"`
+	ComputedAt time.Time `json:"computed_at"`
+}
+
+// TrendingConfig controls the trending job, from TRENDING_INTERVAL,
+// TRENDING_WINDOW and TRENDING_HALF_LIFE.
+type TrendingConfig struct {
+	Interval time.Duration
+	Window   time.Duration
+	HalfLife time.Duration
+}
+
+func newTrendingConfig() TrendingConfig {
+	config := TrendingConfig{
+		Interval: defaultTrendingInterval,
+		Window:   defaultTrendingWindow,
+		HalfLife: defaultTrendingHalfLife,
+	}
+	for name, value := range map[string]*time.Duration{
+		"TRENDING_INTERVAL":  &config.Interval,
+		"TRENDING_WINDOW":    &config.Window,
+		"TRENDING_HALF_LIFE": &config.HalfLife,
+	} {
+		if d, err := time.ParseDuration(os.Getenv(name)); err == nil && d > 0 {
+			*value = d
+		}
+	}
+	return config
+}
+
+// saveHashtags records the hashtags in a new chirp. A failure only keeps the
+// chirp off its hashtag pages, so it is logged rather than returned.
+func saveHashtags(c *apiConfig, r *http.Request, chirp database.Chirp) {
+	tags := entities.UniqueTags(entities.Hashtags(chirp.Body))
+	if len(tags) == 0 {
+		return
+	}
+	err := c.Db.AddChirpHashtags(r.Context(), database.AddChirpHashtagsParams{
+		Tags:      tags,
+		ChirpID:   chirp.ID,
+		CreatedAt: chirp.CreatedAt,
+	})
+	if err != nil {
+		fmt.Println("Error saving hashtags: ", err)
+	}
+}
+
+// refreshTrending recomputes the trending hashtags.
+func refreshTrending(c *apiConfig, ctx context.Context) error {
+	now := time.Now()
+	return c.Db.RefreshTrendingHashtags(ctx, database.RefreshTrendingHashtagsParams{
+		Now:             now,
+		HalfLifeSeconds: c.Trending.HalfLife.Seconds(),
+		WindowStart:     now.Add(-c.Trending.Window),
+		MinAuthors:      trendingMinAuthors,
+		Limit:           trendingSize,
+	})
+}
+
+// startTrendingJob refreshes the trending hashtags now and then every
+// interval until ctx is done.
+func startTrendingJob(c *apiConfig, ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(c.Trending.Interval)
+		defer ticker.Stop()
+		for {
+			if err := refreshTrending(c, ctx); err != nil {
+				fmt.Println("Error refreshing trending hashtags: ", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// hashtagBackfillBatch is how many chirps the backfill tags per query.
+const hashtagBackfillBatch = 500
+
+// backfillHashtags records the hashtags of chirps posted before hashtags were
+// saved, resuming where the last run stopped. Tagging a chirp twice is
+// harmless, so chirps posted while it runs need no special care.
+func backfillHashtags(c *apiConfig, ctx context.Context) error {
+	progress, err := c.Db.GetHashtagBackfill(ctx)
+	if err != nil {
+		return err
+	}
+	if progress.CompletedAt.Valid {
+		return nil
+	}
+	cursor := pageCursor{CreatedAt: progress.CursorCreatedAt, ID: progress.CursorID}
+	for {
+		chirps, err := c.Db.ListChirpsForHashtagBackfill(ctx, database.ListChirpsForHashtagBackfillParams{
+			CreatedAt: cursor.CreatedAt,
+			ID:        cursor.ID,
+			Limit:     hashtagBackfillBatch,
+		})
+		if err != nil {
+			return err
+		}
+		for _, chirp := range chirps {
+			tags := entities.UniqueTags(entities.Hashtags(chirp.Body))
+			if len(tags) == 0 {
+				continue
+			}
+			err := c.Db.AddChirpHashtags(ctx, database.AddChirpHashtagsParams{
+				Tags:      tags,
+				ChirpID:   chirp.ID,
+				CreatedAt: chirp.CreatedAt,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		var completedAt sql.NullTime
+		if len(chirps) < hashtagBackfillBatch {
+			completedAt = sql.NullTime{Time: time.Now(), Valid: true}
+		} else {
+			last := chirps[len(chirps)-1]
+			cursor = pageCursor{CreatedAt: last.CreatedAt, ID: last.ID}
+		}
+		err = c.Db.UpdateHashtagBackfill(ctx, database.UpdateHashtagBackfillParams{
+			CursorCreatedAt: cursor.CreatedAt,
+			CursorID:        cursor.ID,
+			CompletedAt:     completedAt,
+		})
+		if err != nil || completedAt.Valid {
+			return err
+		}
+	}
+}
+
+// startHashtagBackfill runs backfillHashtags in the background, so a large
+// backlog does not hold up startup.
+func startHashtagBackfill(c *apiConfig, ctx context.Context) {
+	go func() {
+		if err := backfillHashtags(c, ctx); err != nil {
+			fmt.Println("Error backfilling hashtags: ", err)
+		}
+	}()
+}
+
+func chirpCursor(chirp Chirp) pageCursor {
+	return pageCursor{CreatedAt: chirp.CreatedAt, ID: chirp.ID}
+}
+
+// handleListHashtagChirps returns the chirps using a hashtag, newest first.
+func handleListHashtagChirps(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	viewer, err := optionalPrincipal(c, r)
+	if err != nil {
+		fmt.Println("Error authenticating request: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error authenticating request")
+		return
+	}
+	tag := entities.NormalizeTag(r.PathValue("tag"))
+	if tag == "" {
+		respondWithError(w, http.StatusBadRequest, "Hashtag not provided")
+		return
+	}
+	cursor, limit, err := parsePagination(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid cursor or limit")
+		return
+	}
+
+	rows, err := c.Db.ListHashtagChirps(r.Context(), database.ListHashtagChirpsParams{
+		Tag:       tag,
+		ViewerID:  viewer.UserID,
+		CreatedAt: cursor.CreatedAt,
+		ChirpID:   cursor.ID,
+		Limit:     int32(limit + 1),
+	})
+	if err != nil {
+		fmt.Println("Error listing hashtag chirps: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error listing chirps")
+		return
+	}
+	chirps := []Chirp{}
+	for _, row := range rows {
+		chirps = append(chirps, mapChirpStruct(row.Chirp))
+	}
+	page := makePage(chirps, limit, chirpCursor)
+	decorateChirps(c, r, viewer.UserID, chirpRefs(page.Items))
+	respondWithJSON(w, http.StatusOK, page)
+}
+
+// handleTrendingHashtags returns the hashtags trending as of the last run of
+// the trending job. ?limit= defaults to 10.
+func handleTrendingHashtags(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	limit := defaultTrendingLimit
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			respondWithError(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		limit = min(n, trendingSize)
+	}
+	rows, err := c.Db.ListTrendingHashtags(r.Context(), int32(limit))
+	if err != nil {
+		fmt.Println("Error listing trending hashtags: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error listing trending hashtags")
+		return
+	}
+	trending := []TrendingHashtag{}
+	for _, row := range rows {
+		trending = append(trending, TrendingHashtag{
+			Tag:        row.Tag,
+			Score:      row.Score,
+			Uses:       row.Uses,
+			Authors:    row.Authors,
+			ComputedAt: row.ComputedAt,
+		})
+	}
+	respondWithJSON(w, http.StatusOK, trending)
+}
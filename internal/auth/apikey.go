@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	ScopeChirpsRead  = "chirps:read"
+	ScopeChirpsWrite = "chirps:write"
+	// ScopeAccount covers managing the account itself (credentials, keys,
+	// two-factor settings). It is never granted to API keys.
+	ScopeAccount = "account"
+
+	apiKeyPrefix = "chirpy_"
+)
+
+// APIKeyScopes lists the scopes a user may grant to an API key.
+var APIKeyScopes = []string{ScopeChirpsRead, ScopeChirpsWrite}
+
+// GenerateAPIKey returns a new key and its public prefix. Only the prefix and
+// HashToken(key) should be stored; the key itself is shown to the user once.
+func GenerateAPIKey() (string, string, error) {
+	prefix := make([]byte, 4)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(prefix); err != nil {
+		return "", "", fmt.Errorf("GenerateAPIKey Function: %w", err)
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", fmt.Errorf("GenerateAPIKey Function: %w", err)
+	}
+	publicPrefix := apiKeyPrefix + hex.EncodeToString(prefix)
+	return publicPrefix + "_" + base64.RawURLEncoding.EncodeToString(secret), publicPrefix, nil
+}
+
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, apiKeyPrefix)
+}
+
+// GetAPIKey extracts a key sent as "Authorization: ApiKey <key>".
+func GetAPIKey(headers http.Header) (string, error) {
+	authHeader := headers.Get("Authorization")
+	if authHeader == "" {
+		return "", fmt.Errorf("no Authorization header provided")
+	}
+	const apiKeyScheme = "ApiKey "
+	if !strings.HasPrefix(authHeader, apiKeyScheme) {
+		return "", fmt.Errorf("invalid Authorization header format")
+	}
+	return strings.TrimPrefix(authHeader, apiKeyScheme), nil
+}
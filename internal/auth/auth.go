@@ -1,12 +1,13 @@
 package auth
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"net/http"
 	"time"
 	"strings"
 
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -24,15 +25,22 @@ func CheckPasswordHash(password, hash string) error {
 	return err
 }
 
-func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error){
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
-		Issuer: "chirpy",
-		IssuedAt: jwt.NewNumericDate(time.Now()),
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
-		Subject: userID.String(),
-	})
-	tokenString, err := token.SignedString([]byte(tokenSecret))
+const hmacKeyID = "hs256"
+
+// MinSecretLength is the shortest JWT secret accepted, in bytes. HMAC with
+// an empty or short key lets anyone forge tokens.
+const MinSecretLength = 32
+
+// CheckSecret reports whether secret is long enough to sign tokens with.
+func CheckSecret(secret string) error {
+	if len(secret) < MinSecretLength {
+		return fmt.Errorf("JWT secret must be at least %d bytes, got %d", MinSecretLength, len(secret))
+	}
+	return nil
+}
 
+func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error){
+	tokenString, err := NewKeyRing(NewHMACKey(hmacKeyID, []byte(tokenSecret))).MakeAccessToken(userID, 0, "", expiresIn)
 	if err != nil {
 		return "", fmt.Errorf("MakeJWT Function: %w",err)
 	}
@@ -42,25 +50,23 @@ func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (str
 }
 
 func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	token,err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(tokenSecret), nil
-	})
+	claims, err := NewKeyRing(NewHMACKey(hmacKeyID, []byte(tokenSecret))).ValidateAccessToken(tokenString)
 	if err != nil {
 		return uuid.Nil, fmt.Errorf("ValidateJWT Function: %w",err)
 	}
 
-	claims, ok := token.Claims.(*jwt.RegisteredClaims)
-	if !ok || !token.Valid {
-		return uuid.Nil, fmt.Errorf("ValidateJWT Function: %w",err)
-	}
-
-	userID, err := uuid.Parse(claims.Subject)
-	if err != nil {
-		return uuid.Nil, fmt.Errorf("ValidateJWT Function: %w",err)
-	}
+	return claims.UserID(), nil
 
-	return userID, nil
+}
 
+// NewHMACKeyRing returns the ring used when no asymmetric keys are configured,
+// signing with the shared JWT secret exactly like MakeJWT. Secrets shorter
+// than MinSecretLength are refused.
+func NewHMACKeyRing(tokenSecret string) (*KeyRing, error) {
+	if err := CheckSecret(tokenSecret); err != nil {
+		return nil, fmt.Errorf("NewHMACKeyRing Function: %w", err)
+	}
+	return NewKeyRing(NewHMACKey(hmacKeyID, []byte(tokenSecret))), nil
 }
 
 func GetBearerToken(headers http.Header) (string, error) {
@@ -74,3 +80,14 @@ func GetBearerToken(headers http.Header) (string, error) {
 	}
 	return strings.TrimPrefix(authHeader, bearerPrefix), nil
 }
+
+// MakeRandomToken returns 256 random bits, hex encoded, for opaque tokens such
+// as session cookies.
+func MakeRandomToken() (string, error) {
+	token := make([]byte, 32)
+	_, err := rand.Read(token)
+	if err != nil {
+		return "", fmt.Errorf("MakeRandomToken Function: %w", err)
+	}
+	return hex.EncodeToString(token), nil
+}
@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"golang.org/x/net/idna"
+)
+
+const emailVerificationAudience = "chirpy-email-verification"
+
+var ErrInvalidEmail = errors.New("invalid email address")
+
+type emailVerificationClaims struct {
+	Email string `json:"email"`
+	jwt.RegisteredClaims
+}
+
+// NormalizeEmail validates the syntax of an email address and returns it in
+// its canonical form: trimmed, lower-cased and with the domain converted to
+// its ASCII (punycode) representation.
+func NormalizeEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+	if email == "" || len(email) > 254 {
+		return "", ErrInvalidEmail
+	}
+
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Name != "" || addr.Address != email {
+		return "", ErrInvalidEmail
+	}
+
+	at := strings.LastIndex(email, "@")
+	local, domain := email[:at], email[at+1:]
+	if local == "" || len(local) > 64 {
+		return "", ErrInvalidEmail
+	}
+
+	asciiDomain, err := idna.Lookup.ToASCII(strings.ToLower(domain))
+	if err != nil || !strings.Contains(asciiDomain, ".") {
+		return "", ErrInvalidEmail
+	}
+
+	normalized := strings.ToLower(local) + "@" + asciiDomain
+	if len(normalized) > 254 {
+		return "", ErrInvalidEmail
+	}
+	return normalized, nil
+}
+
+func MakeEmailVerificationToken(userID uuid.UUID, email, tokenSecret string, expiresIn time.Duration) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, emailVerificationClaims{
+		Email: email,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "chirpy",
+			Audience:  jwt.ClaimStrings{emailVerificationAudience},
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
+			Subject:   userID.String(),
+		},
+	})
+	tokenString, err := token.SignedString([]byte(tokenSecret))
+	if err != nil {
+		return "", fmt.Errorf("MakeEmailVerificationToken Function: %w", err)
+	}
+	return tokenString, nil
+}
+
+// ValidateEmailVerificationToken returns the user and the address the token
+// was issued for. Callers must check the address still matches the account so
+// a link sent before an email change cannot verify the new address.
+func ValidateEmailVerificationToken(tokenString, tokenSecret string) (uuid.UUID, string, error) {
+	claims := &emailVerificationClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(tokenSecret), nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer("chirpy"),
+		jwt.WithAudience(emailVerificationAudience),
+	)
+	if err != nil {
+		return uuid.Nil, "", fmt.Errorf("ValidateEmailVerificationToken Function: %w", err)
+	}
+
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return uuid.Nil, "", fmt.Errorf("ValidateEmailVerificationToken Function: %w", err)
+	}
+	return userID, claims.Email, nil
+}
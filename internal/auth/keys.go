@@ -0,0 +1,341 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	tokenIssuer         = "chirpy"
+	accessTokenAudience = "chirpy"
+)
+
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// SigningKey is one entry of a KeyRing. Asymmetric keys publish their public
+// half through the JWKS endpoint; HMAC keys never leave the process.
+type SigningKey struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Private interface{}
+	Public  interface{}
+	// NotAfter is when a retired key stops being accepted for verification.
+	// The zero value means the key has not been retired.
+	NotAfter time.Time
+}
+
+func NewHMACKey(id string, secret []byte) *SigningKey {
+	return &SigningKey{ID: id, Method: jwt.SigningMethodHS256, Private: secret, Public: secret}
+}
+
+func GenerateSigningKey(alg string) (*SigningKey, error) {
+	switch alg {
+	case jwt.SigningMethodRS256.Alg():
+		private, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, fmt.Errorf("GenerateSigningKey Function: %w", err)
+		}
+		return newAsymmetricKey(private)
+	case jwt.SigningMethodEdDSA.Alg():
+		_, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("GenerateSigningKey Function: %w", err)
+		}
+		return newAsymmetricKey(private)
+	}
+	return nil, fmt.Errorf("GenerateSigningKey Function: unsupported algorithm %q", alg)
+}
+
+// ParseSigningKeyPEM loads an RSA (PKCS#1 or PKCS#8) or Ed25519 (PKCS#8)
+// private key. The key id is the RFC 7638 thumbprint of its public key, so the
+// same file always yields the same kid on every instance.
+func ParseSigningKeyPEM(data []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("ParseSigningKeyPEM Function: no PEM block found")
+	}
+	if private, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return newAsymmetricKey(private)
+	}
+	private, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("ParseSigningKeyPEM Function: %w", err)
+	}
+	return newAsymmetricKey(private)
+}
+
+func newAsymmetricKey(private interface{}) (*SigningKey, error) {
+	key := &SigningKey{Private: private}
+	switch k := private.(type) {
+	case *rsa.PrivateKey:
+		key.Method = jwt.SigningMethodRS256
+		key.Public = &k.PublicKey
+	case ed25519.PrivateKey:
+		key.Method = jwt.SigningMethodEdDSA
+		key.Public = k.Public()
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", private)
+	}
+	jwk, err := publicJWK(key)
+	if err != nil {
+		return nil, err
+	}
+	key.ID = jwk.thumbprint()
+	return key, nil
+}
+
+// KeyRing signs tokens with its active key and verifies them with any key it
+// still holds, which lets keys be rotated without invalidating tokens that
+// are already in flight.
+type KeyRing struct {
+	mux    sync.RWMutex
+	active *SigningKey
+	keys   map[string]*SigningKey
+	now    func() time.Time
+}
+
+func NewKeyRing(active *SigningKey) *KeyRing {
+	return &KeyRing{
+		active: active,
+		keys:   map[string]*SigningKey{active.ID: active},
+		now:    time.Now,
+	}
+}
+
+// AddVerificationKey makes ring accept tokens signed by key until notAfter
+// without ever signing with it.
+func (k *KeyRing) AddVerificationKey(key *SigningKey, notAfter time.Time) {
+	k.mux.Lock()
+	defer k.mux.Unlock()
+	key.NotAfter = notAfter
+	k.keys[key.ID] = key
+}
+
+// Rotate makes next the active signing key. The previous one keeps verifying
+// tokens for overlap, which should be at least the lifetime of the tokens it
+// signed. Keys whose overlap window has ended are dropped.
+func (k *KeyRing) Rotate(next *SigningKey, overlap time.Duration) {
+	k.mux.Lock()
+	defer k.mux.Unlock()
+	now := k.now()
+	k.active.NotAfter = now.Add(overlap)
+	for id, key := range k.keys {
+		if !key.NotAfter.IsZero() && now.After(key.NotAfter) {
+			delete(k.keys, id)
+		}
+	}
+	next.NotAfter = time.Time{}
+	k.active = next
+	k.keys[next.ID] = next
+}
+
+func (k *KeyRing) ActiveKeyID() string {
+	k.mux.RLock()
+	defer k.mux.RUnlock()
+	return k.active.ID
+}
+
+func (k *KeyRing) Sign(claims jwt.Claims) (string, error) {
+	k.mux.RLock()
+	active := k.active
+	k.mux.RUnlock()
+
+	token := jwt.NewWithClaims(active.Method, claims)
+	token.Header["kid"] = active.ID
+	tokenString, err := token.SignedString(active.Private)
+	if err != nil {
+		return "", fmt.Errorf("Sign Function: %w", err)
+	}
+	return tokenString, nil
+}
+
+// Parse verifies tokenString into claims. The kid header selects the key, the
+// alg header must match that key's algorithm exactly, and the issuer and
+// audience are always checked.
+func (k *KeyRing) Parse(tokenString string, claims jwt.Claims, audience string) error {
+	k.mux.RLock()
+	methods := map[string]bool{}
+	for _, key := range k.keys {
+		methods[key.Method.Alg()] = true
+	}
+	k.mux.RUnlock()
+	validMethods := make([]string, 0, len(methods))
+	for alg := range methods {
+		validMethods = append(validMethods, alg)
+	}
+
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		k.mux.RLock()
+		key, ok := k.keys[kid]
+		k.mux.RUnlock()
+		if !ok {
+			return nil, ErrUnknownKey
+		}
+		if !key.NotAfter.IsZero() && k.now().After(key.NotAfter) {
+			return nil, ErrUnknownKey
+		}
+		if token.Method.Alg() != key.Method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %s for key %s", token.Method.Alg(), kid)
+		}
+		return key.Public, nil
+	},
+		jwt.WithValidMethods(validMethods),
+		jwt.WithIssuer(tokenIssuer),
+		jwt.WithAudience(audience),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return fmt.Errorf("Parse Function: %w", err)
+	}
+	return nil
+}
+
+// AccessClaims are the claims carried by access tokens.
+type AccessClaims struct {
+	// TokenVersion must match the user's current token version; bumping it
+	// invalidates every access token issued before.
+	TokenVersion int32 `json:"ver"`
+	// SessionID is the refresh token the access token was issued with, if
+	// any.
+	SessionID string `json:"sid,omitempty"`
+	// ClientID and Scope are only set on tokens issued to OAuth clients, in
+	// which case SessionID is the OAuth grant.
+	ClientID string `json:"client_id,omitempty"`
+	Scope    string `json:"scope,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// UserID returns the subject. ValidateAccessToken guarantees it parses.
+func (c *AccessClaims) UserID() uuid.UUID {
+	userID, _ := uuid.Parse(c.Subject)
+	return userID
+}
+
+func (k *KeyRing) MakeAccessToken(userID uuid.UUID, tokenVersion int32, sessionID string, expiresIn time.Duration) (string, error) {
+	return k.Sign(AccessClaims{
+		TokenVersion: tokenVersion,
+		SessionID:    sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    tokenIssuer,
+			Audience:  jwt.ClaimStrings{accessTokenAudience},
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
+			Subject:   userID.String(),
+		},
+	})
+}
+
+func (k *KeyRing) ValidateAccessToken(tokenString string) (*AccessClaims, error) {
+	claims := &AccessClaims{}
+	err := k.Parse(tokenString, claims, accessTokenAudience)
+	if err != nil {
+		return nil, fmt.Errorf("ValidateAccessToken Function: %w", err)
+	}
+	_, err = uuid.Parse(claims.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("ValidateAccessToken Function: %w", err)
+	}
+	return claims, nil
+}
+
+// JWK is the public half of a signing key as described by RFC 7517.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+func publicJWK(key *SigningKey) (JWK, error) {
+	switch pub := key.Public.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			Kty: "RSA",
+			N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}, nil
+	case ed25519.PublicKey:
+		return JWK{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(pub),
+		}, nil
+	}
+	return JWK{}, fmt.Errorf("no public JWK for %T", key.Public)
+}
+
+// thumbprint computes the RFC 7638 thumbprint: the hash of the required
+// members serialized in lexicographic order.
+func (j JWK) thumbprint() string {
+	var members interface{}
+	if j.Kty == "RSA" {
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{j.E, j.Kty, j.N}
+	} else {
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{j.Crv, j.Kty, j.X}
+	}
+	data, _ := json.Marshal(members)
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// JWKS returns every public key still accepted for verification. HMAC keys
+// are never published.
+func (k *KeyRing) JWKS() JWKSet {
+	k.mux.RLock()
+	defer k.mux.RUnlock()
+	now := k.now()
+	set := JWKSet{Keys: []JWK{}}
+	for _, key := range k.keys {
+		if _, ok := key.Private.(crypto.Signer); !ok {
+			continue
+		}
+		if !key.NotAfter.IsZero() && now.After(key.NotAfter) {
+			continue
+		}
+		jwk, err := publicJWK(key)
+		if err != nil {
+			continue
+		}
+		jwk.Kid = key.ID
+		jwk.Use = "sig"
+		jwk.Alg = key.Method.Alg()
+		set.Keys = append(set.Keys, jwk)
+	}
+	sort.Slice(set.Keys, func(i, j int) bool {
+		return set.Keys[i].Kid < set.Keys[j].Kid
+	})
+	return set
+}
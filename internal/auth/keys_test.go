@@ -0,0 +1,201 @@
+package auth
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+func testClaims(expiresIn time.Duration) AccessClaims {
+	now := time.Now()
+	return AccessClaims{RegisteredClaims: jwt.RegisteredClaims{
+		Issuer:    tokenIssuer,
+		Audience:  jwt.ClaimStrings{accessTokenAudience},
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(expiresIn)),
+		Subject:   uuid.New().String(),
+	}}
+}
+
+// signWith signs claims with method and secret, claiming to use kid.
+func signWith(t *testing.T, method jwt.SigningMethod, secret interface{}, kid string) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, testClaims(time.Hour))
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	s, err := token.SignedString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func generateKey(t *testing.T, alg string) *SigningKey {
+	t.Helper()
+	key, err := GenerateSigningKey(alg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestNewHMACKeyRingRejectsShortSecrets(t *testing.T) {
+	tests := []struct {
+		secret string
+		ok     bool
+	}{
+		{"", false},
+		{"secret", false},
+		{strings.Repeat("x", MinSecretLength-1), false},
+		{strings.Repeat("x", MinSecretLength), true},
+	}
+	for _, tt := range tests {
+		ring, err := NewHMACKeyRing(tt.secret)
+		if (err == nil) != tt.ok {
+			t.Errorf("NewHMACKeyRing(%d bytes): err = %v", len(tt.secret), err)
+		}
+		if err == nil && len(ring.JWKS().Keys) != 0 {
+			t.Errorf("HMAC key published in JWKS")
+		}
+	}
+}
+
+func TestKeyRingSelectsKeyByKid(t *testing.T) {
+	rsaKey := generateKey(t, "RS256")
+	edKey := generateKey(t, "EdDSA")
+	ring := NewKeyRing(rsaKey)
+	ring.AddVerificationKey(edKey, time.Now().Add(time.Hour))
+
+	tests := []struct {
+		name  string
+		token string
+		ok    bool
+	}{
+		{"active key", signWith(t, rsaKey.Method, rsaKey.Private, rsaKey.ID), true},
+		{"verification key", signWith(t, edKey.Method, edKey.Private, edKey.ID), true},
+		{"unknown kid", signWith(t, edKey.Method, edKey.Private, "other"), false},
+		{"missing kid", signWith(t, rsaKey.Method, rsaKey.Private, ""), false},
+		{"kid of another key", signWith(t, edKey.Method, edKey.Private, rsaKey.ID), false},
+	}
+	for _, tt := range tests {
+		_, err := ring.ValidateAccessToken(tt.token)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: err = %v", tt.name, err)
+		}
+	}
+
+	token, err := ring.MakeAccessToken(uuid.New(), 0, "", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &AccessClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Header["kid"] != rsaKey.ID || parsed.Method.Alg() != "RS256" {
+		t.Errorf("signed with kid %v alg %s, want the active key", parsed.Header["kid"], parsed.Method.Alg())
+	}
+}
+
+func TestKeyRingRejectsOtherAlgorithms(t *testing.T) {
+	rsaKey := generateKey(t, "RS256")
+	ring := NewKeyRing(rsaKey)
+	der, err := x509.MarshalPKIXPublicKey(rsaKey.Public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		// The public key is no secret, so HS256 with it must not verify.
+		{"HS256 with the public key", signWith(t, jwt.SigningMethodHS256, publicPEM, rsaKey.ID)},
+		{"HS256 with the DER public key", signWith(t, jwt.SigningMethodHS256, der, rsaKey.ID)},
+		{"none", signWith(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, rsaKey.ID)},
+		{"RS512 with the same key", signWith(t, jwt.SigningMethodRS512, rsaKey.Private, rsaKey.ID)},
+	}
+	for _, tt := range tests {
+		if _, err := ring.ValidateAccessToken(tt.token); err == nil {
+			t.Errorf("%s: token accepted", tt.name)
+		}
+	}
+
+	// While moving from HS256 to RS256 both are valid methods, so each kid
+	// must only accept its own algorithm.
+	secret := []byte(strings.Repeat("x", MinSecretLength))
+	ring.AddVerificationKey(NewHMACKey(hmacKeyID, secret), time.Now().Add(time.Hour))
+	mixed := []struct {
+		name  string
+		token string
+		ok    bool
+	}{
+		{"HMAC key", signWith(t, jwt.SigningMethodHS256, secret, hmacKeyID), true},
+		{"RSA key", signWith(t, rsaKey.Method, rsaKey.Private, rsaKey.ID), true},
+		{"HS256 with the public key", signWith(t, jwt.SigningMethodHS256, publicPEM, rsaKey.ID), false},
+		{"HS256 with the secret under the RSA kid", signWith(t, jwt.SigningMethodHS256, secret, rsaKey.ID), false},
+		{"RS256 under the HMAC kid", signWith(t, rsaKey.Method, rsaKey.Private, hmacKeyID), false},
+	}
+	for _, tt := range mixed {
+		_, err := ring.ValidateAccessToken(tt.token)
+		if (err == nil) != tt.ok {
+			t.Errorf("mixed ring, %s: err = %v", tt.name, err)
+		}
+	}
+}
+
+func TestKeyRingRotationOverlap(t *testing.T) {
+	now := time.Now()
+	old := generateKey(t, "EdDSA")
+	ring := NewKeyRing(old)
+	ring.now = func() time.Time { return now }
+
+	oldToken, err := ring.MakeAccessToken(uuid.New(), 0, "", 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := generateKey(t, "EdDSA")
+	ring.Rotate(next, time.Hour)
+	newToken, err := ring.MakeAccessToken(uuid.New(), 0, "", 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ring.ActiveKeyID() != next.ID {
+		t.Fatalf("active key = %s, want %s", ring.ActiveKeyID(), next.ID)
+	}
+
+	tests := []struct {
+		name   string
+		after  time.Duration
+		token  string
+		ok     bool
+		inJWKS int
+	}{
+		{"old token inside the overlap", 30 * time.Minute, oldToken, true, 2},
+		{"old token after the overlap", 2 * time.Hour, oldToken, false, 1},
+		{"new token after the overlap", 2 * time.Hour, newToken, true, 1},
+	}
+	for _, tt := range tests {
+		now = time.Now().Add(tt.after)
+		_, err := ring.ValidateAccessToken(tt.token)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: err = %v", tt.name, err)
+		}
+		if n := len(ring.JWKS().Keys); n != tt.inJWKS {
+			t.Errorf("%s: %d keys published, want %d", tt.name, n, tt.inJWKS)
+		}
+	}
+
+	// Rotating again drops keys whose overlap has ended.
+	ring.Rotate(generateKey(t, "EdDSA"), time.Hour)
+	if _, ok := ring.keys[old.ID]; ok {
+		t.Errorf("expired key kept after rotation")
+	}
+}
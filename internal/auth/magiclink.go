@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const magicLinkAudience = "chirpy-magic-link"
+
+// MakeMagicLinkToken signs a passwordless login link. The token id is the
+// magic_links row, which is what makes the link single-use.
+func MakeMagicLinkToken(linkID, userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		ID:        linkID.String(),
+		Issuer:    tokenIssuer,
+		Audience:  jwt.ClaimStrings{magicLinkAudience},
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
+		Subject:   userID.String(),
+	})
+	tokenString, err := token.SignedString([]byte(tokenSecret))
+	if err != nil {
+		return "", fmt.Errorf("MakeMagicLinkToken Function: %w", err)
+	}
+	return tokenString, nil
+}
+
+// ValidateMagicLinkToken returns the link and user ids of a magic link token.
+func ValidateMagicLinkToken(tokenString, tokenSecret string) (uuid.UUID, uuid.UUID, error) {
+	claims := &jwt.RegisteredClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(tokenSecret), nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(tokenIssuer),
+		jwt.WithAudience(magicLinkAudience),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, fmt.Errorf("ValidateMagicLinkToken Function: %w", err)
+	}
+
+	linkID, err := uuid.Parse(claims.ID)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, fmt.Errorf("ValidateMagicLinkToken Function: %w", err)
+	}
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, fmt.Errorf("ValidateMagicLinkToken Function: %w", err)
+	}
+	return linkID, userID, nil
+}
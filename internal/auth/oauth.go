@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// OAuthScopes lists the scopes a third-party client may request. Like API
+// keys, clients never get ScopeAccount.
+var OAuthScopes = []string{ScopeChirpsRead, ScopeChirpsWrite}
+
+// MakeOAuthAccessToken issues an access token to an OAuth client. It is the
+// same kind of token as a login access token, narrowed to scopes and tied to
+// the grant so revoking the grant revokes the token.
+func (k *KeyRing) MakeOAuthAccessToken(userID uuid.UUID, tokenVersion int32, grantID, clientID uuid.UUID, scopes []string, expiresIn time.Duration) (string, error) {
+	return k.Sign(AccessClaims{
+		TokenVersion: tokenVersion,
+		SessionID:    grantID.String(),
+		ClientID:     clientID.String(),
+		Scope:        strings.Join(scopes, " "),
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    tokenIssuer,
+			Audience:  jwt.ClaimStrings{accessTokenAudience},
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
+			Subject:   userID.String(),
+		},
+	})
+}
+
+// Scopes splits the space-delimited scope claim.
+func (c *AccessClaims) Scopes() []string {
+	return strings.Fields(c.Scope)
+}
+
+// ParseScope splits a space-delimited OAuth scope parameter, rejecting
+// anything outside allowed. Duplicates are dropped.
+func ParseScope(scope string, allowed []string) ([]string, error) {
+	scopes := []string{}
+	for _, s := range strings.Fields(scope) {
+		if !containsString(allowed, s) {
+			return nil, fmt.Errorf("ParseScope Function: unknown scope %q", s)
+		}
+		if !containsString(scopes, s) {
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes, nil
+}
+
+// ValidPKCEVerifier reports whether verifier is well formed per RFC 7636:
+// 43 to 128 unreserved characters.
+func ValidPKCEVerifier(verifier string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	for _, r := range verifier {
+		if !(r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || strings.ContainsRune("-._~", r)) {
+			return false
+		}
+	}
+	return true
+}
+
+// VerifyPKCE checks verifier against an S256 code challenge. The plain method
+// is not supported.
+func VerifyPKCE(verifier, challenge string) bool {
+	if !ValidPKCEVerifier(verifier) {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
+
+func containsString(slice []string, s string) bool {
+	for _, item := range slice {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+// The example from RFC 7636, appendix B.
+const (
+	rfcVerifier  = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	rfcChallenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+)
+
+// challengeFor is the S256 challenge for verifier, whether or not the
+// verifier is valid.
+func challengeFor(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func TestVerifyPKCE(t *testing.T) {
+	tests := []struct {
+		name      string
+		verifier  string
+		challenge string
+		want      bool
+	}{
+		{"S256", rfcVerifier, rfcChallenge, true},
+		{"wrong verifier", strings.Repeat("a", 43), rfcChallenge, false},
+		{"plain method", rfcVerifier, rfcVerifier, false},
+		{"padded challenge", rfcVerifier, rfcChallenge + "=", false},
+		{"standard base64 challenge", rfcVerifier, strings.NewReplacer("-", "+", "_", "/").Replace(rfcChallenge), false},
+		{"empty verifier", "", rfcChallenge, false},
+		{"empty challenge", rfcVerifier, "", false},
+		{"too short", rfcVerifier[:42], challengeFor(rfcVerifier[:42]), false},
+		{"too long", strings.Repeat("a", 129), challengeFor(strings.Repeat("a", 129)), false},
+		{"longest allowed", strings.Repeat("a", 128), challengeFor(strings.Repeat("a", 128)), true},
+		{"reserved character", rfcVerifier[:42] + "+", challengeFor(rfcVerifier[:42] + "+"), false},
+	}
+	for _, tt := range tests {
+		if got := VerifyPKCE(tt.verifier, tt.challenge); got != tt.want {
+			t.Errorf("%s: VerifyPKCE = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package auth
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// bcryptMaxBytes is the most bcrypt looks at; anything beyond is silently
+// ignored, so longer passwords are rejected instead.
+const bcryptMaxBytes = 72
+
+// PasswordViolation describes one way a password fails the policy.
+type PasswordViolation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// PasswordPolicy describes what passwords are accepted. Strength is scored
+// from 0 (trivial) to 4 (strong) by PasswordStrength.
+type PasswordPolicy struct {
+	MinLength   int
+	MaxBytes    int
+	MinStrength int
+	// Breaches, when set, rejects passwords found in a breach corpus.
+	Breaches BreachCorpus
+}
+
+var DefaultPasswordPolicy = PasswordPolicy{
+	MinLength:   8,
+	MaxBytes:    bcryptMaxBytes,
+	MinStrength: 2,
+}
+
+// Check returns every way password violates the policy. userInputs are
+// values such as the email address that make a password easier to guess
+// when it contains them. Breach lookups fail open: the error is returned
+// alongside the other violations.
+func (p PasswordPolicy) Check(ctx context.Context, password string, userInputs ...string) ([]PasswordViolation, error) {
+	violations := []PasswordViolation{}
+	maxBytes := p.MaxBytes
+	if maxBytes <= 0 || maxBytes > bcryptMaxBytes {
+		maxBytes = bcryptMaxBytes
+	}
+
+	if utf8.RuneCountInString(password) < p.MinLength {
+		violations = append(violations, PasswordViolation{"too_short", fmt.Sprintf("Password must be at least %d characters long", p.MinLength)})
+	}
+	if len(password) > maxBytes {
+		violations = append(violations, PasswordViolation{"too_long", fmt.Sprintf("Password must be at most %d bytes long", maxBytes)})
+	}
+	if len(violations) > 0 {
+		return violations, nil
+	}
+	if PasswordStrength(password, userInputs...) < p.MinStrength {
+		violations = append(violations, PasswordViolation{"too_weak", "Password is too easy to guess; try a longer passphrase"})
+	}
+
+	if p.Breaches == nil {
+		return violations, nil
+	}
+	count, err := PwnedCount(ctx, p.Breaches, password)
+	if err != nil {
+		return violations, err
+	}
+	if count > 0 {
+		violations = append(violations, PasswordViolation{"breached", "Password has appeared in a data breach; choose a different one"})
+	}
+	return violations, nil
+}
+
+// PasswordStrength estimates the entropy of password and maps it to a score
+// from 0 to 4. The estimate is based on the character classes used, with
+// repeated characters, runs such as "abc" or "321", and any of userInputs
+// discounted.
+func PasswordStrength(password string, userInputs ...string) int {
+	lowered := strings.ToLower(password)
+	for _, input := range userInputs {
+		for _, part := range strings.FieldsFunc(strings.ToLower(input), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			if len(part) >= 3 {
+				lowered = strings.ReplaceAll(lowered, part, "")
+			}
+		}
+	}
+
+	pool := 0
+	var hasLower, hasUpper, hasDigit, hasSymbol, hasOther bool
+	for _, r := range password {
+		switch {
+		case r >= 'a' && r <= 'z':
+			hasLower = true
+		case r >= 'A' && r <= 'Z':
+			hasUpper = true
+		case r >= '0' && r <= '9':
+			hasDigit = true
+		case r < utf8.RuneSelf:
+			hasSymbol = true
+		default:
+			hasOther = true
+		}
+	}
+	for _, class := range []struct {
+		used bool
+		size int
+	}{{hasLower, 26}, {hasUpper, 26}, {hasDigit, 10}, {hasSymbol, 33}, {hasOther, 100}} {
+		if class.used {
+			pool += class.size
+		}
+	}
+	if pool == 0 {
+		return 0
+	}
+
+	// Characters that repeat or continue a run from the previous one add
+	// little; count them as a quarter.
+	effective := 0.0
+	var prev rune = -1
+	var prevStep rune
+	for _, r := range lowered {
+		step := r - prev
+		switch {
+		case r == prev:
+			effective += 0.25
+		case prev >= 0 && (step == 1 || step == -1) && step == prevStep:
+			effective += 0.25
+		default:
+			effective++
+		}
+		prevStep = step
+		prev = r
+	}
+
+	bits := effective * math.Log2(float64(pool))
+	switch {
+	case bits < 28:
+		return 0
+	case bits < 36:
+		return 1
+	case bits < 60:
+		return 2
+	case bits < 80:
+		return 3
+	}
+	return 4
+}
+
+// BreachCorpus answers k-anonymity range queries in the format of the Pwned
+// Passwords API: given the first five hex characters of a SHA-1 hash, it
+// returns the remaining 35 characters of every breached hash with that prefix
+// and how often each was seen. Only the prefix leaves the caller.
+type BreachCorpus interface {
+	Range(ctx context.Context, prefix string) (map[string]int, error)
+}
+
+// PwnedCount reports how many times password appears in corpus.
+func PwnedCount(ctx context.Context, corpus BreachCorpus, password string) (int, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	suffixes, err := corpus.Range(ctx, hash[:5])
+	if err != nil {
+		return 0, fmt.Errorf("PwnedCount Function: %w", err)
+	}
+	return suffixes[hash[5:]], nil
+}
+
+// RangeDirectory is a local copy of the breach corpus with one file per
+// prefix (for example "5BAA6.txt"), each holding "SUFFIX:COUNT" lines as
+// produced by the Pwned Passwords downloader.
+type RangeDirectory struct {
+	Dir string
+}
+
+func (d RangeDirectory) Range(ctx context.Context, prefix string) (map[string]int, error) {
+	prefix = strings.ToUpper(prefix)
+	if len(prefix) != 5 || strings.Trim(prefix, "0123456789ABCDEF") != "" {
+		return nil, errors.New("invalid hash prefix")
+	}
+	f, err := os.Open(filepath.Join(d.Dir, prefix+".txt"))
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]int{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	suffixes := map[string]int{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		suffix, count, found := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if !found {
+			continue
+		}
+		n := 0
+		fmt.Sscanf(count, "%d", &n)
+		suffixes[strings.ToUpper(suffix)] = n
+	}
+	return suffixes, scanner.Err()
+}
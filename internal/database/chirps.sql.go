@@ -7,28 +7,47 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+const countChirpsByUser = `-- name: CountChirpsByUser :one
+SELECT COUNT(*) FROM chirps WHERE user_id = $1 AND deleted_at IS NULL
+`
+
+func (q *Queries) CountChirpsByUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countChirpsByUser, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, in_reply_to_id, quoted_chirp_id, search_language)
 VALUES (
     gen_random_uuid(),
     $1,
     $2,
     $3,
-    $4
+    $4,
+    $5,
+    $6,
+    $7
 )
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, fanned_out, in_reply_to_id, conversation_id, deleted_at, reaction_count, reaction_counts, quoted_chirp_id, search_language
 `
 
 type CreateChirpParams struct {
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	Body      string
-	UserID    uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	Body           string
+	UserID         uuid.UUID
+	InReplyToID    uuid.NullUUID
+	QuotedChirpID  uuid.NullUUID
+	SearchLanguage string
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
@@ -37,6 +56,9 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		arg.UpdatedAt,
 		arg.Body,
 		arg.UserID,
+		arg.InReplyToID,
+		arg.QuotedChirpID,
+		arg.SearchLanguage,
 	)
 	var i Chirp
 	err := row.Scan(
@@ -45,6 +67,14 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.FannedOut,
+		&i.InReplyToID,
+		&i.ConversationID,
+		&i.DeletedAt,
+		&i.ReactionCount,
+		&i.ReactionCounts,
+		&i.QuotedChirpID,
+		&i.SearchLanguage,
 	)
 	return i, err
 }
@@ -59,7 +89,7 @@ func (q *Queries) DeleteChirps(ctx context.Context) error {
 }
 
 const getChirp = `-- name: GetChirp :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps WHERE id = $1
+SELECT id, created_at, updated_at, body, user_id, fanned_out, in_reply_to_id, conversation_id, deleted_at, reaction_count, reaction_counts, quoted_chirp_id, search_language FROM chirps WHERE id = $1
 `
 
 func (q *Queries) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
@@ -71,16 +101,31 @@ func (q *Queries) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.FannedOut,
+		&i.InReplyToID,
+		&i.ConversationID,
+		&i.DeletedAt,
+		&i.ReactionCount,
+		&i.ReactionCounts,
+		&i.QuotedChirpID,
+		&i.SearchLanguage,
 	)
 	return i, err
 }
 
 const getChirps = `-- name: GetChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps ORDER BY created_at ASC
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.fanned_out, chirps.in_reply_to_id, chirps.conversation_id, chirps.deleted_at, chirps.reaction_count, chirps.reaction_counts, chirps.quoted_chirp_id, chirps.search_language FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirps.deleted_at IS NULL
+  AND (NOT users.is_private OR users.id = $1 OR EXISTS (
+    SELECT 1 FROM follows
+    WHERE follows.follower_id = $1 AND follows.followee_id = users.id AND follows.status = 'accepted'
+  ))
+ORDER BY chirps.created_at ASC
 `
 
-func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirps)
+func (q *Queries) GetChirps(ctx context.Context, viewerID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirps, viewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -94,6 +139,119 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.FannedOut,
+			&i.InReplyToID,
+			&i.ConversationID,
+			&i.DeletedAt,
+			&i.ReactionCount,
+			&i.ReactionCounts,
+			&i.QuotedChirpID,
+			&i.SearchLanguage,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getThread = `-- name: GetThread :many
+WITH RECURSIVE thread AS (
+    SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.fanned_out, chirps.in_reply_to_id, chirps.conversation_id, chirps.deleted_at, chirps.reaction_count, chirps.reaction_counts, chirps.quoted_chirp_id, chirps.search_language, 0 AS depth
+    FROM chirps
+    WHERE chirps.id = $1
+  UNION ALL
+    SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.fanned_out, chirps.in_reply_to_id, chirps.conversation_id, chirps.deleted_at, chirps.reaction_count, chirps.reaction_counts, chirps.quoted_chirp_id, chirps.search_language, thread.depth + 1
+    FROM chirps
+    JOIN thread ON chirps.in_reply_to_id = thread.id
+    JOIN users ON users.id = chirps.user_id
+    WHERE thread.depth < $2
+      AND (NOT users.is_private OR users.id = $3 OR EXISTS (
+        SELECT 1 FROM follows
+        WHERE follows.follower_id = $3 AND follows.followee_id = users.id AND follows.status = 'accepted'
+      ))
+)
+SELECT thread.id, thread.created_at, thread.updated_at, thread.body, thread.user_id, thread.fanned_out,
+    thread.in_reply_to_id, thread.conversation_id, thread.deleted_at, thread.reaction_count,
+    thread.reaction_counts, thread.quoted_chirp_id, thread.search_language, thread.depth,
+    (SELECT COUNT(*) FROM chirps AS replies
+     JOIN users AS repliers ON repliers.id = replies.user_id
+     WHERE replies.in_reply_to_id = thread.id
+       AND (NOT repliers.is_private OR repliers.id = $3 OR EXISTS (
+         SELECT 1 FROM follows
+         WHERE follows.follower_id = $3 AND follows.followee_id = repliers.id AND follows.status = 'accepted'
+       ))) AS reply_count
+FROM thread
+WHERE thread.created_at > $4 OR (thread.created_at = $4 AND thread.id > $5)
+ORDER BY thread.created_at, thread.id
+LIMIT $6
+`
+
+type GetThreadParams struct {
+	ID        uuid.UUID
+	Depth     int32
+	ViewerID  uuid.UUID
+	CreatedAt time.Time
+	CursorID  uuid.UUID
+	Limit     int32
+}
+
+type GetThreadRow struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	Body           string
+	UserID         uuid.UUID
+	FannedOut      bool
+	InReplyToID    uuid.NullUUID
+	ConversationID uuid.UUID
+	DeletedAt      sql.NullTime
+	ReactionCount  int32
+	ReactionCounts json.RawMessage
+	QuotedChirpID  uuid.NullUUID
+	SearchLanguage string
+	Depth          int32
+	ReplyCount     int64
+}
+
+func (q *Queries) GetThread(ctx context.Context, arg GetThreadParams) ([]GetThreadRow, error) {
+	rows, err := q.db.QueryContext(ctx, getThread,
+		arg.ID,
+		arg.Depth,
+		arg.ViewerID,
+		arg.CreatedAt,
+		arg.CursorID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetThreadRow
+	for rows.Next() {
+		var i GetThreadRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.FannedOut,
+			&i.InReplyToID,
+			&i.ConversationID,
+			&i.DeletedAt,
+			&i.ReactionCount,
+			&i.ReactionCounts,
+			&i.QuotedChirpID,
+			&i.SearchLanguage,
+			&i.Depth,
+			&i.ReplyCount,
 		); err != nil {
 			return nil, err
 		}
@@ -107,3 +265,22 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 	}
 	return items, nil
 }
+
+const tombstoneChirp = `-- name: TombstoneChirp :execrows
+UPDATE chirps
+SET body = '', deleted_at = $2, updated_at = $2
+WHERE id = $1 AND deleted_at IS NULL
+`
+
+type TombstoneChirpParams struct {
+	ID        uuid.UUID
+	DeletedAt sql.NullTime
+}
+
+func (q *Queries) TombstoneChirp(ctx context.Context, arg TombstoneChirpParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, tombstoneChirp, arg.ID, arg.DeletedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
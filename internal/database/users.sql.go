@@ -7,19 +7,23 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 const createUser = `-- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email, password)
+INSERT INTO users (id, created_at, updated_at, email, password, handle)
 VALUES (
     gen_random_uuid(),
     $1,
     $2,
     $3,
-    $4
+    $4,
+    $5
 )
-RETURNING id, created_at, updated_at, email, password
+RETURNING id, created_at, updated_at, email, password, email_verified_at, totp_secret, totp_enabled_at, token_version, handle, display_name, bio, avatar_url, follower_count, following_count, is_private, totp_last_counter
 `
 
 type CreateUserParams struct {
@@ -27,6 +31,7 @@ type CreateUserParams struct {
 	UpdatedAt time.Time
 	Email     string
 	Password  string
+	Handle    string
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
@@ -35,6 +40,7 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		arg.UpdatedAt,
 		arg.Email,
 		arg.Password,
+		arg.Handle,
 	)
 	var i User
 	err := row.Scan(
@@ -43,6 +49,18 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.UpdatedAt,
 		&i.Email,
 		&i.Password,
+		&i.EmailVerifiedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TokenVersion,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.FollowerCount,
+		&i.FollowingCount,
+		&i.IsPrivate,
+		&i.TotpLastCounter,
 	)
 	return i, err
 }
@@ -56,8 +74,40 @@ func (q *Queries) DeleteUsers(ctx context.Context) error {
 	return err
 }
 
+const disableUserTOTP = `-- name: DisableUserTOTP :exec
+UPDATE users
+SET totp_secret = NULL, totp_enabled_at = NULL, updated_at = $2
+WHERE id = $1
+`
+
+type DisableUserTOTPParams struct {
+	ID        uuid.UUID
+	UpdatedAt time.Time
+}
+
+func (q *Queries) DisableUserTOTP(ctx context.Context, arg DisableUserTOTPParams) error {
+	_, err := q.db.ExecContext(ctx, disableUserTOTP, arg.ID, arg.UpdatedAt)
+	return err
+}
+
+const enableUserTOTP = `-- name: EnableUserTOTP :exec
+UPDATE users
+SET totp_enabled_at = $2, updated_at = $2
+WHERE id = $1
+`
+
+type EnableUserTOTPParams struct {
+	ID            uuid.UUID
+	TotpEnabledAt sql.NullTime
+}
+
+func (q *Queries) EnableUserTOTP(ctx context.Context, arg EnableUserTOTPParams) error {
+	_, err := q.db.ExecContext(ctx, enableUserTOTP, arg.ID, arg.TotpEnabledAt)
+	return err
+}
+
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, created_at, updated_at, email, password FROM users WHERE email = $1
+SELECT id, created_at, updated_at, email, password, email_verified_at, totp_secret, totp_enabled_at, token_version, handle, display_name, bio, avatar_url, follower_count, following_count, is_private, totp_last_counter FROM users WHERE lower(email) = lower($1)
 `
 
 func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
@@ -69,6 +119,257 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.UpdatedAt,
 		&i.Email,
 		&i.Password,
+		&i.EmailVerifiedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TokenVersion,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.FollowerCount,
+		&i.FollowingCount,
+		&i.IsPrivate,
+		&i.TotpLastCounter,
+	)
+	return i, err
+}
+
+const getUserByHandle = `-- name: GetUserByHandle :one
+SELECT id, created_at, updated_at, email, password, email_verified_at, totp_secret, totp_enabled_at, token_version, handle, display_name, bio, avatar_url, follower_count, following_count, is_private, totp_last_counter FROM users WHERE lower(handle) = lower($1)
+`
+
+func (q *Queries) GetUserByHandle(ctx context.Context, lower string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByHandle, lower)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.Password,
+		&i.EmailVerifiedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TokenVersion,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.FollowerCount,
+		&i.FollowingCount,
+		&i.IsPrivate,
+		&i.TotpLastCounter,
+	)
+	return i, err
+}
+
+const getUserByID = `-- name: GetUserByID :one
+SELECT id, created_at, updated_at, email, password, email_verified_at, totp_secret, totp_enabled_at, token_version, handle, display_name, bio, avatar_url, follower_count, following_count, is_private, totp_last_counter FROM users WHERE id = $1
+`
+
+func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.Password,
+		&i.EmailVerifiedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TokenVersion,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.FollowerCount,
+		&i.FollowingCount,
+		&i.IsPrivate,
+		&i.TotpLastCounter,
+	)
+	return i, err
+}
+
+const incrementUserTokenVersion = `-- name: IncrementUserTokenVersion :one
+UPDATE users
+SET token_version = token_version + 1, updated_at = $2
+WHERE id = $1
+RETURNING token_version
+`
+
+type IncrementUserTokenVersionParams struct {
+	ID        uuid.UUID
+	UpdatedAt time.Time
+}
+
+func (q *Queries) IncrementUserTokenVersion(ctx context.Context, arg IncrementUserTokenVersionParams) (int32, error) {
+	row := q.db.QueryRowContext(ctx, incrementUserTokenVersion, arg.ID, arg.UpdatedAt)
+	var token_version int32
+	err := row.Scan(&token_version)
+	return token_version, err
+}
+
+const setUserTOTPSecret = `-- name: SetUserTOTPSecret :exec
+UPDATE users
+SET totp_secret = $2, totp_enabled_at = NULL, updated_at = $3
+WHERE id = $1
+`
+
+type SetUserTOTPSecretParams struct {
+	ID         uuid.UUID
+	TotpSecret sql.NullString
+	UpdatedAt  time.Time
+}
+
+func (q *Queries) SetUserTOTPSecret(ctx context.Context, arg SetUserTOTPSecretParams) error {
+	_, err := q.db.ExecContext(ctx, setUserTOTPSecret, arg.ID, arg.TotpSecret, arg.UpdatedAt)
+	return err
+}
+
+const updateUserPassword = `-- name: UpdateUserPassword :one
+UPDATE users
+SET password = $2, updated_at = $3, token_version = token_version + 1
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, password, email_verified_at, totp_secret, totp_enabled_at, token_version, handle, display_name, bio, avatar_url, follower_count, following_count, is_private, totp_last_counter
+`
+
+type UpdateUserPasswordParams struct {
+	ID        uuid.UUID
+	Password  string
+	UpdatedAt time.Time
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserPassword, arg.ID, arg.Password, arg.UpdatedAt)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.Password,
+		&i.EmailVerifiedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TokenVersion,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.FollowerCount,
+		&i.FollowingCount,
+		&i.IsPrivate,
+		&i.TotpLastCounter,
+	)
+	return i, err
+}
+
+const updateUserProfile = `-- name: UpdateUserProfile :one
+UPDATE users
+SET handle = $2, display_name = $3, bio = $4, avatar_url = $5, is_private = $6, updated_at = $7
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, password, email_verified_at, totp_secret, totp_enabled_at, token_version, handle, display_name, bio, avatar_url, follower_count, following_count, is_private, totp_last_counter
+`
+
+type UpdateUserProfileParams struct {
+	ID          uuid.UUID
+	Handle      string
+	DisplayName string
+	Bio         string
+	AvatarUrl   string
+	IsPrivate   bool
+	UpdatedAt   time.Time
+}
+
+func (q *Queries) UpdateUserProfile(ctx context.Context, arg UpdateUserProfileParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserProfile,
+		arg.ID,
+		arg.Handle,
+		arg.DisplayName,
+		arg.Bio,
+		arg.AvatarUrl,
+		arg.IsPrivate,
+		arg.UpdatedAt,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.Password,
+		&i.EmailVerifiedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TokenVersion,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.FollowerCount,
+		&i.FollowingCount,
+		&i.IsPrivate,
+		&i.TotpLastCounter,
+	)
+	return i, err
+}
+
+const useUserTOTPCounter = `-- name: UseUserTOTPCounter :execrows
+UPDATE users
+SET totp_last_counter = $1
+WHERE id = $2 AND (totp_last_counter IS NULL OR totp_last_counter < $1)
+`
+
+type UseUserTOTPCounterParams struct {
+	Counter int64
+	ID      uuid.UUID
+}
+
+func (q *Queries) UseUserTOTPCounter(ctx context.Context, arg UseUserTOTPCounterParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, useUserTOTPCounter, arg.Counter, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const verifyUserEmail = `-- name: VerifyUserEmail :one
+UPDATE users
+SET email_verified_at = $3, updated_at = $3
+WHERE id = $1 AND email = $2
+RETURNING id, created_at, updated_at, email, password, email_verified_at, totp_secret, totp_enabled_at, token_version, handle, display_name, bio, avatar_url, follower_count, following_count, is_private, totp_last_counter
+`
+
+type VerifyUserEmailParams struct {
+	ID              uuid.UUID
+	Email           string
+	EmailVerifiedAt sql.NullTime
+}
+
+func (q *Queries) VerifyUserEmail(ctx context.Context, arg VerifyUserEmailParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, verifyUserEmail, arg.ID, arg.Email, arg.EmailVerifiedAt)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.Password,
+		&i.EmailVerifiedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TokenVersion,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.FollowerCount,
+		&i.FollowingCount,
+		&i.IsPrivate,
+		&i.TotpLastCounter,
 	)
 	return i, err
 }
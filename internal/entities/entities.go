@@ -0,0 +1,155 @@
+// Package entities finds hashtags, mentions and other entities in chirp
+// bodies.
+//
+// Offsets are in runes (Unicode code points) into the body as written, with
+// End exclusive, so clients can highlight entities without re-parsing.
+package entities
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// MaxTagLength is the longest hashtag recognised, in runes.
+const MaxTagLength = 100
+
+type Hashtag struct {
+	Tag   string `json:"tag"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+// NormalizeTag returns the form hashtags are stored and looked up by: case
+// folded and NFKC normalised, so that #Café, #CAFÉ written with a combining
+// accent and full-width #ｃａｆé all name the same tag. A leading # is
+// removed.
+func NormalizeTag(tag string) string {
+	tag = strings.TrimLeft(tag, "#＃")
+	return cases.Fold().String(norm.NFKC.String(tag))
+}
+
+func isTagRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r) || r == '_' || r == '\u200d'
+}
+
+func isHashSign(r rune) bool {
+	return r == '#' || r == '＃'
+}
+
+// Hashtags returns the hashtags in body in order of appearance. A hashtag is
+// a # followed by letters, digits, combining marks and underscores in any
+// script, with at least one letter. A # directly after a word character or
+// a slash, as in "C#", "&#39;" or a URL fragment, does not start one.
+func Hashtags(body string) []Hashtag {
+	runes := []rune(body)
+	hashtags := []Hashtag{}
+	for i := 0; i < len(runes); i++ {
+		if !isHashSign(runes[i]) {
+			continue
+		}
+		if i > 0 && (isTagRune(runes[i-1]) || isHashSign(runes[i-1]) || runes[i-1] == '&' || runes[i-1] == '/') {
+			continue
+		}
+		end := i + 1
+		hasLetter := false
+		for end < len(runes) && isTagRune(runes[end]) {
+			if unicode.IsLetter(runes[end]) {
+				hasLetter = true
+			}
+			end++
+		}
+		length := end - i - 1
+		// "#tag#tag" and "#tag://" are more likely something else.
+		followed := end < len(runes) && (isHashSign(runes[end]) || strings.HasPrefix(string(runes[end:]), "://"))
+		if hasLetter && length <= MaxTagLength && !followed {
+			hashtags = append(hashtags, Hashtag{
+				Tag:   NormalizeTag(string(runes[i+1 : end])),
+				Start: i,
+				End:   end,
+			})
+		}
+		i = end - 1
+	}
+	return hashtags
+}
+
+// UniqueTags returns each distinct tag in hashtags once, in order.
+func UniqueTags(hashtags []Hashtag) []string {
+	seen := map[string]bool{}
+	tags := []string{}
+	for _, hashtag := range hashtags {
+		if !seen[hashtag.Tag] {
+			seen[hashtag.Tag] = true
+			tags = append(tags, hashtag.Tag)
+		}
+	}
+	return tags
+}
+
+// Handles are 3 to 30 ASCII letters, digits and underscores, as enforced
+// when a profile is edited.
+const (
+	minHandleLength = 3
+	maxHandleLength = 30
+)
+
+// Mention is an @handle in a chirp. Handle is as written, without the @;
+// handles are unique regardless of case.
+type Mention struct {
+	Handle string `json:"handle"`
+	Start  int    `json:"start"`
+	End    int    `json:"end"`
+}
+
+func isHandleRune(r rune) bool {
+	return r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_')
+}
+
+// Mentions returns the @handles in body in order of appearance. An @ directly
+// after a word character, as in an email address, does not start one, nor
+// does a run of handle characters too short or too long to be a handle.
+func Mentions(body string) []Mention {
+	runes := []rune(body)
+	mentions := []Mention{}
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != '@' && runes[i] != '＠' {
+			continue
+		}
+		if i > 0 && (isTagRune(runes[i-1]) || runes[i-1] == '@' || runes[i-1] == '＠') {
+			continue
+		}
+		end := i + 1
+		for end < len(runes) && isHandleRune(runes[end]) {
+			end++
+		}
+		length := end - i - 1
+		// "@bob@example.com" and "@bobé" are not mentions of bob.
+		followed := end < len(runes) && (runes[end] == '@' || isTagRune(runes[end]))
+		if length >= minHandleLength && length <= maxHandleLength && !followed {
+			mentions = append(mentions, Mention{
+				Handle: string(runes[i+1 : end]),
+				Start:  i,
+				End:    end,
+			})
+		}
+		i = end - 1
+	}
+	return mentions
+}
+
+// UniqueHandles returns each handle in mentions once, in order, lowercased.
+func UniqueHandles(mentions []Mention) []string {
+	seen := map[string]bool{}
+	handles := []string{}
+	for _, mention := range mentions {
+		handle := strings.ToLower(mention.Handle)
+		if !seen[handle] {
+			seen[handle] = true
+			handles = append(handles, handle)
+		}
+	}
+	return handles
+}
@@ -0,0 +1,100 @@
+package entities
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHashtags(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []Hashtag
+	}{
+		{"plain", "#Go is fun", []Hashtag{{Tag: "go", Start: 0, End: 3}}},
+		{"offsets are in runes", "🎉 héllo #party", []Hashtag{{Tag: "party", Start: 8, End: 14}}},
+		{"several", "#one and #two", []Hashtag{{Tag: "one", Start: 0, End: 4}, {Tag: "two", Start: 9, End: 13}}},
+		{"case folded", "#CAFÉ", []Hashtag{{Tag: "café", Start: 0, End: 5}}},
+		{"combining accent composed", "#café", []Hashtag{{Tag: "café", Start: 0, End: 6}}},
+		{"full-width", "＃ｃａｆé", []Hashtag{{Tag: "café", Start: 0, End: 5}}},
+		{"sharp s folded", "#Straße", []Hashtag{{Tag: "strasse", Start: 0, End: 7}}},
+		{"other scripts", "#日本語", []Hashtag{{Tag: "日本語", Start: 0, End: 4}}},
+		{"underscores and digits", "#go_1_22", []Hashtag{{Tag: "go_1_22", Start: 0, End: 8}}},
+		{"trailing punctuation", "(#go).", []Hashtag{{Tag: "go", Start: 1, End: 4}}},
+		{"after a word character", "I write C# daily", []Hashtag{}},
+		{"html entity", "it&#39;s", []Hashtag{}},
+		{"url fragment", "https://example.com/#top", []Hashtag{}},
+		{"digits only", "#2024", []Hashtag{}},
+		{"run together", "#tag#tag", []Hashtag{}},
+		{"scheme", "#http://example.com", []Hashtag{}},
+		{"too long", "#" + strings.Repeat("a", MaxTagLength+1), []Hashtag{}},
+		{"longest allowed", "#" + strings.Repeat("a", MaxTagLength), []Hashtag{{Tag: strings.Repeat("a", MaxTagLength), Start: 0, End: MaxTagLength + 1}}},
+		{"bare hash", "# ", []Hashtag{}},
+	}
+	for _, tt := range tests {
+		if got := Hashtags(tt.body); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Hashtags(%q) = %+v, want %+v", tt.name, tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeTag(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want string
+	}{
+		{"#Café", "café"},
+		{"CAFÉ", "café"},
+		{"＃ｃａｆé", "café"},
+		{"Straße", "strasse"},
+		{"ΣΊΣΥΦΟΣ", "σίσυφοσ"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := NormalizeTag(tt.tag); got != tt.want {
+			t.Errorf("NormalizeTag(%q) = %q, want %q", tt.tag, got, tt.want)
+		}
+	}
+}
+
+func TestUniqueTags(t *testing.T) {
+	got := UniqueTags(Hashtags("#Go #go #GO #rust #Go"))
+	want := []string{"go", "rust"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("UniqueTags = %v, want %v", got, want)
+	}
+}
+
+func TestMentions(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []Mention
+	}{
+		{"plain", "hi @bob!", []Mention{{Handle: "bob", Start: 3, End: 7}}},
+		{"offsets are in runes", "🎉 @alice", []Mention{{Handle: "alice", Start: 2, End: 8}}},
+		{"case kept", "@Alice_01", []Mention{{Handle: "Alice_01", Start: 0, End: 9}}},
+		{"full-width at sign", "＠alice", []Mention{{Handle: "alice", Start: 0, End: 6}}},
+		{"several", "@bob and @carol", []Mention{{Handle: "bob", Start: 0, End: 4}, {Handle: "carol", Start: 9, End: 15}}},
+		{"email address", "mail bob@example.com", []Mention{}},
+		{"handle followed by a domain", "@bob@example.com", []Mention{}},
+		{"non-ASCII letter", "@bobé", []Mention{}},
+		{"too short", "@ab", []Mention{}},
+		{"too long", "@" + strings.Repeat("a", maxHandleLength+1), []Mention{}},
+		{"double at sign", "@@bob", []Mention{}},
+	}
+	for _, tt := range tests {
+		if got := Mentions(tt.body); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Mentions(%q) = %+v, want %+v", tt.name, tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestUniqueHandles(t *testing.T) {
+	got := UniqueHandles(Mentions("@Bob @bob @carol @BOB"))
+	want := []string{"bob", "carol"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("UniqueHandles = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,183 @@
+// Package lockout tracks failed login attempts and temporarily locks out
+// accounts and client addresses that keep failing.
+package lockout
+
+import (
+	"context"
+	"time"
+)
+
+// State is what a Store remembers about a single key (an account or a client
+// address). Attempts are counted as failures until they succeed.
+type State struct {
+	Failures      int
+	LastFailureAt time.Time
+	LockedUntil   time.Time
+}
+
+// Store persists attempt state. Attempt must count the attempt and set the
+// lock it triggers atomically, so that concurrent attempts cannot all get in
+// before the key is locked.
+type Store interface {
+	// Attempt counts an attempt against key, starting over at one when the
+	// previous one is older than resetBefore, and locks the key for policy's
+	// delay once the count reaches its threshold. Nothing is counted while the
+	// key is locked; it returns the state and whether the attempt counted.
+	Attempt(ctx context.Context, key string, now, resetBefore time.Time, policy Policy) (State, bool, error)
+	// Refund takes back one attempt, along with the lock it triggered.
+	Refund(ctx context.Context, key string, policy Policy) error
+	Reset(ctx context.Context, key string) error
+	// Prune forgets keys starting with prefix whose last attempt is older
+	// than before and that are not locked at now.
+	Prune(ctx context.Context, prefix string, before, now time.Time) error
+}
+
+// Policy describes when a key gets locked and for how long. Once Failures
+// reaches Threshold the key is locked for BaseDelay, doubling with every
+// further failure up to MaxDelay. Failures older than ResetAfter are
+// forgotten.
+type Policy struct {
+	Threshold  int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+	ResetAfter time.Duration
+}
+
+func (p Policy) delay(failures int) time.Duration {
+	if failures < p.Threshold {
+		return 0
+	}
+	d := p.BaseDelay
+	for i := p.Threshold; i < failures; i++ {
+		d *= 2
+		if d >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	return d
+}
+
+var DefaultAccountPolicy = Policy{
+	Threshold:  5,
+	BaseDelay:  30 * time.Second,
+	MaxDelay:   time.Hour,
+	ResetAfter: 24 * time.Hour,
+}
+
+var DefaultAddressPolicy = Policy{
+	Threshold:  20,
+	BaseDelay:  time.Minute,
+	MaxDelay:   time.Hour,
+	ResetAfter: time.Hour,
+}
+
+// Limiter applies separate policies to accounts and client addresses on top
+// of a shared Store. Limiters sharing a Store must use distinct Namespaces,
+// none a prefix of another, or pruning one would prune the others too.
+type Limiter struct {
+	Store         Store
+	Namespace     string
+	AccountPolicy Policy
+	AddressPolicy Policy
+	now           func() time.Time
+}
+
+// DefaultNamespace is the Namespace of limiters made by NewLimiter.
+const DefaultNamespace = "login:"
+
+func NewLimiter(store Store) *Limiter {
+	return &Limiter{
+		Store:         store,
+		Namespace:     DefaultNamespace,
+		AccountPolicy: DefaultAccountPolicy,
+		AddressPolicy: DefaultAddressPolicy,
+		now:           time.Now,
+	}
+}
+
+func AccountKey(email string) string {
+	return "account:" + email
+}
+
+func AddressKey(ip string) string {
+	return "address:" + ip
+}
+
+// Attempt counts a login attempt against the address and the account before
+// the credentials are checked, so that concurrent guesses cannot all get past
+// a check made before any of them has failed. It returns how long the caller
+// has to wait if either is locked, in which case the attempt must be refused,
+// and whether this attempt locked either of them. A successful attempt is
+// taken back with Succeed.
+func (l *Limiter) Attempt(ctx context.Context, email, ip string) (time.Duration, bool, error) {
+	now := l.now()
+	locked := false
+	// The address goes first so that a locked address cannot count attempts
+	// against accounts.
+	for _, k := range []struct {
+		key    string
+		policy Policy
+	}{
+		{l.Namespace + AddressKey(ip), l.AddressPolicy},
+		{l.Namespace + AccountKey(email), l.AccountPolicy},
+	} {
+		state, counted, err := l.Store.Attempt(ctx, k.key, now, now.Add(-k.policy.ResetAfter), k.policy)
+		if err != nil {
+			return 0, locked, err
+		}
+		if !counted {
+			// The lock may have run out since; the attempt is refused all
+			// the same, so the caller is told to wait at least a moment.
+			return max(state.LockedUntil.Sub(now), time.Second), locked, nil
+		}
+		if state.LockedUntil.After(now) {
+			locked = true
+		}
+	}
+	return 0, locked, nil
+}
+
+// Succeed clears the account's history after a successful attempt. The
+// address only gets the attempt back, so a client cannot reset its budget by
+// logging into its own account.
+func (l *Limiter) Succeed(ctx context.Context, email, ip string) error {
+	if err := l.Store.Reset(ctx, l.Namespace+AccountKey(email)); err != nil {
+		return err
+	}
+	return l.Store.Refund(ctx, l.Namespace+AddressKey(ip), l.AddressPolicy)
+}
+
+// Refund takes back an attempt that was good but did not finish the login,
+// such as a password that still needs a second factor. Unlike Succeed it
+// leaves the account's earlier failures in place.
+func (l *Limiter) Refund(ctx context.Context, email, ip string) error {
+	if err := l.Store.Refund(ctx, l.Namespace+AccountKey(email), l.AccountPolicy); err != nil {
+		return err
+	}
+	return l.Store.Refund(ctx, l.Namespace+AddressKey(ip), l.AddressPolicy)
+}
+
+// Prune forgets accounts and addresses whose attempts are too old to count
+// and that are not locked. Limiters sharing a Store each prune their own
+// Namespace.
+func (l *Limiter) Prune(ctx context.Context) error {
+	now := l.now()
+	horizon := max(l.AccountPolicy.ResetAfter, l.AddressPolicy.ResetAfter)
+	return l.Store.Prune(ctx, l.Namespace, now.Add(-horizon), now)
+}
+
+// Unlock clears the history of an account and/or an address. Empty values are
+// ignored.
+func (l *Limiter) Unlock(ctx context.Context, email, ip string) error {
+	if email != "" {
+		if err := l.Store.Reset(ctx, l.Namespace+AccountKey(email)); err != nil {
+			return err
+		}
+	}
+	if ip != "" {
+		if err := l.Store.Reset(ctx, l.Namespace+AddressKey(ip)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,233 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testPolicy = Policy{
+	Threshold:  3,
+	BaseDelay:  time.Minute,
+	MaxDelay:   4 * time.Minute,
+	ResetAfter: time.Hour,
+}
+
+// testLimiter returns a limiter over a fresh memory store whose clock only
+// moves when the test advances it.
+func testLimiter() (*Limiter, *MemoryStore, *time.Time) {
+	store := NewMemoryStore()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := NewLimiter(store)
+	l.AccountPolicy = testPolicy
+	l.AddressPolicy = Policy{Threshold: 10, BaseDelay: time.Minute, MaxDelay: time.Hour, ResetAfter: time.Hour}
+	l.now = func() time.Time { return now }
+	return l, store, &now
+}
+
+func failures(store *MemoryStore, key string) int {
+	store.mux.Lock()
+	defer store.mux.Unlock()
+	return store.states[key].Failures
+}
+
+func TestPolicyDelay(t *testing.T) {
+	policy := Policy{Threshold: 5, BaseDelay: 30 * time.Second, MaxDelay: time.Hour}
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{4, 0},
+		{5, 30 * time.Second},
+		{6, time.Minute},
+		{7, 2 * time.Minute},
+		{11, 32 * time.Minute},
+		{12, time.Hour},
+		{100, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := policy.delay(tt.failures); got != tt.want {
+			t.Errorf("delay(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestLimiterBacksOff(t *testing.T) {
+	ctx := context.Background()
+	l, _, now := testLimiter()
+	tests := []struct {
+		name       string
+		advance    time.Duration
+		wantWait   time.Duration
+		wantLocked bool
+	}{
+		{"first", 0, 0, false},
+		{"second", 0, 0, false},
+		{"third reaches the threshold", 0, 0, true},
+		{"refused while locked", 30 * time.Second, 30 * time.Second, false},
+		{"counted once the lock ends", 30 * time.Second, 0, true},
+		{"lock doubled", time.Minute, time.Minute, false},
+		{"capped at MaxDelay", 5 * time.Minute, 0, true},
+		{"capped lock", 0, 4 * time.Minute, false},
+	}
+	for _, tt := range tests {
+		*now = now.Add(tt.advance)
+		wait, locked, err := l.Attempt(ctx, "bob@example.com", "10.0.0.1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wait != tt.wantWait || locked != tt.wantLocked {
+			t.Errorf("%s: wait = %v, locked = %v, want %v, %v", tt.name, wait, locked, tt.wantWait, tt.wantLocked)
+		}
+	}
+}
+
+func TestLimiterForgetsOldFailures(t *testing.T) {
+	ctx := context.Background()
+	l, store, now := testLimiter()
+	for range testPolicy.Threshold - 1 {
+		l.Attempt(ctx, "bob@example.com", "10.0.0.1")
+	}
+	*now = now.Add(testPolicy.ResetAfter + time.Second)
+	if _, locked, _ := l.Attempt(ctx, "bob@example.com", "10.0.0.1"); locked {
+		t.Errorf("old failures counted towards the lock")
+	}
+	if n := failures(store, DefaultNamespace+AccountKey("bob@example.com")); n != 1 {
+		t.Errorf("failures = %d, want 1", n)
+	}
+}
+
+func TestLimiterLocksAddressAcrossAccounts(t *testing.T) {
+	ctx := context.Background()
+	l, store, _ := testLimiter()
+	l.AddressPolicy.Threshold = 3
+	emails := []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com"}
+	for i, email := range emails {
+		wait, _, err := l.Attempt(ctx, email, "10.0.0.1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if refused := wait > 0; refused != (i >= 3) {
+			t.Errorf("attempt %d: wait = %v", i+1, wait)
+		}
+	}
+	// A locked address does not count attempts against accounts.
+	if n := failures(store, DefaultNamespace+AccountKey("d@example.com")); n != 0 {
+		t.Errorf("refused attempt counted against the account: %d", n)
+	}
+	if wait, _, _ := l.Attempt(ctx, "a@example.com", "10.0.0.2"); wait > 0 {
+		t.Errorf("other address refused")
+	}
+}
+
+func TestLimiterSucceedAndRefund(t *testing.T) {
+	tests := []struct {
+		name         string
+		settle       func(l *Limiter, ctx context.Context) error
+		wantAccount  int
+		wantAddress  int
+		wantUnlocked bool
+	}{
+		{"succeed clears the account", func(l *Limiter, ctx context.Context) error {
+			return l.Succeed(ctx, "bob@example.com", "10.0.0.1")
+		}, 0, 2, true},
+		{"refund keeps earlier failures", func(l *Limiter, ctx context.Context) error {
+			return l.Refund(ctx, "bob@example.com", "10.0.0.1")
+		}, 2, 2, true},
+		{"unlock clears both", func(l *Limiter, ctx context.Context) error {
+			return l.Unlock(ctx, "bob@example.com", "10.0.0.1")
+		}, 0, 0, true},
+	}
+	for _, tt := range tests {
+		ctx := context.Background()
+		l, store, _ := testLimiter()
+		for range testPolicy.Threshold {
+			l.Attempt(ctx, "bob@example.com", "10.0.0.1")
+		}
+		if err := tt.settle(l, ctx); err != nil {
+			t.Fatal(err)
+		}
+		if n := failures(store, DefaultNamespace+AccountKey("bob@example.com")); n != tt.wantAccount {
+			t.Errorf("%s: account failures = %d, want %d", tt.name, n, tt.wantAccount)
+		}
+		if n := failures(store, DefaultNamespace+AddressKey("10.0.0.1")); n != tt.wantAddress {
+			t.Errorf("%s: address failures = %d, want %d", tt.name, n, tt.wantAddress)
+		}
+		if wait, _, _ := l.Attempt(ctx, "bob@example.com", "10.0.0.1"); (wait == 0) != tt.wantUnlocked {
+			t.Errorf("%s: wait = %v after settling", tt.name, wait)
+		}
+	}
+}
+
+func TestLimiterPrune(t *testing.T) {
+	ctx := context.Background()
+	l, store, now := testLimiter()
+	other := NewLimiter(store)
+	other.Namespace = "magic:"
+	other.now = l.now
+
+	for range testPolicy.Threshold + 1 {
+		l.Attempt(ctx, "locked@example.com", "10.0.0.1")
+	}
+	l.Attempt(ctx, "old@example.com", "10.0.0.2")
+	other.Attempt(ctx, "old@example.com", "10.0.0.2")
+	// Long enough for every failure to be forgotten, but the lock of
+	// locked@example.com is pushed out past it.
+	store.mux.Lock()
+	state := store.states[DefaultNamespace+AccountKey("locked@example.com")]
+	state.LockedUntil = now.Add(3 * time.Hour)
+	store.states[DefaultNamespace+AccountKey("locked@example.com")] = state
+	store.mux.Unlock()
+	*now = now.Add(2 * time.Hour)
+	l.Attempt(ctx, "new@example.com", "10.0.0.3")
+
+	if err := l.Prune(ctx); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		key  string
+		kept bool
+	}{
+		{DefaultNamespace + AccountKey("locked@example.com"), true},
+		{DefaultNamespace + AccountKey("old@example.com"), false},
+		{DefaultNamespace + AddressKey("10.0.0.2"), false},
+		{DefaultNamespace + AccountKey("new@example.com"), true},
+		{DefaultNamespace + AddressKey("10.0.0.3"), true},
+		{"magic:" + AccountKey("old@example.com"), true},
+	}
+	for _, tt := range tests {
+		store.mux.Lock()
+		_, ok := store.states[tt.key]
+		store.mux.Unlock()
+		if ok != tt.kept {
+			t.Errorf("%s: kept = %v, want %v", tt.key, ok, tt.kept)
+		}
+	}
+}
+
+func TestLimiterConcurrentAttempts(t *testing.T) {
+	ctx := context.Background()
+	l, _, _ := testLimiter()
+	l.AddressPolicy.Threshold = 1000
+	var mux sync.Mutex
+	allowed := 0
+	var wg sync.WaitGroup
+	for range 200 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			wait, _, err := l.Attempt(ctx, "bob@example.com", "10.0.0.1")
+			if err == nil && wait == 0 {
+				mux.Lock()
+				allowed++
+				mux.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if allowed != testPolicy.Threshold {
+		t.Errorf("%d concurrent attempts allowed, want %d", allowed, testPolicy.Threshold)
+	}
+}
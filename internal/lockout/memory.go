@@ -0,0 +1,72 @@
+package lockout
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps attempt state in process memory. It is only suitable for
+// a single instance; use PostgresStore when running more than one.
+type MemoryStore struct {
+	mux    sync.Mutex
+	states map[string]State
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{states: map[string]State{}}
+}
+
+func (m *MemoryStore) Attempt(ctx context.Context, key string, now, resetBefore time.Time, policy Policy) (State, bool, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	state := m.states[key]
+	if state.LockedUntil.After(now) {
+		return state, false, nil
+	}
+	if state.LastFailureAt.Before(resetBefore) {
+		state.Failures = 0
+	}
+	state.Failures++
+	state.LastFailureAt = now
+	state.LockedUntil = time.Time{}
+	if d := policy.delay(state.Failures); d > 0 {
+		state.LockedUntil = now.Add(d)
+	}
+	m.states[key] = state
+	return state, true, nil
+}
+
+func (m *MemoryStore) Refund(ctx context.Context, key string, policy Policy) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	state, ok := m.states[key]
+	if !ok {
+		return nil
+	}
+	state.Failures = max(state.Failures-1, 0)
+	if state.Failures < policy.Threshold {
+		state.LockedUntil = time.Time{}
+	}
+	m.states[key] = state
+	return nil
+}
+
+func (m *MemoryStore) Reset(ctx context.Context, key string) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	delete(m.states, key)
+	return nil
+}
+
+func (m *MemoryStore) Prune(ctx context.Context, prefix string, before, now time.Time) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	for key, state := range m.states {
+		if strings.HasPrefix(key, prefix) && state.LastFailureAt.Before(before) && !state.LockedUntil.After(now) {
+			delete(m.states, key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package lockout
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/ablanchetMD/chirpy/internal/database"
+)
+
+// PostgresStore shares attempt state between every instance using the
+// login_attempts table.
+type PostgresStore struct {
+	Db *database.Queries
+}
+
+func NewPostgresStore(db *database.Queries) *PostgresStore {
+	return &PostgresStore{Db: db}
+}
+
+func mapState(src database.LoginAttempt) State {
+	return State{
+		Failures:      int(src.Failures),
+		LastFailureAt: src.LastFailureAt,
+		LockedUntil:   src.LockedUntil.Time,
+	}
+}
+
+func (p *PostgresStore) Attempt(ctx context.Context, key string, now, resetBefore time.Time, policy Policy) (State, bool, error) {
+	attempt, err := p.Db.RecordLoginAttempt(ctx, database.RecordLoginAttemptParams{
+		Key:         key,
+		Now:         now,
+		Threshold:   int32(policy.Threshold),
+		BaseDelay:   policy.BaseDelay.Seconds(),
+		MaxDelay:    policy.MaxDelay.Seconds(),
+		ResetBefore: resetBefore,
+	})
+	if err == sql.ErrNoRows {
+		// The key is locked, so the upsert left it alone.
+		attempt, err = p.Db.GetLoginAttempt(ctx, key)
+		if err != nil {
+			return State{}, false, err
+		}
+		return mapState(attempt), false, nil
+	}
+	if err != nil {
+		return State{}, false, err
+	}
+	return mapState(attempt), true, nil
+}
+
+func (p *PostgresStore) Refund(ctx context.Context, key string, policy Policy) error {
+	return p.Db.RefundLoginAttempt(ctx, database.RefundLoginAttemptParams{
+		Key:       key,
+		Threshold: int32(policy.Threshold),
+	})
+}
+
+func (p *PostgresStore) Reset(ctx context.Context, key string) error {
+	return p.Db.DeleteLoginAttempt(ctx, key)
+}
+
+func (p *PostgresStore) Prune(ctx context.Context, prefix string, before, now time.Time) error {
+	_, err := p.Db.PruneLoginAttempts(ctx, database.PruneLoginAttemptsParams{
+		Prefix: prefix,
+		Before: before,
+		Now:    now,
+	})
+	return err
+}
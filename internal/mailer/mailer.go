@@ -0,0 +1,101 @@
+// Package mailer sends transactional email (verification and login links)
+// through a pluggable backend.
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// LogMailer prints messages to stdout. It is the fallback when nothing else
+// is configured.
+type LogMailer struct{}
+
+func (LogMailer) Send(ctx context.Context, msg Message) error {
+	fmt.Println("Mail to ", msg.To, ": ", msg.Subject, "\n", msg.Body)
+	return nil
+}
+
+// FileMailer writes every message to its own .eml file in Dir, which is handy
+// for local development and tests.
+type FileMailer struct {
+	Dir string
+	mux sync.Mutex
+	seq int
+}
+
+func NewFileMailer(dir string) (*FileMailer, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, fmt.Errorf("NewFileMailer Function: %w", err)
+	}
+	return &FileMailer{Dir: dir}, nil
+}
+
+func (f *FileMailer) Send(ctx context.Context, msg Message) error {
+	f.mux.Lock()
+	f.seq++
+	name := fmt.Sprintf("%s-%04d.eml", time.Now().UTC().Format("20060102T150405"), f.seq)
+	f.mux.Unlock()
+
+	err := os.WriteFile(filepath.Join(f.Dir, name), []byte(formatMessage("chirpy@localhost", msg)), 0o644)
+	if err != nil {
+		return fmt.Errorf("FileMailer.Send Function: %w", err)
+	}
+	return nil
+}
+
+// SMTPMailer relays messages through an SMTP server.
+type SMTPMailer struct {
+	Addr string
+	From string
+	Auth smtp.Auth
+}
+
+func NewSMTPMailer(addr, from, username, password string) *SMTPMailer {
+	var auth smtp.Auth
+	if username != "" {
+		host := addr
+		if i := strings.LastIndex(addr, ":"); i >= 0 {
+			host = addr[:i]
+		}
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+	return &SMTPMailer{Addr: addr, From: from, Auth: auth}
+}
+
+func (s *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	err := smtp.SendMail(s.Addr, s.Auth, s.From, []string{msg.To}, []byte(formatMessage(s.From, msg)))
+	if err != nil {
+		return fmt.Errorf("SMTPMailer.Send Function: %w", err)
+	}
+	return nil
+}
+
+func formatMessage(from string, msg Message) string {
+	var b strings.Builder
+	b.WriteString("From: " + from + "\r\n")
+	b.WriteString("To: " + msg.To + "\r\n")
+	b.WriteString("Subject: " + msg.Subject + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return b.String()
+}
@@ -0,0 +1,175 @@
+// Package realtime fans events out to connected clients. A Hub is an
+// in-process publish/subscribe broker shared by every realtime transport;
+// Listen feeds it from Postgres LISTEN/NOTIFY so events raised on one server
+// instance reach clients connected to any of them.
+package realtime
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// Message is an event published on a topic. Payload is JSON, so transports
+// can pass it through or decode it as they need.
+type Message struct {
+	Topic   string
+	Payload json.RawMessage
+}
+
+type Hub struct {
+	mu     sync.RWMutex
+	topics map[string]map[*Subscription]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{topics: map[string]map[*Subscription]struct{}{}}
+}
+
+// Subscription receives the messages published on its topics. Publishers
+// never wait for a subscriber: one that lets its buffer fill up is dropped,
+// and Done is closed with Overflowed reporting true. Clients of a dropped
+// subscription are expected to reconnect and catch up from storage.
+type Subscription struct {
+	hub *Hub
+	ch  chan Message
+
+	mu         sync.Mutex
+	topics     map[string]struct{}
+	done       chan struct{}
+	closed     bool
+	overflowed bool
+}
+
+// Subscribe starts a subscription to topics with room for buffer undelivered
+// messages.
+func (h *Hub) Subscribe(buffer int, topics ...string) *Subscription {
+	s := &Subscription{
+		hub:    h,
+		ch:     make(chan Message, buffer),
+		topics: map[string]struct{}{},
+		done:   make(chan struct{}),
+	}
+	s.Add(topics...)
+	return s
+}
+
+// Publish delivers payload to every subscription to topic.
+func (h *Hub) Publish(topic string, payload json.RawMessage) {
+	h.mu.RLock()
+	subs := make([]*Subscription, 0, len(h.topics[topic]))
+	for s := range h.topics[topic] {
+		subs = append(subs, s)
+	}
+	h.mu.RUnlock()
+
+	for _, s := range subs {
+		s.deliver(Message{Topic: topic, Payload: payload})
+	}
+}
+
+// Subscribers returns how many subscriptions topic has.
+func (h *Hub) Subscribers(topic string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.topics[topic])
+}
+
+func (s *Subscription) deliver(m Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	select {
+	case s.ch <- m:
+	default:
+		s.overflowed = true
+		s.closeLocked()
+	}
+}
+
+// C delivers the subscription's messages.
+func (s *Subscription) C() <-chan Message {
+	return s.ch
+}
+
+// Done is closed when the subscription is closed or dropped.
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// Overflowed reports whether the subscription was dropped for falling
+// behind.
+func (s *Subscription) Overflowed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.overflowed
+}
+
+// Add subscribes to more topics.
+func (s *Subscription) Add(topics ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	for _, topic := range topics {
+		s.topics[topic] = struct{}{}
+		if s.hub.topics[topic] == nil {
+			s.hub.topics[topic] = map[*Subscription]struct{}{}
+		}
+		s.hub.topics[topic][s] = struct{}{}
+	}
+}
+
+// Remove unsubscribes from topics.
+func (s *Subscription) Remove(topics ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	for _, topic := range topics {
+		delete(s.topics, topic)
+		s.hub.removeLocked(topic, s)
+	}
+}
+
+// Topics returns the topics s is subscribed to.
+func (s *Subscription) Topics() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	topics := make([]string, 0, len(s.topics))
+	for topic := range s.topics {
+		topics = append(topics, topic)
+	}
+	return topics
+}
+
+// Close ends the subscription. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closeLocked()
+}
+
+func (s *Subscription) closeLocked() {
+	if s.closed {
+		return
+	}
+	s.closed = true
+	close(s.done)
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	for topic := range s.topics {
+		s.hub.removeLocked(topic, s)
+	}
+}
+
+func (h *Hub) removeLocked(topic string, s *Subscription) {
+	delete(h.topics[topic], s)
+	if len(h.topics[topic]) == 0 {
+		delete(h.topics, topic)
+	}
+}
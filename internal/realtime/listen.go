@@ -0,0 +1,58 @@
+package realtime
+
+import (
+	"context"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	minReconnectInterval = time.Second
+	maxReconnectInterval = time.Minute
+	// listenPingInterval is how long Listen waits without a notification
+	// before checking that its connection is still alive.
+	listenPingInterval = 90 * time.Second
+)
+
+// Listen passes each Postgres notification on channels to handle until ctx
+// is done. The connection is re-established whenever it is lost, and
+// notifications sent in the meantime are lost with it; handle is called
+// with an empty channel after each reconnect so callers can resynchronise.
+// Connection problems are passed to onError, which may be nil. Listen blocks,
+// so it is usually run in its own goroutine.
+func Listen(ctx context.Context, dsn string, channels []string, handle func(channel, payload string), onError func(error)) {
+	report := func(err error) {
+		if err != nil && onError != nil {
+			onError(err)
+		}
+	}
+	listener := pq.NewListener(dsn, minReconnectInterval, maxReconnectInterval, func(_ pq.ListenerEventType, err error) {
+		report(err)
+	})
+	defer listener.Close()
+
+	go func() {
+		// Listen blocks until the first connection succeeds.
+		for _, channel := range channels {
+			report(listener.Listen(channel))
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case n := <-listener.Notify:
+			if n == nil {
+				handle("", "")
+				continue
+			}
+			handle(n.Channel, n.Extra)
+		case <-time.After(listenPingInterval):
+			go func() {
+				report(listener.Ping())
+			}()
+		}
+	}
+}
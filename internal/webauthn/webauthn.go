@@ -0,0 +1,322 @@
+// Package webauthn implements the server side of WebAuthn registration and
+// assertion ceremonies for passkeys. Only "none" attestation is accepted:
+// Chirpy trusts the key the user registers, not the authenticator model.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttestedData = 0x40
+
+	coseAlgES256 = -7
+	coseAlgEdDSA = -8
+	coseAlgRS256 = -257
+)
+
+var (
+	ErrChallengeMismatch    = errors.New("challenge does not match")
+	ErrOriginMismatch       = errors.New("origin is not allowed")
+	ErrRelyingPartyMismatch = errors.New("relying party id hash does not match")
+	ErrUserNotPresent       = errors.New("user presence flag not set")
+	ErrUserNotVerified      = errors.New("user verification flag not set")
+	ErrBadSignature         = errors.New("signature does not verify")
+	// ErrSignCountRegressed means the authenticator's counter did not move
+	// forward, which suggests the credential was cloned.
+	ErrSignCountRegressed = errors.New("signature counter did not increase")
+)
+
+// SupportedAlgorithms are the COSE algorithms offered in
+// pubKeyCredParams, in order of preference.
+var SupportedAlgorithms = []int{coseAlgES256, coseAlgEdDSA, coseAlgRS256}
+
+// RelyingParty identifies this server to authenticators.
+type RelyingParty struct {
+	ID      string
+	Name    string
+	Origins []string
+}
+
+// Credential is what needs to be stored after a successful registration.
+type Credential struct {
+	ID        []byte
+	PublicKey []byte
+	SignCount uint32
+	AAGUID    []byte
+}
+
+type clientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+type authenticatorData struct {
+	RPIDHash     []byte
+	Flags        byte
+	SignCount    uint32
+	AAGUID       []byte
+	CredentialID []byte
+	PublicKey    []byte
+}
+
+// NewChallenge returns a random challenge, base64url encoded as it appears in
+// clientDataJSON.
+func NewChallenge() (string, error) {
+	challenge := make([]byte, 32)
+	_, err := rand.Read(challenge)
+	if err != nil {
+		return "", fmt.Errorf("NewChallenge Function: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(challenge), nil
+}
+
+func (rp RelyingParty) verifyClientData(raw []byte, ceremony, challenge string) error {
+	var data clientData
+	err := json.Unmarshal(raw, &data)
+	if err != nil {
+		return fmt.Errorf("invalid clientDataJSON: %w", err)
+	}
+	if data.Type != ceremony {
+		return fmt.Errorf("unexpected client data type %q", data.Type)
+	}
+	if subtle.ConstantTimeCompare([]byte(data.Challenge), []byte(challenge)) != 1 {
+		return ErrChallengeMismatch
+	}
+	for _, origin := range rp.Origins {
+		if data.Origin == origin {
+			return nil
+		}
+	}
+	return ErrOriginMismatch
+}
+
+func (rp RelyingParty) verifyAuthenticatorData(data authenticatorData, requireUV bool) error {
+	expected := sha256.Sum256([]byte(rp.ID))
+	if !bytes.Equal(data.RPIDHash, expected[:]) {
+		return ErrRelyingPartyMismatch
+	}
+	if data.Flags&flagUserPresent == 0 {
+		return ErrUserNotPresent
+	}
+	if requireUV && data.Flags&flagUserVerified == 0 {
+		return ErrUserNotVerified
+	}
+	return nil
+}
+
+func parseAuthenticatorData(raw []byte) (authenticatorData, error) {
+	if len(raw) < 37 {
+		return authenticatorData{}, errors.New("authenticator data too short")
+	}
+	data := authenticatorData{
+		RPIDHash:  raw[:32],
+		Flags:     raw[32],
+		SignCount: binary.BigEndian.Uint32(raw[33:37]),
+	}
+	if data.Flags&flagAttestedData == 0 {
+		return data, nil
+	}
+
+	rest := raw[37:]
+	if len(rest) < 18 {
+		return authenticatorData{}, errors.New("attested credential data too short")
+	}
+	data.AAGUID = rest[:16]
+	idLength := int(binary.BigEndian.Uint16(rest[16:18]))
+	rest = rest[18:]
+	if len(rest) < idLength {
+		return authenticatorData{}, errors.New("credential id truncated")
+	}
+	data.CredentialID = rest[:idLength]
+	rest = rest[idLength:]
+	_, used, err := decodeCBOR(rest)
+	if err != nil {
+		return authenticatorData{}, fmt.Errorf("invalid credential public key: %w", err)
+	}
+	data.PublicKey = rest[:used]
+	return data, nil
+}
+
+// VerifyRegistration checks the response to navigator.credentials.create()
+// and returns the credential to store.
+func (rp RelyingParty) VerifyRegistration(challenge string, clientDataJSON, attestationObject []byte, requireUV bool) (Credential, error) {
+	err := rp.verifyClientData(clientDataJSON, "webauthn.create", challenge)
+	if err != nil {
+		return Credential{}, err
+	}
+
+	decoded, _, err := decodeCBOR(attestationObject)
+	if err != nil {
+		return Credential{}, fmt.Errorf("invalid attestation object: %w", err)
+	}
+	object, ok := decoded.(map[interface{}]interface{})
+	if !ok {
+		return Credential{}, errors.New("attestation object is not a map")
+	}
+	if format, _ := object["fmt"].(string); format != "none" {
+		return Credential{}, fmt.Errorf("unsupported attestation format %q", format)
+	}
+	rawAuthData, ok := object["authData"].([]byte)
+	if !ok {
+		return Credential{}, errors.New("attestation object has no authData")
+	}
+
+	authData, err := parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return Credential{}, err
+	}
+	err = rp.verifyAuthenticatorData(authData, requireUV)
+	if err != nil {
+		return Credential{}, err
+	}
+	if authData.CredentialID == nil {
+		return Credential{}, errors.New("no attested credential data")
+	}
+	_, err = parseCOSEKey(authData.PublicKey)
+	if err != nil {
+		return Credential{}, err
+	}
+
+	return Credential{
+		ID:        authData.CredentialID,
+		PublicKey: authData.PublicKey,
+		SignCount: authData.SignCount,
+		AAGUID:    authData.AAGUID,
+	}, nil
+}
+
+// VerifyAssertion checks the response to navigator.credentials.get() against
+// a stored credential and returns the new signature counter to store.
+func (rp RelyingParty) VerifyAssertion(challenge string, credential Credential, clientDataJSON, rawAuthData, signature []byte, requireUV bool) (uint32, error) {
+	err := rp.verifyClientData(clientDataJSON, "webauthn.get", challenge)
+	if err != nil {
+		return 0, err
+	}
+	authData, err := parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return 0, err
+	}
+	err = rp.verifyAuthenticatorData(authData, requireUV)
+	if err != nil {
+		return 0, err
+	}
+
+	key, err := parseCOSEKey(credential.PublicKey)
+	if err != nil {
+		return 0, err
+	}
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte(nil), rawAuthData...), clientDataHash[:]...)
+	if !key.verify(signed, signature) {
+		return 0, ErrBadSignature
+	}
+
+	// Authenticators that do not implement a counter always report zero.
+	if (authData.SignCount != 0 || credential.SignCount != 0) && authData.SignCount <= credential.SignCount {
+		return 0, ErrSignCountRegressed
+	}
+	return authData.SignCount, nil
+}
+
+type coseKey struct {
+	alg int64
+	key interface{}
+}
+
+func parseCOSEKey(raw []byte) (coseKey, error) {
+	decoded, _, err := decodeCBOR(raw)
+	if err != nil {
+		return coseKey{}, fmt.Errorf("invalid COSE key: %w", err)
+	}
+	m, ok := decoded.(map[interface{}]interface{})
+	if !ok {
+		return coseKey{}, errors.New("COSE key is not a map")
+	}
+	kty, _ := m[int64(1)].(int64)
+	alg, _ := m[int64(3)].(int64)
+
+	switch {
+	case kty == 2 && alg == coseAlgES256:
+		crv, _ := m[int64(-1)].(int64)
+		x, _ := m[int64(-2)].([]byte)
+		y, _ := m[int64(-3)].([]byte)
+		if crv != 1 || len(x) != 32 || len(y) != 32 {
+			return coseKey{}, errors.New("invalid P-256 key")
+		}
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return coseKey{}, errors.New("P-256 point is not on the curve")
+		}
+		return coseKey{alg: alg, key: pub}, nil
+	case kty == 1 && alg == coseAlgEdDSA:
+		crv, _ := m[int64(-1)].(int64)
+		x, _ := m[int64(-2)].([]byte)
+		if crv != 6 || len(x) != ed25519.PublicKeySize {
+			return coseKey{}, errors.New("invalid Ed25519 key")
+		}
+		return coseKey{alg: alg, key: ed25519.PublicKey(x)}, nil
+	case kty == 3 && alg == coseAlgRS256:
+		n, _ := m[int64(-1)].([]byte)
+		e, _ := m[int64(-2)].([]byte)
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return coseKey{}, errors.New("invalid RSA key")
+		}
+		exponent := int(new(big.Int).SetBytes(e).Int64())
+		return coseKey{alg: alg, key: &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: exponent}}, nil
+	}
+	return coseKey{}, fmt.Errorf("unsupported COSE key type %d / algorithm %d", kty, alg)
+}
+
+func (k coseKey) verify(message, signature []byte) bool {
+	switch pub := k.key.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(message)
+		return ecdsa.VerifyASN1(pub, digest[:], signature)
+	case ed25519.PublicKey:
+		return ed25519.Verify(pub, message, signature)
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(message)
+		return rsa.VerifyPKCS1v15(pub, 5, digest[:], signature) == nil
+	}
+	return false
+}
+
+// Base64URL is binary data that travels as unpadded base64url in JSON, the
+// encoding browsers use for WebAuthn buffers. Padded input is also accepted.
+type Base64URL []byte
+
+func (b Base64URL) MarshalJSON() ([]byte, error) {
+	return json.Marshal(base64.RawURLEncoding.EncodeToString(b))
+}
+
+func (b *Base64URL) UnmarshalJSON(data []byte) error {
+	var s string
+	err := json.Unmarshal(data, &s)
+	if err != nil {
+		return err
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return err
+	}
+	*b = decoded
+	return nil
+}
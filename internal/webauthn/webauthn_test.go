@@ -0,0 +1,226 @@
+package webauthn
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"sort"
+	"testing"
+)
+
+var testRP = RelyingParty{ID: "chirpy.test", Name: "Chirpy", Origins: []string{"https://chirpy.test"}}
+
+// softAuthenticator is a minimal CTAP2-like authenticator holding a single
+// P-256 credential in memory.
+type softAuthenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	signCount    uint32
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := make([]byte, 16)
+	rand.Read(id)
+	return &softAuthenticator{key: key, credentialID: id}
+}
+
+func (a *softAuthenticator) clientData(typ, challenge, origin string) []byte {
+	data, _ := json.Marshal(clientData{Type: typ, Challenge: challenge, Origin: origin})
+	return data
+}
+
+func (a *softAuthenticator) authData(rpID string, flags byte, attested bool) []byte {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	data := append([]byte(nil), rpIDHash[:]...)
+	if attested {
+		flags |= flagAttestedData
+	}
+	data = append(data, flags)
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+	if attested {
+		data = append(data, make([]byte, 16)...)
+		data = binary.BigEndian.AppendUint16(data, uint16(len(a.credentialID)))
+		data = append(data, a.credentialID...)
+		x := make([]byte, 32)
+		y := make([]byte, 32)
+		a.key.X.FillBytes(x)
+		a.key.Y.FillBytes(y)
+		data = append(data, encodeCBOR(map[interface{}]interface{}{
+			int64(1): int64(2), int64(3): int64(coseAlgES256),
+			int64(-1): int64(1), int64(-2): x, int64(-3): y,
+		})...)
+	}
+	return data
+}
+
+func (a *softAuthenticator) create(challenge, origin string) (clientDataJSON, attestationObject []byte) {
+	clientDataJSON = a.clientData("webauthn.create", challenge, origin)
+	attestationObject = encodeCBOR(map[interface{}]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[interface{}]interface{}{},
+		"authData": a.authData(testRP.ID, flagUserPresent|flagUserVerified, true),
+	})
+	return clientDataJSON, attestationObject
+}
+
+func (a *softAuthenticator) get(t *testing.T, challenge, origin string) (clientDataJSON, authData, signature []byte) {
+	a.signCount++
+	clientDataJSON = a.clientData("webauthn.get", challenge, origin)
+	authData = a.authData(testRP.ID, flagUserPresent|flagUserVerified, false)
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(append(append([]byte(nil), authData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return clientDataJSON, authData, signature
+}
+
+func encodeCBOR(v interface{}) []byte {
+	header := func(major byte, n uint64) []byte {
+		switch {
+		case n < 24:
+			return []byte{major<<5 | byte(n)}
+		case n < 1<<8:
+			return []byte{major<<5 | 24, byte(n)}
+		default:
+			return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(n))
+		}
+	}
+	switch v := v.(type) {
+	case int64:
+		if v < 0 {
+			return header(1, uint64(-1-v))
+		}
+		return header(0, uint64(v))
+	case []byte:
+		return append(header(2, uint64(len(v))), v...)
+	case string:
+		return append(header(3, uint64(len(v))), v...)
+	case map[interface{}]interface{}:
+		var entries [][]byte
+		for key, value := range v {
+			entries = append(entries, append(encodeCBOR(key), encodeCBOR(value)...))
+		}
+		sort.Slice(entries, func(i, j int) bool { return string(entries[i]) < string(entries[j]) })
+		out := header(5, uint64(len(v)))
+		for _, entry := range entries {
+			out = append(out, entry...)
+		}
+		return out
+	}
+	panic("unsupported CBOR value")
+}
+
+func register(t *testing.T, a *softAuthenticator) Credential {
+	challenge, err := NewChallenge()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientDataJSON, attestationObject := a.create(challenge, "https://chirpy.test")
+	credential, err := testRP.VerifyRegistration(challenge, clientDataJSON, attestationObject, true)
+	if err != nil {
+		t.Fatalf("VerifyRegistration: %v", err)
+	}
+	return credential
+}
+
+func TestRegisterAndLogin(t *testing.T) {
+	a := newSoftAuthenticator(t)
+	credential := register(t, a)
+	if string(credential.ID) != string(a.credentialID) {
+		t.Fatalf("credential id = %x, want %x", credential.ID, a.credentialID)
+	}
+
+	for i := 0; i < 2; i++ {
+		challenge, _ := NewChallenge()
+		clientDataJSON, authData, signature := a.get(t, challenge, "https://chirpy.test")
+		signCount, err := testRP.VerifyAssertion(challenge, credential, clientDataJSON, authData, signature, true)
+		if err != nil {
+			t.Fatalf("VerifyAssertion: %v", err)
+		}
+		if signCount != a.signCount {
+			t.Fatalf("sign count = %d, want %d", signCount, a.signCount)
+		}
+		credential.SignCount = signCount
+	}
+}
+
+func TestLoginRejectsSignCountRegression(t *testing.T) {
+	a := newSoftAuthenticator(t)
+	credential := register(t, a)
+	credential.SignCount = 5
+
+	challenge, _ := NewChallenge()
+	clientDataJSON, authData, signature := a.get(t, challenge, "https://chirpy.test")
+	_, err := testRP.VerifyAssertion(challenge, credential, clientDataJSON, authData, signature, false)
+	if !errors.Is(err, ErrSignCountRegressed) {
+		t.Fatalf("err = %v, want ErrSignCountRegressed", err)
+	}
+}
+
+func TestLoginRejectsWrongChallengeOriginAndSignature(t *testing.T) {
+	a := newSoftAuthenticator(t)
+	credential := register(t, a)
+	challenge, _ := NewChallenge()
+	other, _ := NewChallenge()
+
+	clientDataJSON, authData, signature := a.get(t, other, "https://chirpy.test")
+	_, err := testRP.VerifyAssertion(challenge, credential, clientDataJSON, authData, signature, false)
+	if !errors.Is(err, ErrChallengeMismatch) {
+		t.Errorf("wrong challenge: err = %v", err)
+	}
+
+	clientDataJSON, authData, signature = a.get(t, challenge, "https://evil.test")
+	_, err = testRP.VerifyAssertion(challenge, credential, clientDataJSON, authData, signature, false)
+	if !errors.Is(err, ErrOriginMismatch) {
+		t.Errorf("wrong origin: err = %v", err)
+	}
+
+	clientDataJSON, authData, signature = a.get(t, challenge, "https://chirpy.test")
+	signature[len(signature)-1] ^= 0xff
+	_, err = testRP.VerifyAssertion(challenge, credential, clientDataJSON, authData, signature, false)
+	if !errors.Is(err, ErrBadSignature) {
+		t.Errorf("tampered signature: err = %v", err)
+	}
+
+	otherRP := testRP
+	otherRP.ID = "evil.test"
+	clientDataJSON, authData, signature = a.get(t, challenge, "https://chirpy.test")
+	_, err = otherRP.VerifyAssertion(challenge, credential, clientDataJSON, authData, signature, false)
+	if !errors.Is(err, ErrRelyingPartyMismatch) {
+		t.Errorf("wrong rp id: err = %v", err)
+	}
+}
+
+func TestRegistrationRejectsAttestationFormats(t *testing.T) {
+	a := newSoftAuthenticator(t)
+	challenge, _ := NewChallenge()
+	clientDataJSON := a.clientData("webauthn.create", challenge, "https://chirpy.test")
+	attestationObject := encodeCBOR(map[interface{}]interface{}{
+		"fmt":      "packed",
+		"attStmt":  map[interface{}]interface{}{},
+		"authData": a.authData(testRP.ID, flagUserPresent, true),
+	})
+	_, err := testRP.VerifyRegistration(challenge, clientDataJSON, attestationObject, false)
+	if err == nil {
+		t.Fatal("packed attestation was accepted")
+	}
+}
+
+func TestDecodeCBORRejectsTruncatedInput(t *testing.T) {
+	data := encodeCBOR(map[interface{}]interface{}{"authData": make([]byte, 40)})
+	for i := 0; i < len(data); i++ {
+		if _, _, err := decodeCBOR(data[:i]); err == nil {
+			t.Fatalf("truncated input of %d bytes decoded", i)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+// Package webhooks signs and sends webhook deliveries. Queueing and retries
+// are left to the caller, which decides when to call Send again using
+// Backoff.
+//
+// Each delivery is a POST of a JSON event with a Chirpy-Signature header of
+// the form "t=<unix seconds>,v1=<hex HMAC-SHA256>", where the HMAC is keyed
+// with the endpoint's secret and covers "<t>.<body>". Receivers should
+// recompute it and reject timestamps more than a few minutes old, which
+// stops replays.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	SignatureHeader = "Chirpy-Signature"
+	EventHeader     = "Chirpy-Event"
+	DeliveryHeader  = "Chirpy-Delivery"
+
+	userAgent = "Chirpy-Webhooks/1.0"
+	// maxResponseBody is how much of a response is kept for the delivery
+	// log.
+	maxResponseBody = 1024
+	requestTimeout  = 10 * time.Second
+)
+
+// Sign returns the Chirpy-Signature header value for body sent at t.
+func Sign(secret string, t time.Time, body []byte) string {
+	timestamp := strconv.FormatInt(t.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "t=" + timestamp + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Backoff returns how long to wait before retrying after attempt failures:
+// base doubled for each earlier failure, capped at limit, and randomised by up
+// to a fifth either way so that endpoints recovering from an outage are not
+// hit by every retry at once.
+func Backoff(attempt int, base, limit time.Duration) time.Duration {
+	d := base
+	for i := 1; i < attempt && d < limit; i++ {
+		d *= 2
+	}
+	d = min(d, limit)
+	jitter := time.Duration(rand.Int64N(int64(d)/5*2+1)) - d/5
+	return d + jitter
+}
+
+// ValidateURL checks that rawURL can be used as an endpoint: an absolute
+// https URL, or http when insecure is set, without credentials in it.
+func ValidateURL(rawURL string, insecure bool) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "https" && !(insecure && u.Scheme == "http") {
+		return errors.New("webhook URLs must use https")
+	}
+	if u.Host == "" {
+		return errors.New("webhook URL has no host")
+	}
+	if u.User != nil {
+		return errors.New("webhook URLs cannot contain credentials")
+	}
+	return nil
+}
+
+var errForbiddenAddress = errors.New("webhook endpoint resolves to a private address")
+
+// NewClient returns a client for sending deliveries. Unless allowPrivate is
+// set it refuses to connect to loopback, private and link-local addresses,
+// checked after DNS resolution, so endpoints cannot be pointed at internal
+// services. Redirects are not followed.
+func NewClient(allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: requestTimeout}
+	if !allowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || !ip.IsGlobalUnicast() || ip.IsPrivate() || ip.IsLoopback() {
+				return errForbiddenAddress
+			}
+			return nil
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	transport.Proxy = nil
+	return &http.Client{
+		Timeout:   requestTimeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// Result is what an endpoint answered. StatusCode is zero when no response
+// was received.
+type Result struct {
+	StatusCode int
+	Body       string
+}
+
+// OK reports whether the endpoint accepted the delivery.
+func (r Result) OK() bool {
+	return r.StatusCode >= 200 && r.StatusCode < 300
+}
+
+// Send posts body to endpoint, signed with secret. A non-2xx response is not
+// an error; check Result.OK.
+func Send(ctx context.Context, client *http.Client, endpoint, secret, eventType, deliveryID string, body []byte) (Result, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return Result{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set(EventHeader, eventType)
+	req.Header.Set(DeliveryHeader, deliveryID)
+	req.Header.Set(SignatureHeader, Sign(secret, time.Now(), body))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return Result{}, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	if err != nil {
+		return Result{StatusCode: resp.StatusCode}, fmt.Errorf("reading response: %w", err)
+	}
+	return Result{StatusCode: resp.StatusCode, Body: string(data)}, nil
+}
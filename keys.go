@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ablanchetMD/chirpy/internal/auth"
+)
+
+// loadKeyRing builds the access token key ring from the environment.
+//
+// JWT_SIGNING_ALG selects HS256 (the default, using JWT_SECRET), RS256 or
+// EdDSA. For the asymmetric algorithms JWT_SIGNING_KEYS lists PEM files: the
+// first one signs, the others are previous keys that keep verifying tokens
+// for JWT_KEY_OVERLAP after startup. Without key files an ephemeral key is
+// generated, which only works for a single instance.
+func loadKeyRing(c *apiConfig) (*auth.KeyRing, error) {
+	alg := os.Getenv("JWT_SIGNING_ALG")
+	if alg == "" || alg == "HS256" {
+		return auth.NewHMACKeyRing(c.JWTSecret)
+	}
+
+	var paths []string
+	for _, path := range strings.Split(os.Getenv("JWT_SIGNING_KEYS"), ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			paths = append(paths, path)
+		}
+	}
+	if len(paths) == 0 {
+		fmt.Println("No JWT_SIGNING_KEYS configured, generating an ephemeral ", alg, " key")
+		key, err := auth.GenerateSigningKey(alg)
+		if err != nil {
+			return nil, err
+		}
+		return auth.NewKeyRing(key), nil
+	}
+
+	var ring *auth.KeyRing
+	for i, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading signing key %s: %w", path, err)
+		}
+		key, err := auth.ParseSigningKeyPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("parsing signing key %s: %w", path, err)
+		}
+		if key.Method.Alg() != alg {
+			return nil, fmt.Errorf("signing key %s is %s, expected %s", path, key.Method.Alg(), alg)
+		}
+		if i == 0 {
+			ring = auth.NewKeyRing(key)
+			continue
+		}
+		ring.AddVerificationKey(key, time.Now().Add(c.KeyOverlap))
+	}
+	return ring, nil
+}
+
+func handleJWKS(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	respondWithJSON(w, http.StatusOK, c.Keys.JWKS())
+}
+
+// handleRotateKeys switches this instance to a freshly generated signing key.
+// Fleets should rotate by deploying a new JWT_SIGNING_KEYS list instead, so
+// every instance agrees on the active key.
+func handleRotateKeys(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	if !isAdmin(c, r) {
+		respondWithError(w, http.StatusForbidden, "You are not authorized to use this function.")
+		return
+	}
+	alg := os.Getenv("JWT_SIGNING_ALG")
+	if alg == "" || alg == "HS256" {
+		respondWithError(w, http.StatusBadRequest, "Key rotation requires an asymmetric JWT_SIGNING_ALG")
+		return
+	}
+
+	key, err := auth.GenerateSigningKey(alg)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error generating signing key")
+		return
+	}
+	c.Keys.Rotate(key, c.KeyOverlap)
+	respondWithJSON(w, http.StatusOK, map[string]string{"kid": key.ID})
+}
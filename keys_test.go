@@ -0,0 +1,20 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadKeyRingRefusesWeakHMACSecret(t *testing.T) {
+	for _, alg := range []string{"", "HS256"} {
+		t.Setenv("JWT_SIGNING_ALG", alg)
+		for _, secret := range []string{"", "too-short", strings.Repeat("x", 31)} {
+			if _, err := loadKeyRing(&apiConfig{JWTSecret: secret}); err == nil {
+				t.Errorf("alg %q: %d byte secret accepted", alg, len(secret))
+			}
+		}
+		if _, err := loadKeyRing(&apiConfig{JWTSecret: strings.Repeat("x", 32)}); err != nil {
+			t.Errorf("alg %q: %v", alg, err)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/ablanchetMD/chirpy/internal/auth"
+	"github.com/ablanchetMD/chirpy/internal/database"
+	"github.com/ablanchetMD/chirpy/internal/lockout"
+	"github.com/ablanchetMD/chirpy/internal/mailer"
+)
+
+const magicLinkExpiry = 15 * time.Minute
+
+// newMailer picks the mail backend from MAILER: "smtp" (SMTP_ADDR,
+// SMTP_USERNAME, SMTP_PASSWORD, MAIL_FROM), "file" (MAILER_DIR) or "log",
+// the default. The file and log mailers expose live login links, so they are
+// only allowed on the dev platform.
+func newMailer(platform string) (mailer.Mailer, error) {
+	backend := os.Getenv("MAILER")
+	switch backend {
+	case "smtp":
+		return mailer.NewSMTPMailer(
+			os.Getenv("SMTP_ADDR"),
+			os.Getenv("MAIL_FROM"),
+			os.Getenv("SMTP_USERNAME"),
+			os.Getenv("SMTP_PASSWORD"),
+		), nil
+	case "file":
+		dir := os.Getenv("MAILER_DIR")
+		if dir == "" {
+			dir = "mail"
+		}
+		if platform != "dev" {
+			break
+		}
+		return mailer.NewFileMailer(dir)
+	case "", "log":
+		if platform != "dev" {
+			break
+		}
+		return mailer.LogMailer{}, nil
+	default:
+		return nil, fmt.Errorf("unknown MAILER %q", backend)
+	}
+	return nil, fmt.Errorf("MAILER must be smtp outside the dev platform")
+}
+
+// newMagicLinkLimiter throttles link requests. Every request counts, whether
+// or not the account exists, so the limit itself reveals nothing.
+func newMagicLinkLimiter(store lockout.Store) *lockout.Limiter {
+	limiter := lockout.NewLimiter(store)
+	limiter.Namespace = "magic:"
+	limiter.AccountPolicy = lockout.Policy{
+		Threshold:  3,
+		BaseDelay:  time.Minute,
+		MaxDelay:   time.Hour,
+		ResetAfter: time.Hour,
+	}
+	limiter.AddressPolicy = lockout.Policy{
+		Threshold:  10,
+		BaseDelay:  time.Minute,
+		MaxDelay:   time.Hour,
+		ResetAfter: time.Hour,
+	}
+	return limiter
+}
+
+// handleRequestMagicLink emails a single-use login link. The response is the
+// same whether or not the address belongs to an account.
+func handleRequestMagicLink(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	requestData, err := decodeStringFields(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	email, err := auth.NormalizeEmail(requestData["email"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid email field")
+		return
+	}
+
+	ip := clientIP(c, r)
+	wait, _, err := c.MagicLinkLimiter.Attempt(r.Context(), email, ip)
+	if err != nil {
+		fmt.Println("Error checking magic link requests: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error sending login link")
+		return
+	}
+	if wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		respondWithError(w, http.StatusTooManyRequests, "Too many login link requests, try again later.")
+		return
+	}
+	// Sending happens in the background so the response time does not
+	// reveal whether an account was found either.
+	go func(ctx context.Context) {
+		err := sendMagicLink(ctx, c, email)
+		if err != nil {
+			fmt.Println("Error sending magic link: ", err)
+		}
+	}(context.WithoutCancel(r.Context()))
+	respondWithJSON(w, http.StatusAccepted, "If that address has an account, a login link is on its way.")
+}
+
+func sendMagicLink(ctx context.Context, c *apiConfig, email string) error {
+	user, err := c.Db.GetUserByEmail(ctx, email)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	link, err := c.Db.CreateMagicLink(ctx, database.CreateMagicLinkParams{
+		CreatedAt: now,
+		UserID:    user.ID,
+		ExpiresAt: now.Add(magicLinkExpiry),
+	})
+	if err != nil {
+		return err
+	}
+	token, err := auth.MakeMagicLinkToken(link.ID, user.ID, c.JWTSecret, magicLinkExpiry)
+	if err != nil {
+		return err
+	}
+
+	// The link opens a page that POSTs the token, so mail scanners that
+	// prefetch links cannot burn it.
+	loginURL := c.BaseURL + "/app/magic-login.html?token=" + url.QueryEscape(token)
+	return c.Mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Your Chirpy login link",
+		Body:    "Open this link within 15 minutes to log in to Chirpy:\n\n" + loginURL + "\n\nIf you did not ask for it, you can ignore this email.\n",
+	})
+}
+
+// handleVerifyMagicLink exchanges a magic link token for the same tokens (or
+// cookie session) a password login produces. Accounts with two-factor
+// authentication still get an MFA challenge.
+func handleVerifyMagicLink(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	requestData, err := decodeStringFields(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	linkID, userID, err := auth.ValidateMagicLinkToken(requestData["token"], c.JWTSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid or expired login link")
+		return
+	}
+	now := time.Now()
+	link, err := c.Db.UseMagicLink(r.Context(), database.UseMagicLinkParams{
+		ID:     linkID,
+		UsedAt: sql.NullTime{Time: now, Valid: true},
+	})
+	if err != nil || link.UserID != userID {
+		respondWithError(w, http.StatusUnauthorized, "Invalid or expired login link")
+		return
+	}
+	user, err := c.Db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid or expired login link")
+		return
+	}
+
+	// Following the link proves the user controls the address.
+	if !user.EmailVerifiedAt.Valid {
+		user, err = c.Db.VerifyUserEmail(r.Context(), database.VerifyUserEmailParams{
+			ID:              user.ID,
+			Email:           user.Email,
+			EmailVerifiedAt: sql.NullTime{Time: now, Valid: true},
+		})
+		if err != nil {
+			fmt.Println("Error verifying email: ", err)
+			respondWithError(w, http.StatusInternalServerError, "Error logging in")
+			return
+		}
+	}
+
+	if user.TotpEnabledAt.Valid {
+		mfaToken, err := auth.MakeMFAToken(user.ID, c.JWTSecret, mfaChallengeExpiry)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error creating MFA challenge")
+			return
+		}
+		respondWithJSON(w, http.StatusOK, MFAChallenge{MFARequired: true, MFAToken: mfaToken})
+		return
+	}
+	completeLogin(c, w, r, user)
+}
@@ -1,21 +1,50 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"sync/atomic"
+	"time"
+	"github.com/ablanchetMD/chirpy/internal/auth"
 	"github.com/ablanchetMD/chirpy/internal/database"
+	"github.com/ablanchetMD/chirpy/internal/lockout"
+	"github.com/ablanchetMD/chirpy/internal/mailer"
+	"github.com/ablanchetMD/chirpy/internal/realtime"
+	"github.com/ablanchetMD/chirpy/internal/webauthn"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 )
 
 type apiConfig struct {
 	Db *database.Queries
+	DBPool *sql.DB
 	Platform string
+	JWTSecret string
+	Keys *auth.KeyRing
+	KeyOverlap time.Duration
+	BaseURL string
+	RequireEmailVerification bool
+	AdminToken string
+	TrustProxy bool
+	LoginLimiter *lockout.Limiter
+	MagicLinkLimiter *lockout.Limiter
+	Mailer mailer.Mailer
+	WebAuthn webauthn.RelyingParty
+	PasswordPolicy auth.PasswordPolicy
+	FanoutThreshold int
+	Reactions map[string]bool
+	SearchLanguage string
+	Trending TrendingConfig
+	Hub *realtime.Hub
+	PublicChirps *publicChirpCache
+	Webhooks WebhookConfig
 	fileserverHits uint64
+	failedLogins uint64
+	loginLockouts uint64
 }
 
 func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
@@ -52,7 +81,63 @@ func main() {
 	defer db.Close()
 	dbQueries := database.New(db)
 	cfg.Db = dbQueries
+	cfg.DBPool = db
 	cfg.Platform = os.Getenv("PLATFORM")
+	cfg.JWTSecret = os.Getenv("JWT_SECRET")
+	// Email verification, MFA and magic link tokens are all signed with it.
+	if err := auth.CheckSecret(cfg.JWTSecret); err != nil {
+		fmt.Println("Error configuring JWT_SECRET: ", err)
+		return
+	}
+	cfg.BaseURL = os.Getenv("BASE_URL")
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = "http://localhost:8080"
+	}
+	cfg.RequireEmailVerification = os.Getenv("REQUIRE_EMAIL_VERIFICATION") == "true"
+	cfg.KeyOverlap = accessTokenExpiry
+	if overlap, err := time.ParseDuration(os.Getenv("JWT_KEY_OVERLAP")); err == nil {
+		cfg.KeyOverlap = overlap
+	}
+	cfg.Keys, err = loadKeyRing(cfg)
+	if err != nil {
+		fmt.Println("Error loading signing keys: ", err)
+		return
+	}
+	cfg.AdminToken = os.Getenv("ADMIN_TOKEN")
+	cfg.TrustProxy = os.Getenv("TRUST_PROXY") == "true"
+	var attemptStore lockout.Store = lockout.NewMemoryStore()
+	if os.Getenv("LOGIN_ATTEMPT_STORE") == "postgres" {
+		attemptStore = lockout.NewPostgresStore(dbQueries)
+	}
+	cfg.LoginLimiter = lockout.NewLimiter(attemptStore)
+	cfg.MagicLinkLimiter = newMagicLinkLimiter(attemptStore)
+	startLoginPruning(cfg, context.Background())
+	cfg.Mailer, err = newMailer(cfg.Platform)
+	if err != nil {
+		fmt.Println("Error configuring mailer: ", err)
+		return
+	}
+	cfg.PasswordPolicy = newPasswordPolicy()
+	cfg.FanoutThreshold = newFanoutThreshold()
+	cfg.Reactions = newReactionSet()
+	cfg.SearchLanguage = newSearchLanguage()
+	if err := checkSearchLanguage(cfg); err != nil {
+		fmt.Println("Error configuring search: ", err)
+		return
+	}
+	cfg.Trending = newTrendingConfig()
+	startTrendingJob(cfg, context.Background())
+	startHashtagBackfill(cfg, context.Background())
+	cfg.Hub = realtime.NewHub()
+	cfg.PublicChirps = newPublicChirpCache()
+	startRealtime(cfg, context.Background(), os.Getenv("DB_URL"))
+	cfg.Webhooks = newWebhookConfig(cfg.Platform)
+	startWebhookWorker(cfg, context.Background())
+	cfg.WebAuthn, err = newRelyingParty(cfg.BaseURL)
+	if err != nil {
+		fmt.Println("Error configuring WebAuthn: ", err)
+		return
+	}
 
 	mux := http.NewServeMux()
 	fileserver := http.FileServer(http.Dir("."))
@@ -69,6 +154,80 @@ func main() {
 		handleCreateUser(cfg, w, r)
 	})
 
+	mux.HandleFunc("PUT /api/users", cfg.middlewareAuth(auth.ScopeAccount, func(w http.ResponseWriter, r *http.Request) {
+		handleUpdateProfile(cfg, w, r)
+	}))
+
+	mux.HandleFunc("GET /api/users/{handle}", func(w http.ResponseWriter, r *http.Request) {
+		handleGetProfile(cfg, w, r)
+	})
+
+	mux.HandleFunc("POST /api/follows/{handle}", cfg.middlewareAuth(auth.ScopeAccount, func(w http.ResponseWriter, r *http.Request) {
+		handleFollow(cfg, w, r)
+	}))
+
+	mux.HandleFunc("DELETE /api/follows/{handle}", cfg.middlewareAuth(auth.ScopeAccount, func(w http.ResponseWriter, r *http.Request) {
+		handleUnfollow(cfg, w, r)
+	}))
+
+	mux.HandleFunc("GET /api/users/{handle}/followers", func(w http.ResponseWriter, r *http.Request) {
+		handleListFollowers(cfg, w, r)
+	})
+
+	mux.HandleFunc("GET /api/users/{handle}/following", func(w http.ResponseWriter, r *http.Request) {
+		handleListFollowing(cfg, w, r)
+	})
+
+	mux.HandleFunc("GET /api/notifications", cfg.middlewareAuth(auth.ScopeAccount, func(w http.ResponseWriter, r *http.Request) {
+		handleListNotifications(cfg, w, r)
+	}))
+	mux.HandleFunc("GET /api/notifications/unread-count", cfg.middlewareAuth(auth.ScopeAccount, func(w http.ResponseWriter, r *http.Request) {
+		handleUnreadNotificationCount(cfg, w, r)
+	}))
+	mux.HandleFunc("POST /api/notifications/read", cfg.middlewareAuth(auth.ScopeAccount, func(w http.ResponseWriter, r *http.Request) {
+		handleMarkAllNotificationsRead(cfg, w, r)
+	}))
+	mux.HandleFunc("POST /api/notifications/{notificationID}/read", cfg.middlewareAuth(auth.ScopeAccount, func(w http.ResponseWriter, r *http.Request) {
+		handleMarkNotificationRead(cfg, w, r)
+	}))
+	mux.HandleFunc("GET /api/notifications/preferences", cfg.middlewareAuth(auth.ScopeAccount, func(w http.ResponseWriter, r *http.Request) {
+		handleGetNotificationPreferences(cfg, w, r)
+	}))
+	mux.HandleFunc("PUT /api/notifications/preferences", cfg.middlewareAuth(auth.ScopeAccount, func(w http.ResponseWriter, r *http.Request) {
+		handleUpdateNotificationPreferences(cfg, w, r)
+	}))
+	mux.HandleFunc("GET /api/follow-requests", cfg.middlewareAuth(auth.ScopeAccount, func(w http.ResponseWriter, r *http.Request) {
+		handleListFollowRequests(cfg, w, r)
+	}))
+
+	mux.HandleFunc("POST /api/follow-requests/{handle}/approve", cfg.middlewareAuth(auth.ScopeAccount, func(w http.ResponseWriter, r *http.Request) {
+		handleApproveFollowRequest(cfg, w, r)
+	}))
+
+	mux.HandleFunc("POST /api/follow-requests/{handle}/deny", cfg.middlewareAuth(auth.ScopeAccount, func(w http.ResponseWriter, r *http.Request) {
+		handleDenyFollowRequest(cfg, w, r)
+	}))
+
+	mux.HandleFunc("PUT /api/users/password", cfg.middlewareAuth(auth.ScopeAccount, func(w http.ResponseWriter, r *http.Request) {
+		handleChangePassword(cfg, w, r)
+	}))
+
+	mux.HandleFunc("GET /api/users/verify", func(w http.ResponseWriter, r *http.Request) {
+		handleVerifyEmail(cfg, w, r)
+	})
+
+	mux.HandleFunc("POST /api/keys", cfg.middlewareAuth(auth.ScopeAccount, func(w http.ResponseWriter, r *http.Request) {
+		handleCreateAPIKey(cfg, w, r)
+	}))
+
+	mux.HandleFunc("GET /api/keys", cfg.middlewareAuth(auth.ScopeAccount, func(w http.ResponseWriter, r *http.Request) {
+		handleListAPIKeys(cfg, w, r)
+	}))
+
+	mux.HandleFunc("DELETE /api/keys/{keyID}", cfg.middlewareAuth(auth.ScopeAccount, func(w http.ResponseWriter, r *http.Request) {
+		handleRevokeAPIKey(cfg, w, r)
+	}))
+
 	mux.HandleFunc("POST /admin/reset", func(w http.ResponseWriter, r *http.Request) {
 		handleReset(cfg, w, r)
 	})
@@ -78,6 +237,110 @@ func main() {
 	})
 	// mux.HandleFunc("POST /api/login", db.handleLogin)handleLogin
 
+	mux.HandleFunc("POST /api/logout", cfg.middlewareAuth(auth.ScopeAccount, func(w http.ResponseWriter, r *http.Request) {
+		handleLogout(cfg, w, r)
+	}))
+
+	mux.HandleFunc("POST /api/refresh", func(w http.ResponseWriter, r *http.Request) {
+		handleRefresh(cfg, w, r)
+	})
+
+	mux.HandleFunc("POST /api/revoke", func(w http.ResponseWriter, r *http.Request) {
+		handleRevoke(cfg, w, r)
+	})
+
+	mux.HandleFunc("GET /api/sessions", cfg.middlewareAuth(auth.ScopeAccount, func(w http.ResponseWriter, r *http.Request) {
+		handleListSessions(cfg, w, r)
+	}))
+
+	mux.HandleFunc("DELETE /api/sessions/{sessionID}", cfg.middlewareAuth(auth.ScopeAccount, func(w http.ResponseWriter, r *http.Request) {
+		handleDeleteSession(cfg, w, r)
+	}))
+
+	mux.HandleFunc("POST /api/sessions/logout-all", cfg.middlewareAuth(auth.ScopeAccount, func(w http.ResponseWriter, r *http.Request) {
+		handleLogoutAll(cfg, w, r)
+	}))
+
+	mux.HandleFunc("POST /api/login/magic", func(w http.ResponseWriter, r *http.Request) {
+		handleRequestMagicLink(cfg, w, r)
+	})
+
+	mux.HandleFunc("POST /api/login/magic/verify", func(w http.ResponseWriter, r *http.Request) {
+		handleVerifyMagicLink(cfg, w, r)
+	})
+
+	mux.HandleFunc("POST /api/login/passkey/begin", func(w http.ResponseWriter, r *http.Request) {
+		handleBeginPasskeyLogin(cfg, w, r)
+	})
+
+	mux.HandleFunc("POST /api/login/passkey/finish", func(w http.ResponseWriter, r *http.Request) {
+		handleFinishPasskeyLogin(cfg, w, r)
+	})
+
+	mux.HandleFunc("POST /api/users/passkeys/begin", cfg.middlewareAuth(auth.ScopeAccount, func(w http.ResponseWriter, r *http.Request) {
+		handleBeginPasskeyRegistration(cfg, w, r)
+	}))
+
+	mux.HandleFunc("POST /api/users/passkeys/finish", cfg.middlewareAuth(auth.ScopeAccount, func(w http.ResponseWriter, r *http.Request) {
+		handleFinishPasskeyRegistration(cfg, w, r)
+	}))
+
+	mux.HandleFunc("GET /api/users/passkeys", cfg.middlewareAuth(auth.ScopeAccount, func(w http.ResponseWriter, r *http.Request) {
+		handleListPasskeys(cfg, w, r)
+	}))
+
+	mux.HandleFunc("DELETE /api/users/passkeys/{passkeyID}", cfg.middlewareAuth(auth.ScopeAccount, func(w http.ResponseWriter, r *http.Request) {
+		handleDeletePasskey(cfg, w, r)
+	}))
+
+	mux.HandleFunc("POST /api/login/mfa", func(w http.ResponseWriter, r *http.Request) {
+		handleLoginMFA(cfg, w, r)
+	})
+
+	mux.HandleFunc("POST /api/users/mfa/totp", cfg.middlewareAuth(auth.ScopeAccount, func(w http.ResponseWriter, r *http.Request) {
+		handleEnrollTOTP(cfg, w, r)
+	}))
+
+	mux.HandleFunc("POST /api/users/mfa/totp/confirm", cfg.middlewareAuth(auth.ScopeAccount, func(w http.ResponseWriter, r *http.Request) {
+		handleConfirmTOTP(cfg, w, r)
+	}))
+
+	mux.HandleFunc("DELETE /api/users/mfa/totp", cfg.middlewareAuth(auth.ScopeAccount, func(w http.ResponseWriter, r *http.Request) {
+		handleDisableTOTP(cfg, w, r)
+	}))
+
+	mux.HandleFunc("POST /api/oauth/clients", cfg.middlewareAuth(auth.ScopeAccount, func(w http.ResponseWriter, r *http.Request) {
+		handleCreateOAuthClient(cfg, w, r)
+	}))
+
+	mux.HandleFunc("GET /api/oauth/clients", cfg.middlewareAuth(auth.ScopeAccount, func(w http.ResponseWriter, r *http.Request) {
+		handleListOAuthClients(cfg, w, r)
+	}))
+
+	mux.HandleFunc("DELETE /api/oauth/clients/{clientID}", cfg.middlewareAuth(auth.ScopeAccount, func(w http.ResponseWriter, r *http.Request) {
+		handleRevokeOAuthClient(cfg, w, r)
+	}))
+
+	mux.HandleFunc("GET /api/oauth/consent", cfg.middlewareAuth(auth.ScopeAccount, func(w http.ResponseWriter, r *http.Request) {
+		handleGetConsent(cfg, w, r)
+	}))
+
+	mux.HandleFunc("POST /api/oauth/consent", cfg.middlewareAuth(auth.ScopeAccount, func(w http.ResponseWriter, r *http.Request) {
+		handleConsent(cfg, w, r)
+	}))
+
+	mux.HandleFunc("GET /oauth/authorize", func(w http.ResponseWriter, r *http.Request) {
+		handleAuthorize(cfg, w, r)
+	})
+
+	mux.HandleFunc("POST /oauth/token", func(w http.ResponseWriter, r *http.Request) {
+		handleToken(cfg, w, r)
+	})
+
+	mux.HandleFunc("POST /oauth/revoke", func(w http.ResponseWriter, r *http.Request) {
+		handleOAuthRevoke(cfg, w, r)
+	})
+
 	mux.HandleFunc("GET /admin/metrics", func(w http.ResponseWriter, r *http.Request) {
 		data, err := os.ReadFile("./admin/index.html")
 		if err != nil {
@@ -86,16 +349,114 @@ func main() {
 		}
 		w.Header().Set("Content-Type", "text/html")
 		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(fmt.Sprintf(string(data), atomic.LoadUint64(&cfg.fileserverHits))))
+		w.Write([]byte(fmt.Sprintf(string(data),
+			atomic.LoadUint64(&cfg.fileserverHits),
+			atomic.LoadUint64(&cfg.failedLogins),
+			atomic.LoadUint64(&cfg.loginLockouts),
+		)))
 	})
 
-	 mux.HandleFunc("POST /api/chirps", func(w http.ResponseWriter, r *http.Request) {
-		handleCreateChirp(cfg, w, r)
+	mux.HandleFunc("GET /.well-known/jwks.json", func(w http.ResponseWriter, r *http.Request) {
+		handleJWKS(cfg, w, r)
+	})
+
+	mux.HandleFunc("POST /admin/keys/rotate", func(w http.ResponseWriter, r *http.Request) {
+		handleRotateKeys(cfg, w, r)
+	})
+
+	mux.HandleFunc("POST /admin/unlock", func(w http.ResponseWriter, r *http.Request) {
+		handleUnlock(cfg, w, r)
+	})
+
+	mux.HandleFunc("POST /api/webhooks", cfg.middlewareAuth(auth.ScopeAccount, func(w http.ResponseWriter, r *http.Request) {
+		handleCreateWebhook(cfg, w, r)
+	}))
+	mux.HandleFunc("GET /api/webhooks", cfg.middlewareAuth(auth.ScopeAccount, func(w http.ResponseWriter, r *http.Request) {
+		handleListWebhooks(cfg, w, r)
+	}))
+	mux.HandleFunc("PUT /api/webhooks/{webhookID}", cfg.middlewareAuth(auth.ScopeAccount, func(w http.ResponseWriter, r *http.Request) {
+		handleUpdateWebhook(cfg, w, r)
+	}))
+	mux.HandleFunc("DELETE /api/webhooks/{webhookID}", cfg.middlewareAuth(auth.ScopeAccount, func(w http.ResponseWriter, r *http.Request) {
+		handleDeleteWebhook(cfg, w, r)
+	}))
+	mux.HandleFunc("GET /api/webhooks/{webhookID}/deliveries", cfg.middlewareAuth(auth.ScopeAccount, func(w http.ResponseWriter, r *http.Request) {
+		handleListWebhookDeliveries(cfg, w, r)
+	}))
+	mux.HandleFunc("POST /api/webhooks/{webhookID}/deliveries/{deliveryID}/redeliver", cfg.middlewareAuth(auth.ScopeAccount, func(w http.ResponseWriter, r *http.Request) {
+		handleRedeliverWebhook(cfg, w, r)
+	}))
+	mux.HandleFunc("POST /admin/webhooks", func(w http.ResponseWriter, r *http.Request) {
+		handleCreateWebhook(cfg, w, r)
+	})
+	mux.HandleFunc("GET /admin/webhooks", func(w http.ResponseWriter, r *http.Request) {
+		handleListWebhooks(cfg, w, r)
 	})
+	mux.HandleFunc("PUT /admin/webhooks/{webhookID}", func(w http.ResponseWriter, r *http.Request) {
+		handleUpdateWebhook(cfg, w, r)
+	})
+	mux.HandleFunc("DELETE /admin/webhooks/{webhookID}", func(w http.ResponseWriter, r *http.Request) {
+		handleDeleteWebhook(cfg, w, r)
+	})
+	mux.HandleFunc("GET /admin/webhooks/{webhookID}/deliveries", func(w http.ResponseWriter, r *http.Request) {
+		handleListWebhookDeliveries(cfg, w, r)
+	})
+	mux.HandleFunc("POST /admin/webhooks/{webhookID}/deliveries/{deliveryID}/redeliver", func(w http.ResponseWriter, r *http.Request) {
+		handleRedeliverWebhook(cfg, w, r)
+	})
+
+	 mux.HandleFunc("POST /api/chirps", cfg.middlewareAuth(auth.ScopeChirpsWrite, func(w http.ResponseWriter, r *http.Request) {
+		handleCreateChirp(cfg, w, r)
+	}))
 
 	 mux.HandleFunc("GET /api/chirps", func(w http.ResponseWriter, r *http.Request) {
 		handleGetChirps(cfg, w, r)
 	})
+	mux.HandleFunc("GET /api/hashtags/trending", func(w http.ResponseWriter, r *http.Request) {
+		handleTrendingHashtags(cfg, w, r)
+	})
+	mux.HandleFunc("GET /api/hashtags/{tag}/chirps", func(w http.ResponseWriter, r *http.Request) {
+		handleListHashtagChirps(cfg, w, r)
+	})
+	mux.HandleFunc("GET /api/stream", func(w http.ResponseWriter, r *http.Request) {
+		handleStream(cfg, w, r)
+	})
+	mux.HandleFunc("GET /api/ws", tokenFromQuery(cfg.middlewareAuth(auth.ScopeChirpsRead, func(w http.ResponseWriter, r *http.Request) {
+		handleWebSocket(cfg, w, r)
+	})))
+	mux.HandleFunc("GET /api/search/chirps", func(w http.ResponseWriter, r *http.Request) {
+		handleSearchChirps(cfg, w, r)
+	})
+	mux.HandleFunc("GET /api/timeline", cfg.middlewareAuth(auth.ScopeChirpsRead, func(w http.ResponseWriter, r *http.Request) {
+		handleGetTimeline(cfg, w, r)
+	}))
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}", cfg.middlewareAuth(auth.ScopeChirpsWrite, func(w http.ResponseWriter, r *http.Request) {
+		handleDeleteChirp(cfg, w, r)
+	}))
+	mux.HandleFunc("POST /api/chirps/{chirpID}/likes", cfg.middlewareAuth(auth.ScopeChirpsWrite, func(w http.ResponseWriter, r *http.Request) {
+		handleReact(cfg, w, r)
+	}))
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/likes", cfg.middlewareAuth(auth.ScopeChirpsWrite, func(w http.ResponseWriter, r *http.Request) {
+		handleUnreact(cfg, w, r)
+	}))
+	mux.HandleFunc("POST /api/chirps/{chirpID}/rechirps", cfg.middlewareAuth(auth.ScopeChirpsWrite, func(w http.ResponseWriter, r *http.Request) {
+		handleRechirp(cfg, w, r)
+	}))
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/rechirps", cfg.middlewareAuth(auth.ScopeChirpsWrite, func(w http.ResponseWriter, r *http.Request) {
+		handleUndoRechirp(cfg, w, r)
+	}))
+	mux.HandleFunc("POST /api/chirps/{chirpID}/bookmark", cfg.middlewareAuth(auth.ScopeChirpsWrite, func(w http.ResponseWriter, r *http.Request) {
+		handleBookmark(cfg, w, r)
+	}))
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/bookmark", cfg.middlewareAuth(auth.ScopeChirpsWrite, func(w http.ResponseWriter, r *http.Request) {
+		handleDeleteBookmark(cfg, w, r)
+	}))
+	mux.HandleFunc("GET /api/bookmarks", cfg.middlewareAuth(auth.ScopeChirpsRead, func(w http.ResponseWriter, r *http.Request) {
+		handleListBookmarks(cfg, w, r)
+	}))
+	mux.HandleFunc("GET /api/chirps/{chirpID}/thread", func(w http.ResponseWriter, r *http.Request) {
+		handleGetThread(cfg, w, r)
+	})
 	mux.HandleFunc("/api/chirps/{chirpID}", func(w http.ResponseWriter, r *http.Request) {
 		handleGetChirp(cfg, w, r)
 	})
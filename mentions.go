@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ablanchetMD/chirpy/internal/database"
+	"github.com/ablanchetMD/chirpy/internal/entities"
+	"github.com/google/uuid"
+)
+
+// MentionEntity is an @handle in a chirp that names an existing user.
+// Mentions of unknown handles are left as plain text.
+type MentionEntity struct {
+	entities.Mention
+	UserID uuid.UUID `json:"user_id"`
+}
+
+// saveMentions resolves the @handles in a new chirp to users and notifies
+// those who can see it. Like saveHashtags, it logs rather than fails the
+// request.
+func saveMentions(c *apiConfig, r *http.Request, chirp database.Chirp) {
+	handles := entities.UniqueHandles(entities.Mentions(chirp.Body))
+	if len(handles) == 0 {
+		return
+	}
+	mentioned, err := c.Db.AddChirpMentions(r.Context(), database.AddChirpMentionsParams{
+		ChirpID:  chirp.ID,
+		Handles:  handles,
+		AuthorID: chirp.UserID,
+	})
+	if err != nil {
+		fmt.Println("Error saving mentions: ", err)
+		return
+	}
+	for _, user := range mentioned {
+		notify(c, r.Context(), user, chirp.UserID, notifyMention, chirp.ID)
+	}
+}
+
+// setMentions adds the resolved mentions to chirps and the chirps they quote.
+func setMentions(c *apiConfig, r *http.Request, chirps []*Chirp) {
+	byID := map[uuid.UUID][]*Chirp{}
+	ids := []uuid.UUID{}
+	for _, chirp := range chirps {
+		for _, ch := range []*Chirp{chirp, chirp.QuotedChirp} {
+			if ch == nil || !strings.ContainsAny(ch.Body, "@＠") {
+				continue
+			}
+			if _, ok := byID[ch.ID]; !ok {
+				ids = append(ids, ch.ID)
+			}
+			byID[ch.ID] = append(byID[ch.ID], ch)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+	rows, err := c.Db.ListChirpMentions(r.Context(), ids)
+	if err != nil {
+		fmt.Println("Error listing mentions: ", err)
+		return
+	}
+	users := map[uuid.UUID]map[string]uuid.UUID{}
+	for _, row := range rows {
+		if users[row.ChirpID] == nil {
+			users[row.ChirpID] = map[string]uuid.UUID{}
+		}
+		users[row.ChirpID][row.Handle] = row.UserID
+	}
+	for id, copies := range byID {
+		resolved := []MentionEntity{}
+		for _, mention := range entities.Mentions(copies[0].Body) {
+			if userID, ok := users[id][strings.ToLower(mention.Handle)]; ok {
+				resolved = append(resolved, MentionEntity{Mention: mention, UserID: userID})
+			}
+		}
+		for _, ch := range copies {
+			ch.Entities.Mentions = resolved
+		}
+	}
+}
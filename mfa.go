@@ -0,0 +1,277 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ablanchetMD/chirpy/internal/auth"
+	"github.com/ablanchetMD/chirpy/internal/database"
+)
+
+const (
+	mfaChallengeExpiry = 5 * time.Minute
+	totpIssuer         = "Chirpy"
+	recoveryCodeCount  = 10
+)
+
+type MFAChallenge struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+}
+
+type TOTPEnrollment struct {
+	Secret        string   `json:"secret"`
+	OTPAuthURI    string   `json:"otpauth_uri"`
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+func decodeStringFields(r *http.Request) (map[string]string, error) {
+	defer r.Body.Close()
+	var requestData map[string]string
+	err := json.NewDecoder(r.Body).Decode(&requestData)
+	if err != nil {
+		return nil, err
+	}
+	return requestData, nil
+}
+
+// useTOTPCode checks a TOTP code and records its time step, refusing codes
+// from a step that has already been used.
+func useTOTPCode(c *apiConfig, r *http.Request, user database.User, code string) (bool, error) {
+	counter, ok := auth.ValidateTOTP(user.TotpSecret.String, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+	used, err := c.Db.UseUserTOTPCounter(r.Context(), database.UseUserTOTPCounterParams{
+		ID:      user.ID,
+		Counter: int64(counter),
+	})
+	if err != nil {
+		return false, err
+	}
+	return used == 1, nil
+}
+
+// verifySecondFactor accepts either a current TOTP code or an unused recovery
+// code, consuming the latter.
+func verifySecondFactor(c *apiConfig, r *http.Request, user database.User, code, recoveryCode string) (bool, error) {
+	if code != "" {
+		if !user.TotpSecret.Valid {
+			return false, nil
+		}
+		return useTOTPCode(c, r, user, code)
+	}
+	if recoveryCode == "" {
+		return false, nil
+	}
+	used, err := c.Db.UseRecoveryCode(r.Context(), database.UseRecoveryCodeParams{
+		UserID:   user.ID,
+		CodeHash: auth.HashToken(strings.ToLower(strings.TrimSpace(recoveryCode))),
+		UsedAt:   sql.NullTime{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		return false, err
+	}
+	return used == 1, nil
+}
+
+func handleEnrollTOTP(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	principal, _ := principalFromContext(r.Context())
+	user, err := c.Db.GetUserByID(r.Context(), principal.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid or missing credentials")
+		return
+	}
+	if user.TotpEnabledAt.Valid {
+		respondWithError(w, http.StatusConflict, "Two-factor authentication is already enabled")
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error generating TOTP secret")
+		return
+	}
+	codes, err := auth.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error generating recovery codes")
+		return
+	}
+
+	err = c.Db.SetUserTOTPSecret(r.Context(), database.SetUserTOTPSecretParams{
+		ID:         user.ID,
+		TotpSecret: sql.NullString{String: secret, Valid: true},
+		UpdatedAt:  time.Now(),
+	})
+	if err != nil {
+		fmt.Println("Error saving TOTP secret: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error enrolling authenticator")
+		return
+	}
+	err = c.Db.DeleteRecoveryCodes(r.Context(), user.ID)
+	if err != nil {
+		fmt.Println("Error deleting recovery codes: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error enrolling authenticator")
+		return
+	}
+	for _, code := range codes {
+		err = c.Db.CreateRecoveryCode(r.Context(), database.CreateRecoveryCodeParams{
+			CreatedAt: time.Now(),
+			UserID:    user.ID,
+			CodeHash:  auth.HashToken(code),
+		})
+		if err != nil {
+			fmt.Println("Error saving recovery code: ", err)
+			respondWithError(w, http.StatusInternalServerError, "Error enrolling authenticator")
+			return
+		}
+	}
+
+	respondWithJSON(w, http.StatusCreated, TOTPEnrollment{
+		Secret:        secret,
+		OTPAuthURI:    auth.TOTPProvisioningURI(totpIssuer, user.Email, secret),
+		RecoveryCodes: codes,
+	})
+}
+
+func handleConfirmTOTP(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	principal, _ := principalFromContext(r.Context())
+	requestData, err := decodeStringFields(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	user, err := c.Db.GetUserByID(r.Context(), principal.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid or missing credentials")
+		return
+	}
+	if !user.TotpSecret.Valid {
+		respondWithError(w, http.StatusBadRequest, "No authenticator enrollment in progress")
+		return
+	}
+	if user.TotpEnabledAt.Valid {
+		respondWithError(w, http.StatusConflict, "Two-factor authentication is already enabled")
+		return
+	}
+	ok, err := useTOTPCode(c, r, user, requestData["code"])
+	if err != nil {
+		fmt.Println("Error checking TOTP code: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error enabling two-factor authentication")
+		return
+	}
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Invalid code")
+		return
+	}
+
+	err = c.Db.EnableUserTOTP(r.Context(), database.EnableUserTOTPParams{
+		ID:            user.ID,
+		TotpEnabledAt: sql.NullTime{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		fmt.Println("Error enabling TOTP: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error enabling two-factor authentication")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, "Two-factor authentication enabled")
+}
+
+func handleDisableTOTP(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	principal, _ := principalFromContext(r.Context())
+	requestData, err := decodeStringFields(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	user, err := c.Db.GetUserByID(r.Context(), principal.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid or missing credentials")
+		return
+	}
+	if !user.TotpEnabledAt.Valid {
+		respondWithError(w, http.StatusBadRequest, "Two-factor authentication is not enabled")
+		return
+	}
+	ok, err := verifySecondFactor(c, r, user, requestData["code"], requestData["recovery_code"])
+	if err != nil {
+		fmt.Println("Error checking second factor: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error disabling two-factor authentication")
+		return
+	}
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Invalid code")
+		return
+	}
+
+	err = c.Db.DisableUserTOTP(r.Context(), database.DisableUserTOTPParams{
+		ID:        user.ID,
+		UpdatedAt: time.Now(),
+	})
+	if err == nil {
+		err = c.Db.DeleteRecoveryCodes(r.Context(), user.ID)
+	}
+	if err != nil {
+		fmt.Println("Error disabling TOTP: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error disabling two-factor authentication")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, "Two-factor authentication disabled")
+}
+
+// handleLoginMFA is the second step of a login for accounts with an
+// authenticator: it trades the MFA challenge token and a code for the real
+// access token.
+func handleLoginMFA(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	requestData, err := decodeStringFields(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	userID, err := auth.ValidateMFAToken(requestData["mfa_token"], c.JWTSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid or expired MFA token")
+		return
+	}
+	user, err := c.Db.GetUserByID(r.Context(), userID)
+	if err != nil || !user.TotpEnabledAt.Valid {
+		respondWithError(w, http.StatusUnauthorized, "Invalid or expired MFA token")
+		return
+	}
+
+	ip := clientIP(c, r)
+	wait, locked, err := c.LoginLimiter.Attempt(r.Context(), user.Email, ip)
+	if err != nil {
+		fmt.Println("Error checking login attempts: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error logging in")
+		return
+	}
+	if wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		respondWithError(w, http.StatusTooManyRequests, "Too many failed login attempts, try again later.")
+		return
+	}
+
+	ok, err := verifySecondFactor(c, r, user, requestData["code"], requestData["recovery_code"])
+	if err != nil {
+		fmt.Println("Error checking second factor: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error logging in")
+		return
+	}
+	if !ok {
+		recordLoginFailure(c, user.Email, ip, locked)
+		respondWithError(w, http.StatusUnauthorized, "Invalid code")
+		return
+	}
+	err = c.LoginLimiter.Succeed(r.Context(), user.Email, ip)
+	if err != nil {
+		fmt.Println("Error clearing login attempts: ", err)
+	}
+
+	completeLogin(c, w, r, user)
+}
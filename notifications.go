@@ -0,0 +1,336 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/ablanchetMD/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	notifyLike          = "like"
+	notifyReply         = "reply"
+	notifyMention       = "mention"
+	notifyFollow        = "follow"
+	notifyFollowRequest = "follow_request"
+	notifyRechirp       = "rechirp"
+)
+
+// notificationTypes lists the types a user can turn off. Those that group
+// repeated events say what the events are grouped by.
+var notificationTypes = map[string]struct {
+	grouped  bool
+	perChirp bool
+}{
+	notifyLike:          {grouped: true, perChirp: true},
+	notifyReply:         {},
+	notifyMention:       {},
+	notifyFollow:        {grouped: true},
+	notifyFollowRequest: {grouped: true},
+	notifyRechirp:       {grouped: true, perChirp: true},
+}
+
+// notificationActorsShown is how many actors are listed on a grouped
+// notification; actor_count has the total.
+const notificationActorsShown = 3
+
+// Notification is an entry in a user's inbox. Grouped notifications, such
+// as likes of one chirp, collect every actor until they are read, so clients
+// can show "X and 12 others liked". UpdatedAt is when the latest one acted.
+type Notification struct {
+	ID         uuid.UUID    `json:"id"`
+	Type       string       `json:"type"`
+	CreatedAt  time.Time    `json:"created_at"`
+	UpdatedAt  time.Time    `json:"updated_at"`
+	ReadAt     *time.Time   `json:"read_at"`
+	Actors     []PublicUser `json:"actors"`
+	ActorCount int          `json:"actor_count"`
+	ChirpID    *uuid.UUID   `json:"chirp_id,omitempty"`
+	Chirp      *Chirp       `json:"chirp,omitempty"`
+}
+
+type NotificationPage struct {
+	Page[Notification]
+	UnreadCount int64 `json:"unread_count"`
+}
+
+func mapNotificationStruct(src database.Notification, actorCount int64) Notification {
+	return Notification{
+		ID:         src.ID,
+		Type:       src.Type,
+		CreatedAt:  src.CreatedAt,
+		UpdatedAt:  src.UpdatedAt,
+		ReadAt:     nullTimePtr(src.ReadAt),
+		ActorCount: int(actorCount),
+		ChirpID:    nullUUIDPtr(src.ChirpID),
+	}
+}
+
+func notificationCursor(n Notification) pageCursor {
+	return pageCursor{CreatedAt: n.UpdatedAt, ID: n.ID}
+}
+
+// notify tells user that actor did something. Nothing is sent for users'
+// own actions or types they have turned off. Failures are logged, as the
+// action itself has already succeeded.
+func notify(c *apiConfig, ctx context.Context, user, actor uuid.UUID, kind string, chirpID uuid.UUID) {
+	if user == actor {
+		return
+	}
+	params := database.CreateNotificationParams{
+		CreatedAt: time.Now(),
+		UserID:    user,
+		ActorID:   actor,
+		Type:      kind,
+	}
+	if chirpID != uuid.Nil {
+		params.ChirpID = uuid.NullUUID{UUID: chirpID, Valid: true}
+	}
+	if t := notificationTypes[kind]; t.grouped {
+		key := kind
+		if t.perChirp {
+			key += ":" + chirpID.String()
+		}
+		params.GroupKey = sql.NullString{String: key, Valid: true}
+	}
+	if err := c.Db.CreateNotification(ctx, params); err != nil {
+		fmt.Println("Error creating notification: ", err)
+	}
+}
+
+// setNotificationDetails adds the latest actors and the chirp, if any and
+// still visible to viewer, to each notification.
+func setNotificationDetails(c *apiConfig, r *http.Request, viewer uuid.UUID, notifications []Notification) error {
+	ids := []uuid.UUID{}
+	chirpIDs := []uuid.UUID{}
+	for _, n := range notifications {
+		ids = append(ids, n.ID)
+		if n.ChirpID != nil {
+			chirpIDs = append(chirpIDs, *n.ChirpID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	actors, err := c.Db.ListNotificationActors(r.Context(), database.ListNotificationActorsParams{
+		NotificationIds: ids,
+		PerNotification: notificationActorsShown,
+	})
+	if err != nil {
+		return err
+	}
+	byNotification := map[uuid.UUID][]PublicUser{}
+	for _, row := range actors {
+		byNotification[row.NotificationID] = append(byNotification[row.NotificationID], mapPublicUserStruct(row.User))
+	}
+
+	chirps := map[uuid.UUID]*Chirp{}
+	if len(chirpIDs) > 0 {
+		rows, err := c.Db.ListChirpsForViewer(r.Context(), database.ListChirpsForViewerParams{
+			ViewerID: viewer,
+			Ids:      chirpIDs,
+		})
+		if err != nil {
+			return err
+		}
+		refs := []*Chirp{}
+		for _, row := range rows {
+			if row.Visible {
+				chirp := mapChirpStruct(row.Chirp)
+				chirps[chirp.ID] = &chirp
+				refs = append(refs, &chirp)
+			}
+		}
+		decorateChirps(c, r, viewer, refs)
+	}
+
+	for i := range notifications {
+		n := &notifications[i]
+		n.Actors = byNotification[n.ID]
+		if n.Actors == nil {
+			n.Actors = []PublicUser{}
+		}
+		if n.ChirpID != nil {
+			n.Chirp = chirps[*n.ChirpID]
+		}
+	}
+	return nil
+}
+
+// handleListNotifications returns the caller's notifications, latest first,
+// with the number still unread. ?unread=true leaves out those already read.
+func handleListNotifications(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	principal, _ := principalFromContext(r.Context())
+
+	cursor, limit, err := parsePagination(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid cursor or limit")
+		return
+	}
+	rows, err := c.Db.ListNotifications(r.Context(), database.ListNotificationsParams{
+		UserID:     principal.UserID,
+		UnreadOnly: r.URL.Query().Get("unread") == "true",
+		UpdatedAt:  cursor.CreatedAt,
+		ID:         cursor.ID,
+		Limit:      int32(limit + 1),
+	})
+	if err != nil {
+		fmt.Println("Error listing notifications: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error listing notifications")
+		return
+	}
+	notifications := []Notification{}
+	for _, row := range rows {
+		notifications = append(notifications, mapNotificationStruct(row.Notification, row.ActorCount))
+	}
+	page := makePage(notifications, limit, notificationCursor)
+	if err := setNotificationDetails(c, r, principal.UserID, page.Items); err != nil {
+		fmt.Println("Error listing notification details: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error listing notifications")
+		return
+	}
+
+	unread, err := c.Db.CountUnreadNotifications(r.Context(), principal.UserID)
+	if err != nil {
+		fmt.Println("Error counting notifications: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error listing notifications")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, NotificationPage{Page: page, UnreadCount: unread})
+}
+
+// handleUnreadNotificationCount returns just the unread count, for badges.
+func handleUnreadNotificationCount(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	principal, _ := principalFromContext(r.Context())
+
+	unread, err := c.Db.CountUnreadNotifications(r.Context(), principal.UserID)
+	if err != nil {
+		fmt.Println("Error counting notifications: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error counting notifications")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]int64{"unread_count": unread})
+}
+
+// handleMarkNotificationRead marks one of the caller's notifications read.
+// Marking it again is not an error.
+func handleMarkNotificationRead(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	principal, _ := principalFromContext(r.Context())
+
+	id, err := uuid.Parse(r.PathValue("notificationID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid notification id")
+		return
+	}
+	updated, err := c.Db.MarkNotificationRead(r.Context(), database.MarkNotificationReadParams{
+		ID:     id,
+		UserID: principal.UserID,
+		ReadAt: time.Now(),
+	})
+	if err != nil {
+		fmt.Println("Error marking notification read: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error marking notification read")
+		return
+	}
+	if updated == 0 {
+		respondWithError(w, http.StatusNotFound, "No notification with that id")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleMarkAllNotificationsRead marks all of the caller's notifications
+// read.
+func handleMarkAllNotificationsRead(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	principal, _ := principalFromContext(r.Context())
+
+	_, err := c.Db.MarkAllNotificationsRead(r.Context(), database.MarkAllNotificationsReadParams{
+		UserID: principal.UserID,
+		ReadAt: time.Now(),
+	})
+	if err != nil {
+		fmt.Println("Error marking notifications read: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error marking notifications read")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// notificationPreferences returns whether each notification type is on for
+// user.
+func notificationPreferences(c *apiConfig, r *http.Request, user uuid.UUID) (map[string]bool, error) {
+	preferences := map[string]bool{}
+	for kind := range notificationTypes {
+		preferences[kind] = true
+	}
+	rows, err := c.Db.ListNotificationPreferences(r.Context(), user)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		if _, ok := preferences[row.Type]; ok {
+			preferences[row.Type] = row.Enabled
+		}
+	}
+	return preferences, nil
+}
+
+func handleGetNotificationPreferences(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	principal, _ := principalFromContext(r.Context())
+
+	preferences, err := notificationPreferences(c, r, principal.UserID)
+	if err != nil {
+		fmt.Println("Error getting notification preferences: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error getting notification preferences")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, preferences)
+}
+
+// handleUpdateNotificationPreferences turns notification types on or off
+// with a body such as {"like": false}. Types left out are unchanged.
+func handleUpdateNotificationPreferences(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	principal, _ := principalFromContext(r.Context())
+
+	var requestData map[string]bool
+	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	kinds := make([]string, 0, len(requestData))
+	for kind := range requestData {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	var fieldErrors []FieldError
+	for _, kind := range kinds {
+		if _, ok := notificationTypes[kind]; !ok {
+			fieldErrors = append(fieldErrors, FieldError{Field: kind, Code: "unsupported", Message: "Unknown notification type"})
+		}
+	}
+	if len(fieldErrors) > 0 {
+		respondWithFieldErrors(w, fieldErrors)
+		return
+	}
+
+	for _, kind := range kinds {
+		err := c.Db.SetNotificationPreference(r.Context(), database.SetNotificationPreferenceParams{
+			UserID:  principal.UserID,
+			Type:    kind,
+			Enabled: requestData[kind],
+		})
+		if err != nil {
+			fmt.Println("Error saving notification preference: ", err)
+			respondWithError(w, http.StatusInternalServerError, "Error saving notification preferences")
+			return
+		}
+	}
+	handleGetNotificationPreferences(c, w, r)
+}
@@ -0,0 +1,661 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ablanchetMD/chirpy/internal/auth"
+	"github.com/ablanchetMD/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const oauthCodeExpiry = time.Minute
+
+type OAuthClient struct {
+	ID           uuid.UUID `json:"client_id"`
+	CreatedAt    time.Time `json:"created_at"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	Scopes       []string  `json:"scopes"`
+	Confidential bool      `json:"confidential"`
+	// ClientSecret is only set in the response that registers the client.
+	ClientSecret string `json:"client_secret,omitempty"`
+}
+
+func mapOAuthClientStruct(src database.OauthClient) OAuthClient {
+	return OAuthClient{
+		ID:           src.ID,
+		CreatedAt:    src.CreatedAt,
+		Name:         src.Name,
+		RedirectURIs: src.RedirectUris,
+		Scopes:       src.Scopes,
+		Confidential: src.ClientSecretHash.Valid,
+	}
+}
+
+// OAuthConsent is what the consent page shows the user.
+type OAuthConsent struct {
+	ClientID    uuid.UUID `json:"client_id"`
+	ClientName  string    `json:"client_name"`
+	Scopes      []string  `json:"scopes"`
+	RedirectURI string    `json:"redirect_uri"`
+}
+
+type OAuthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+	Scope        string `json:"scope"`
+}
+
+// oauthError is an error reported to the client in RFC 6749 form, either in
+// a JSON body or on the redirect URI.
+type oauthError struct {
+	Code        string
+	Description string
+}
+
+func (e *oauthError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+// errUnknownClient covers authorization requests with a client or redirect
+// URI we cannot trust, which must not be redirected anywhere.
+var errUnknownClient = errors.New("unknown client or redirect_uri")
+
+func respondWithOAuthError(w http.ResponseWriter, code int, oerr *oauthError) {
+	w.Header().Set("Cache-Control", "no-store")
+	respondWithJSON(w, code, struct {
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description,omitempty"`
+	}{oerr.Code, oerr.Description})
+}
+
+// validRedirectURI accepts absolute https URIs, plus http on loopback for
+// native and development clients. Fragments are not allowed.
+func validRedirectURI(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || !u.IsAbs() || u.Fragment != "" || u.Host == "" {
+		return false
+	}
+	switch u.Scheme {
+	case "https":
+		return true
+	case "http":
+		host := u.Hostname()
+		return host == "localhost" || host == "127.0.0.1" || host == "::1"
+	}
+	return false
+}
+
+func redirectURIWithParams(redirectURI string, params url.Values) string {
+	u, _ := url.Parse(redirectURI)
+	query := u.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+func handleCreateOAuthClient(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	principal, _ := principalFromContext(r.Context())
+
+	var requestData struct {
+		Name         string   `json:"name"`
+		RedirectURIs []string `json:"redirect_uris"`
+		Scopes       []string `json:"scopes"`
+		Confidential bool     `json:"confidential"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&requestData)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	defer r.Body.Close()
+
+	if requestData.Name == "" {
+		respondWithError(w, http.StatusBadRequest, "Missing name field")
+		return
+	}
+	if len(requestData.RedirectURIs) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Missing redirect_uris field")
+		return
+	}
+	for _, redirectURI := range requestData.RedirectURIs {
+		if !validRedirectURI(redirectURI) {
+			respondWithError(w, http.StatusBadRequest, "Invalid redirect URI: "+redirectURI)
+			return
+		}
+	}
+	if len(requestData.Scopes) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Missing scopes field")
+		return
+	}
+	for _, scope := range requestData.Scopes {
+		if !contains(auth.OAuthScopes, scope) {
+			respondWithError(w, http.StatusBadRequest, "Unknown scope: "+scope)
+			return
+		}
+	}
+
+	secret := ""
+	secretHash := sql.NullString{}
+	if requestData.Confidential {
+		secret, err = auth.MakeRandomToken()
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error generating client secret")
+			return
+		}
+		secretHash = sql.NullString{String: auth.HashToken(secret), Valid: true}
+	}
+
+	client, err := c.Db.CreateOAuthClient(r.Context(), database.CreateOAuthClientParams{
+		CreatedAt:        time.Now(),
+		OwnerID:          principal.UserID,
+		Name:             requestData.Name,
+		ClientSecretHash: secretHash,
+		RedirectUris:     requestData.RedirectURIs,
+		Scopes:           requestData.Scopes,
+	})
+	if err != nil {
+		fmt.Println("Error creating OAuth client: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error creating OAuth client")
+		return
+	}
+
+	response := mapOAuthClientStruct(client)
+	response.ClientSecret = secret
+	respondWithJSON(w, http.StatusCreated, response)
+}
+
+func handleListOAuthClients(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	principal, _ := principalFromContext(r.Context())
+
+	clients, err := c.Db.ListOAuthClientsByOwner(r.Context(), principal.UserID)
+	if err != nil {
+		fmt.Println("Error listing OAuth clients: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error listing OAuth clients")
+		return
+	}
+	clientStructs := []OAuthClient{}
+	for _, client := range clients {
+		clientStructs = append(clientStructs, mapOAuthClientStruct(client))
+	}
+	respondWithJSON(w, http.StatusOK, clientStructs)
+}
+
+// handleRevokeOAuthClient retires a client along with every grant users gave
+// it.
+func handleRevokeOAuthClient(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	principal, _ := principalFromContext(r.Context())
+
+	clientID, err := uuid.Parse(r.PathValue("clientID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid id field")
+		return
+	}
+	now := sql.NullTime{Time: time.Now(), Valid: true}
+	revoked, err := c.Db.RevokeOAuthClient(r.Context(), database.RevokeOAuthClientParams{
+		ID:        clientID,
+		OwnerID:   principal.UserID,
+		RevokedAt: now,
+	})
+	if err != nil {
+		fmt.Println("Error revoking OAuth client: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error revoking OAuth client")
+		return
+	}
+	if revoked == 0 {
+		respondWithError(w, http.StatusNotFound, "No OAuth client with that id")
+		return
+	}
+	err = c.Db.RevokeOAuthGrantsByClient(r.Context(), database.RevokeOAuthGrantsByClientParams{
+		ClientID:  clientID,
+		RevokedAt: now,
+	})
+	if err != nil {
+		fmt.Println("Error revoking OAuth grants: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error revoking OAuth client")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type authorizationRequest struct {
+	Client        database.OauthClient
+	RedirectURI   string
+	State         string
+	Scopes        []string
+	CodeChallenge string
+}
+
+// parseAuthorizationRequest validates the authorization endpoint parameters.
+// It returns errUnknownClient when the client or redirect URI cannot be
+// trusted and an *oauthError for anything that may be reported back on the
+// redirect URI.
+func parseAuthorizationRequest(c *apiConfig, r *http.Request, params url.Values) (authorizationRequest, error) {
+	req := authorizationRequest{
+		RedirectURI: params.Get("redirect_uri"),
+		State:       params.Get("state"),
+	}
+	clientID, err := uuid.Parse(params.Get("client_id"))
+	if err != nil {
+		return req, errUnknownClient
+	}
+	req.Client, err = c.Db.GetOAuthClient(r.Context(), clientID)
+	if err == sql.ErrNoRows {
+		return req, errUnknownClient
+	}
+	if err != nil {
+		return req, err
+	}
+	if !contains(req.Client.RedirectUris, req.RedirectURI) {
+		return req, errUnknownClient
+	}
+
+	if params.Get("response_type") != "code" {
+		return req, &oauthError{"unsupported_response_type", "Only the code response type is supported"}
+	}
+	req.Scopes, err = auth.ParseScope(params.Get("scope"), req.Client.Scopes)
+	if err != nil || len(req.Scopes) == 0 {
+		return req, &oauthError{"invalid_scope", "Requested scope is missing or not allowed for this client"}
+	}
+	req.CodeChallenge = params.Get("code_challenge")
+	if req.CodeChallenge == "" || params.Get("code_challenge_method") != "S256" {
+		return req, &oauthError{"invalid_request", "PKCE with code_challenge_method=S256 is required"}
+	}
+	return req, nil
+}
+
+// handleAuthorize is the OAuth authorization endpoint. Valid requests are
+// sent to the consent page; everything else is reported to the client.
+func handleAuthorize(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	req, err := parseAuthorizationRequest(c, r, r.URL.Query())
+	var oerr *oauthError
+	if errors.As(err, &oerr) {
+		params := url.Values{"error": {oerr.Code}, "error_description": {oerr.Description}}
+		if req.State != "" {
+			params.Set("state", req.State)
+		}
+		http.Redirect(w, r, redirectURIWithParams(req.RedirectURI, params), http.StatusFound)
+		return
+	}
+	if err == errUnknownClient {
+		respondWithError(w, http.StatusBadRequest, "Unknown client_id or redirect_uri")
+		return
+	}
+	if err != nil {
+		fmt.Println("Error parsing authorization request: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error processing authorization request")
+		return
+	}
+	http.Redirect(w, r, "/app/oauth-consent.html?"+r.URL.RawQuery, http.StatusFound)
+}
+
+// handleGetConsent describes a pending authorization request to the logged
+// in user.
+func handleGetConsent(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	req, err := parseAuthorizationRequest(c, r, r.URL.Query())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid authorization request")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, OAuthConsent{
+		ClientID:    req.Client.ID,
+		ClientName:  req.Client.Name,
+		Scopes:      req.Scopes,
+		RedirectURI: req.RedirectURI,
+	})
+}
+
+// handleConsent records the user's decision and returns where to send the
+// browser: back to the client with either a code or access_denied.
+func handleConsent(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	principal, _ := principalFromContext(r.Context())
+
+	var requestData struct {
+		ClientID            string `json:"client_id"`
+		RedirectURI         string `json:"redirect_uri"`
+		ResponseType        string `json:"response_type"`
+		Scope               string `json:"scope"`
+		State               string `json:"state"`
+		CodeChallenge       string `json:"code_challenge"`
+		CodeChallengeMethod string `json:"code_challenge_method"`
+		Approve             bool   `json:"approve"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&requestData)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	defer r.Body.Close()
+
+	req, err := parseAuthorizationRequest(c, r, url.Values{
+		"client_id":             {requestData.ClientID},
+		"redirect_uri":          {requestData.RedirectURI},
+		"response_type":         {requestData.ResponseType},
+		"scope":                 {requestData.Scope},
+		"state":                 {requestData.State},
+		"code_challenge":        {requestData.CodeChallenge},
+		"code_challenge_method": {requestData.CodeChallengeMethod},
+	})
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid authorization request")
+		return
+	}
+
+	params := url.Values{}
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+	if !requestData.Approve {
+		params.Set("error", "access_denied")
+		respondWithJSON(w, http.StatusOK, map[string]string{"redirect_to": redirectURIWithParams(req.RedirectURI, params)})
+		return
+	}
+
+	code, err := auth.MakeRandomToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating authorization code")
+		return
+	}
+	now := time.Now()
+	_, err = c.Db.CreateOAuthAuthorizationCode(r.Context(), database.CreateOAuthAuthorizationCodeParams{
+		CreatedAt:     now,
+		ClientID:      req.Client.ID,
+		UserID:        principal.UserID,
+		CodeHash:      auth.HashToken(code),
+		RedirectUri:   req.RedirectURI,
+		Scopes:        req.Scopes,
+		CodeChallenge: req.CodeChallenge,
+		ExpiresAt:     now.Add(oauthCodeExpiry),
+	})
+	if err != nil {
+		fmt.Println("Error creating authorization code: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error creating authorization code")
+		return
+	}
+	params.Set("code", code)
+	respondWithJSON(w, http.StatusOK, map[string]string{"redirect_to": redirectURIWithParams(req.RedirectURI, params)})
+}
+
+// authenticateOAuthClient identifies the client calling the token or
+// revocation endpoint, with HTTP Basic credentials or client_id (and
+// client_secret) form fields. Public clients only send their id and rely on
+// PKCE instead.
+func authenticateOAuthClient(c *apiConfig, r *http.Request) (database.OauthClient, error) {
+	id, secret, ok := r.BasicAuth()
+	if ok {
+		id, _ = url.QueryUnescape(id)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		id, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	clientID, err := uuid.Parse(id)
+	if err != nil {
+		return database.OauthClient{}, errUnknownClient
+	}
+	client, err := c.Db.GetOAuthClient(r.Context(), clientID)
+	if err == sql.ErrNoRows {
+		return database.OauthClient{}, errUnknownClient
+	}
+	if err != nil {
+		return database.OauthClient{}, err
+	}
+	if client.ClientSecretHash.Valid {
+		if subtle.ConstantTimeCompare([]byte(auth.HashToken(secret)), []byte(client.ClientSecretHash.String)) != 1 {
+			return database.OauthClient{}, errUnknownClient
+		}
+	}
+	return client, nil
+}
+
+// handleToken is the OAuth token endpoint, supporting the authorization_code
+// and refresh_token grants.
+func handleToken(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		respondWithOAuthError(w, http.StatusBadRequest, &oauthError{"invalid_request", "Invalid form body"})
+		return
+	}
+	client, err := authenticateOAuthClient(c, r)
+	if err == errUnknownClient {
+		respondWithOAuthError(w, http.StatusUnauthorized, &oauthError{"invalid_client", "Client authentication failed"})
+		return
+	}
+	if err != nil {
+		fmt.Println("Error authenticating OAuth client: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error issuing token")
+		return
+	}
+
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		exchangeAuthorizationCode(c, w, r, client)
+	case "refresh_token":
+		refreshOAuthToken(c, w, r, client)
+	default:
+		respondWithOAuthError(w, http.StatusBadRequest, &oauthError{"unsupported_grant_type", "Unsupported grant_type"})
+	}
+}
+
+// authorizationCodeStore is the part of the database that
+// redeemAuthorizationCode needs.
+type authorizationCodeStore interface {
+	UseOAuthAuthorizationCode(ctx context.Context, arg database.UseOAuthAuthorizationCodeParams) (database.OauthAuthorizationCode, error)
+	GetOAuthAuthorizationCodeByHash(ctx context.Context, codeHash string) (database.OauthAuthorizationCode, error)
+	RevokeOAuthGrantsByCode(ctx context.Context, arg database.RevokeOAuthGrantsByCodeParams) error
+}
+
+// redeemAuthorizationCode marks code used and returns it, or sql.ErrNoRows if
+// it is unknown or was already used. A code presented twice may have been
+// intercepted, so whatever it was exchanged for the first time is revoked too.
+func redeemAuthorizationCode(ctx context.Context, db authorizationCodeStore, code string, now time.Time) (database.OauthAuthorizationCode, error) {
+	codeHash := auth.HashToken(code)
+	redeemed, err := db.UseOAuthAuthorizationCode(ctx, database.UseOAuthAuthorizationCodeParams{
+		CodeHash: codeHash,
+		UsedAt:   sql.NullTime{Time: now, Valid: true},
+	})
+	if err != sql.ErrNoRows {
+		return redeemed, err
+	}
+	used, err := db.GetOAuthAuthorizationCodeByHash(ctx, codeHash)
+	if err == nil {
+		fmt.Println("Authorization code replayed for client ", used.ClientID)
+		err = db.RevokeOAuthGrantsByCode(ctx, database.RevokeOAuthGrantsByCodeParams{
+			CodeID:    used.ID,
+			RevokedAt: sql.NullTime{Time: now, Valid: true},
+		})
+		if err != nil {
+			fmt.Println("Error revoking OAuth grants: ", err)
+		}
+	}
+	return database.OauthAuthorizationCode{}, sql.ErrNoRows
+}
+
+func exchangeAuthorizationCode(c *apiConfig, w http.ResponseWriter, r *http.Request, client database.OauthClient) {
+	invalidGrant := &oauthError{"invalid_grant", "Invalid, expired or already used authorization code"}
+	now := time.Now()
+
+	code, err := redeemAuthorizationCode(r.Context(), c.Db, r.PostForm.Get("code"), now)
+	if err == sql.ErrNoRows {
+		respondWithOAuthError(w, http.StatusBadRequest, invalidGrant)
+		return
+	}
+	if err != nil {
+		fmt.Println("Error using authorization code: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error issuing token")
+		return
+	}
+	if code.ClientID != client.ID || now.After(code.ExpiresAt) ||
+		code.RedirectUri != r.PostForm.Get("redirect_uri") ||
+		!auth.VerifyPKCE(r.PostForm.Get("code_verifier"), code.CodeChallenge) {
+		respondWithOAuthError(w, http.StatusBadRequest, invalidGrant)
+		return
+	}
+
+	user, err := c.Db.GetUserByID(r.Context(), code.UserID)
+	if err != nil {
+		respondWithOAuthError(w, http.StatusBadRequest, invalidGrant)
+		return
+	}
+	refreshToken, err := auth.MakeRandomToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error issuing token")
+		return
+	}
+	grant, err := c.Db.CreateOAuthGrant(r.Context(), database.CreateOAuthGrantParams{
+		CreatedAt:        now,
+		ClientID:         client.ID,
+		UserID:           user.ID,
+		CodeID:           code.ID,
+		Scopes:           code.Scopes,
+		RefreshTokenHash: auth.HashToken(refreshToken),
+		ExpiresAt:        now.Add(refreshTokenExpiry),
+	})
+	if err != nil {
+		fmt.Println("Error creating OAuth grant: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error issuing token")
+		return
+	}
+	respondWithOAuthTokens(c, w, user, grant, grant.Scopes, refreshToken)
+}
+
+// refreshOAuthToken rotates the refresh token. The client may ask for a
+// narrower scope than the grant, never a wider one.
+func refreshOAuthToken(c *apiConfig, w http.ResponseWriter, r *http.Request, client database.OauthClient) {
+	invalidGrant := &oauthError{"invalid_grant", "Invalid, expired or revoked refresh token"}
+	now := time.Now()
+	oldHash := auth.HashToken(r.PostForm.Get("refresh_token"))
+
+	grant, err := c.Db.GetOAuthGrantByRefreshTokenHash(r.Context(), oldHash)
+	if err != nil || grant.ClientID != client.ID {
+		respondWithOAuthError(w, http.StatusBadRequest, invalidGrant)
+		return
+	}
+	scopes := grant.Scopes
+	if scope := r.PostForm.Get("scope"); scope != "" {
+		scopes, err = auth.ParseScope(scope, grant.Scopes)
+		if err != nil {
+			respondWithOAuthError(w, http.StatusBadRequest, &oauthError{"invalid_scope", "Requested scope exceeds the grant"})
+			return
+		}
+	}
+
+	refreshToken, err := auth.MakeRandomToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error issuing token")
+		return
+	}
+	grant, err = c.Db.RotateOAuthRefreshToken(r.Context(), database.RotateOAuthRefreshTokenParams{
+		RefreshTokenHash:   oldHash,
+		RefreshTokenHash_2: auth.HashToken(refreshToken),
+		LastUsedAt:         now,
+	})
+	if err == sql.ErrNoRows {
+		respondWithOAuthError(w, http.StatusBadRequest, invalidGrant)
+		return
+	}
+	if err != nil {
+		fmt.Println("Error rotating OAuth refresh token: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error issuing token")
+		return
+	}
+	user, err := c.Db.GetUserByID(r.Context(), grant.UserID)
+	if err != nil {
+		respondWithOAuthError(w, http.StatusBadRequest, invalidGrant)
+		return
+	}
+	respondWithOAuthTokens(c, w, user, grant, scopes, refreshToken)
+}
+
+func respondWithOAuthTokens(c *apiConfig, w http.ResponseWriter, user database.User, grant database.OauthGrant, scopes []string, refreshToken string) {
+	token, err := c.Keys.MakeOAuthAccessToken(user.ID, user.TokenVersion, grant.ID, grant.ClientID, scopes, accessTokenExpiry)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error issuing token")
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	respondWithJSON(w, http.StatusOK, OAuthTokenResponse{
+		AccessToken:  token,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(accessTokenExpiry.Seconds()),
+		RefreshToken: refreshToken,
+		Scope:        strings.Join(scopes, " "),
+	})
+}
+
+// handleOAuthRevoke implements RFC 7009. Either kind of token revokes the
+// whole grant. Unknown tokens are not an error.
+func handleOAuthRevoke(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		respondWithOAuthError(w, http.StatusBadRequest, &oauthError{"invalid_request", "Invalid form body"})
+		return
+	}
+	client, err := authenticateOAuthClient(c, r)
+	if err == errUnknownClient {
+		respondWithOAuthError(w, http.StatusUnauthorized, &oauthError{"invalid_client", "Client authentication failed"})
+		return
+	}
+	if err != nil {
+		fmt.Println("Error authenticating OAuth client: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error revoking token")
+		return
+	}
+
+	token := r.PostForm.Get("token")
+	grantID := uuid.Nil
+	if grant, err := c.Db.GetOAuthGrantByRefreshTokenHash(r.Context(), auth.HashToken(token)); err == nil {
+		if grant.ClientID == client.ID {
+			grantID = grant.ID
+		}
+	} else if claims, err := c.Keys.ValidateAccessToken(token); err == nil && claims.ClientID == client.ID.String() {
+		grantID, _ = uuid.Parse(claims.SessionID)
+	}
+	if grantID != uuid.Nil {
+		err = c.Db.RevokeOAuthGrant(r.Context(), database.RevokeOAuthGrantParams{
+			ID:        grantID,
+			RevokedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		})
+		if err != nil {
+			fmt.Println("Error revoking OAuth grant: ", err)
+			respondWithError(w, http.StatusInternalServerError, "Error revoking token")
+			return
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// authenticateOAuthToken finishes authenticating an access token issued to
+// an OAuth client: the grant must still be live.
+func authenticateOAuthToken(c *apiConfig, r *http.Request, claims *auth.AccessClaims, grantID uuid.UUID) (Principal, error) {
+	grant, err := c.Db.GetOAuthGrant(r.Context(), grantID)
+	if err == sql.ErrNoRows {
+		return Principal{}, errUnauthenticated
+	}
+	if err != nil {
+		return Principal{}, err
+	}
+	if grant.RevokedAt.Valid || grant.ClientID.String() != claims.ClientID || grant.UserID != claims.UserID() {
+		return Principal{}, errUnauthenticated
+	}
+	return Principal{
+		UserID:    grant.UserID,
+		Method:    authMethodOAuth,
+		Scopes:    claims.Scopes(),
+		SessionID: grant.ID,
+		ClientID:  grant.ClientID,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}, nil
+}
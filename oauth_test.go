@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/ablanchetMD/chirpy/internal/auth"
+	"github.com/ablanchetMD/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// memoryCodeStore keeps authorization codes and the grants issued for them
+// the way the oauth queries do.
+type memoryCodeStore struct {
+	codes   map[string]database.OauthAuthorizationCode
+	revoked map[uuid.UUID]time.Time
+}
+
+func (s *memoryCodeStore) UseOAuthAuthorizationCode(ctx context.Context, arg database.UseOAuthAuthorizationCodeParams) (database.OauthAuthorizationCode, error) {
+	code, ok := s.codes[arg.CodeHash]
+	if !ok || code.UsedAt.Valid {
+		return database.OauthAuthorizationCode{}, sql.ErrNoRows
+	}
+	code.UsedAt = arg.UsedAt
+	s.codes[arg.CodeHash] = code
+	return code, nil
+}
+
+func (s *memoryCodeStore) GetOAuthAuthorizationCodeByHash(ctx context.Context, codeHash string) (database.OauthAuthorizationCode, error) {
+	code, ok := s.codes[codeHash]
+	if !ok {
+		return database.OauthAuthorizationCode{}, sql.ErrNoRows
+	}
+	return code, nil
+}
+
+func (s *memoryCodeStore) RevokeOAuthGrantsByCode(ctx context.Context, arg database.RevokeOAuthGrantsByCodeParams) error {
+	s.revoked[arg.CodeID] = arg.RevokedAt.Time
+	return nil
+}
+
+func TestRedeemAuthorizationCode(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	id := uuid.New()
+	store := &memoryCodeStore{
+		codes: map[string]database.OauthAuthorizationCode{
+			auth.HashToken("code"): {ID: id, CodeHash: auth.HashToken("code")},
+		},
+		revoked: map[uuid.UUID]time.Time{},
+	}
+
+	code, err := redeemAuthorizationCode(ctx, store, "code", now)
+	if err != nil || code.ID != id {
+		t.Fatalf("first use: code = %v, err = %v", code.ID, err)
+	}
+	if len(store.revoked) != 0 {
+		t.Errorf("first use revoked grants")
+	}
+
+	if _, err := redeemAuthorizationCode(ctx, store, "unknown", now); err != sql.ErrNoRows {
+		t.Errorf("unknown code: err = %v, want sql.ErrNoRows", err)
+	}
+	if len(store.revoked) != 0 {
+		t.Errorf("unknown code revoked grants")
+	}
+
+	replayedAt := now.Add(time.Second)
+	if _, err := redeemAuthorizationCode(ctx, store, "code", replayedAt); err != sql.ErrNoRows {
+		t.Errorf("replayed code: err = %v, want sql.ErrNoRows", err)
+	}
+	if at, ok := store.revoked[id]; !ok || !at.Equal(replayedAt) {
+		t.Errorf("replayed code did not revoke its grants: %v", store.revoked)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// Page is one page of a cursor-paginated list. NextCursor is empty on the
+// last page.
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// pageCursor points just past the last item of a page. Lists are ordered by
+// (created_at, id) descending, which is stable even when timestamps tie.
+type pageCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+func (p pageCursor) String() string {
+	raw := strconv.FormatInt(p.CreatedAt.UnixNano(), 10) + ":" + p.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(s string) (pageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return pageCursor{}, errInvalidCursor
+	}
+	nanos, id, found := strings.Cut(string(raw), ":")
+	if !found {
+		return pageCursor{}, errInvalidCursor
+	}
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return pageCursor{}, errInvalidCursor
+	}
+	parsedID, err := uuid.Parse(id)
+	if err != nil {
+		return pageCursor{}, errInvalidCursor
+	}
+	return pageCursor{CreatedAt: time.Unix(0, n).UTC(), ID: parsedID}, nil
+}
+
+// parsePagination reads ?cursor= and ?limit=. Without a cursor the page
+// starts at the newest item. One extra row should be fetched so the caller
+// can tell whether there is a next page.
+func parsePagination(r *http.Request) (pageCursor, int, error) {
+	cursor := pageCursor{CreatedAt: time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC), ID: uuid.Max}
+	if s := r.URL.Query().Get("cursor"); s != "" {
+		var err error
+		cursor, err = decodeCursor(s)
+		if err != nil {
+			return pageCursor{}, 0, err
+		}
+	}
+	limit, err := parseLimit(r)
+	if err != nil {
+		return pageCursor{}, 0, err
+	}
+	return cursor, limit, nil
+}
+
+// parseLimit reads ?limit=, capped at maxPageSize.
+func parseLimit(r *http.Request) (int, error) {
+	limit := defaultPageSize
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			return 0, errors.New("invalid limit")
+		}
+		limit = min(n, maxPageSize)
+	}
+	return limit, nil
+}
+
+// parseAscendingPagination is parsePagination for lists ordered oldest
+// first, where the page starts before the first item without a cursor.
+func parseAscendingPagination(r *http.Request) (pageCursor, int, error) {
+	cursor, limit, err := parsePagination(r)
+	if err == nil && r.URL.Query().Get("cursor") == "" {
+		cursor = pageCursor{}
+	}
+	return cursor, limit, err
+}
+
+// makePage trims the extra row fetched by the caller and derives the next
+// cursor from the last item kept.
+func makePage[T any](items []T, limit int, cursorOf func(T) pageCursor) Page[T] {
+	page := Page[T]{Items: items}
+	if page.Items == nil {
+		page.Items = []T{}
+	}
+	if len(items) > limit {
+		page.Items = items[:limit]
+		page.NextCursor = cursorOf(page.Items[limit-1]).String()
+	}
+	return page
+}
@@ -0,0 +1,414 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ablanchetMD/chirpy/internal/auth"
+	"github.com/ablanchetMD/chirpy/internal/database"
+	"github.com/ablanchetMD/chirpy/internal/webauthn"
+	"github.com/google/uuid"
+)
+
+const (
+	webauthnChallengeExpiry = 5 * time.Minute
+	ceremonyRegister        = "register"
+	ceremonyLogin           = "login"
+)
+
+type Passkey struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Name       string     `json:"name"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+func mapPasskeyStruct(src database.WebauthnCredential) Passkey {
+	return Passkey{
+		ID:         src.ID,
+		CreatedAt:  src.CreatedAt,
+		Name:       src.Name,
+		LastUsedAt: nullTimePtr(src.LastUsedAt),
+	}
+}
+
+type credentialDescriptor struct {
+	Type string             `json:"type"`
+	ID   webauthn.Base64URL `json:"id"`
+}
+
+type credentialParameter struct {
+	Type string `json:"type"`
+	Alg  int    `json:"alg"`
+}
+
+// PasskeyRegistrationOptions is passed to navigator.credentials.create() as
+// publicKey, once the buffers are decoded.
+type PasskeyRegistrationOptions struct {
+	Challenge string `json:"challenge"`
+	RP        struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"rp"`
+	User struct {
+		ID          webauthn.Base64URL `json:"id"`
+		Name        string             `json:"name"`
+		DisplayName string             `json:"displayName"`
+	} `json:"user"`
+	PubKeyCredParams       []credentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout"`
+	Attestation            string                 `json:"attestation"`
+	ExcludeCredentials     []credentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection map[string]string      `json:"authenticatorSelection"`
+}
+
+// PasskeyLoginOptions is passed to navigator.credentials.get() as publicKey.
+type PasskeyLoginOptions struct {
+	Challenge        string                 `json:"challenge"`
+	RPID             string                 `json:"rpId"`
+	Timeout          int64                  `json:"timeout"`
+	UserVerification string                 `json:"userVerification"`
+	AllowCredentials []credentialDescriptor `json:"allowCredentials"`
+}
+
+type PasskeyChallenge struct {
+	ChallengeID uuid.UUID   `json:"challenge_id"`
+	PublicKey   interface{} `json:"public_key"`
+}
+
+// newRelyingParty reads WEBAUTHN_RP_ID, WEBAUTHN_RP_NAME and the
+// comma-separated WEBAUTHN_ORIGINS, defaulting to the host and origin of
+// BASE_URL.
+func newRelyingParty(baseURL string) (webauthn.RelyingParty, error) {
+	parsed, err := url.Parse(baseURL)
+	if err != nil {
+		return webauthn.RelyingParty{}, err
+	}
+	rp := webauthn.RelyingParty{
+		ID:      os.Getenv("WEBAUTHN_RP_ID"),
+		Name:    os.Getenv("WEBAUTHN_RP_NAME"),
+		Origins: []string{parsed.Scheme + "://" + parsed.Host},
+	}
+	if rp.ID == "" {
+		rp.ID = parsed.Hostname()
+	}
+	if rp.Name == "" {
+		rp.Name = "Chirpy"
+	}
+	if origins := os.Getenv("WEBAUTHN_ORIGINS"); origins != "" {
+		rp.Origins = strings.Split(origins, ",")
+	}
+	return rp, nil
+}
+
+func createWebAuthnChallenge(c *apiConfig, r *http.Request, userID uuid.NullUUID, ceremony string) (database.WebauthnChallenge, error) {
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return database.WebauthnChallenge{}, err
+	}
+	now := time.Now()
+	return c.Db.CreateWebAuthnChallenge(r.Context(), database.CreateWebAuthnChallengeParams{
+		CreatedAt: now,
+		UserID:    userID,
+		Ceremony:  ceremony,
+		Challenge: challenge,
+		ExpiresAt: now.Add(webauthnChallengeExpiry),
+	})
+}
+
+func credentialDescriptors(credentials []database.WebauthnCredential) []credentialDescriptor {
+	descriptors := []credentialDescriptor{}
+	for _, credential := range credentials {
+		descriptors = append(descriptors, credentialDescriptor{Type: "public-key", ID: credential.CredentialID})
+	}
+	return descriptors
+}
+
+func handleBeginPasskeyRegistration(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	principal, _ := principalFromContext(r.Context())
+
+	user, err := c.Db.GetUserByID(r.Context(), principal.UserID)
+	if err != nil {
+		fmt.Println("Error fetching user: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error starting passkey registration")
+		return
+	}
+	existing, err := c.Db.ListWebAuthnCredentialsByUser(r.Context(), user.ID)
+	if err != nil {
+		fmt.Println("Error listing passkeys: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error starting passkey registration")
+		return
+	}
+	challenge, err := createWebAuthnChallenge(c, r, uuid.NullUUID{UUID: user.ID, Valid: true}, ceremonyRegister)
+	if err != nil {
+		fmt.Println("Error creating WebAuthn challenge: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error starting passkey registration")
+		return
+	}
+
+	options := PasskeyRegistrationOptions{
+		Challenge:          challenge.Challenge,
+		Timeout:            webauthnChallengeExpiry.Milliseconds(),
+		Attestation:        "none",
+		ExcludeCredentials: credentialDescriptors(existing),
+		AuthenticatorSelection: map[string]string{
+			"residentKey":      "preferred",
+			"userVerification": "preferred",
+		},
+	}
+	options.RP.ID = c.WebAuthn.ID
+	options.RP.Name = c.WebAuthn.Name
+	options.User.ID = user.ID[:]
+	options.User.Name = user.Email
+	options.User.DisplayName = user.Email
+	for _, alg := range webauthn.SupportedAlgorithms {
+		options.PubKeyCredParams = append(options.PubKeyCredParams, credentialParameter{Type: "public-key", Alg: alg})
+	}
+	respondWithJSON(w, http.StatusOK, PasskeyChallenge{ChallengeID: challenge.ID, PublicKey: options})
+}
+
+func handleFinishPasskeyRegistration(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	principal, _ := principalFromContext(r.Context())
+
+	var requestData struct {
+		ChallengeID uuid.UUID `json:"challenge_id"`
+		Name        string    `json:"name"`
+		Credential  struct {
+			Response struct {
+				ClientDataJSON    webauthn.Base64URL `json:"clientDataJSON"`
+				AttestationObject webauthn.Base64URL `json:"attestationObject"`
+			} `json:"response"`
+		} `json:"credential"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&requestData)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	defer r.Body.Close()
+	if requestData.Name == "" {
+		requestData.Name = "Passkey"
+	}
+
+	challenge, err := c.Db.ConsumeWebAuthnChallenge(r.Context(), database.ConsumeWebAuthnChallengeParams{
+		ID:        requestData.ChallengeID,
+		Ceremony:  ceremonyRegister,
+		ExpiresAt: time.Now(),
+	})
+	if err != nil || challenge.UserID.UUID != principal.UserID {
+		if err != nil && err != sql.ErrNoRows {
+			fmt.Println("Error consuming WebAuthn challenge: ", err)
+		}
+		respondWithError(w, http.StatusBadRequest, "Invalid or expired challenge")
+		return
+	}
+
+	credential, err := c.WebAuthn.VerifyRegistration(
+		challenge.Challenge,
+		requestData.Credential.Response.ClientDataJSON,
+		requestData.Credential.Response.AttestationObject,
+		false,
+	)
+	if err != nil {
+		fmt.Println("Error verifying passkey registration: ", err)
+		respondWithError(w, http.StatusBadRequest, "Passkey registration could not be verified")
+		return
+	}
+
+	passkey, err := c.Db.CreateWebAuthnCredential(r.Context(), database.CreateWebAuthnCredentialParams{
+		CreatedAt:    time.Now(),
+		UserID:       principal.UserID,
+		Name:         requestData.Name,
+		CredentialID: credential.ID,
+		PublicKey:    credential.PublicKey,
+		SignCount:    int64(credential.SignCount),
+		Aaguid:       credential.AAGUID,
+	})
+	if err != nil {
+		fmt.Println("Error saving passkey: ", err)
+		respondWithError(w, http.StatusConflict, "Passkey is already registered")
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, mapPasskeyStruct(passkey))
+}
+
+func handleListPasskeys(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	principal, _ := principalFromContext(r.Context())
+
+	credentials, err := c.Db.ListWebAuthnCredentialsByUser(r.Context(), principal.UserID)
+	if err != nil {
+		fmt.Println("Error listing passkeys: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error listing passkeys")
+		return
+	}
+	passkeys := []Passkey{}
+	for _, credential := range credentials {
+		passkeys = append(passkeys, mapPasskeyStruct(credential))
+	}
+	respondWithJSON(w, http.StatusOK, passkeys)
+}
+
+func handleDeletePasskey(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	principal, _ := principalFromContext(r.Context())
+
+	passkeyID, err := uuid.Parse(r.PathValue("passkeyID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid id field")
+		return
+	}
+	deleted, err := c.Db.DeleteWebAuthnCredential(r.Context(), database.DeleteWebAuthnCredentialParams{
+		ID:     passkeyID,
+		UserID: principal.UserID,
+	})
+	if err != nil {
+		fmt.Println("Error deleting passkey: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error deleting passkey")
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "No passkey with that id")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleBeginPasskeyLogin starts an assertion. With an email the browser is
+// told which credentials to use; without one it offers discoverable
+// credentials. Unknown emails get an empty list rather than an error.
+func handleBeginPasskeyLogin(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	requestData, err := decodeStringFields(r)
+	if err != nil && err != io.EOF {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	userID := uuid.NullUUID{}
+	allowed := []credentialDescriptor{}
+	if email, err := auth.NormalizeEmail(requestData["email"]); err == nil {
+		user, err := c.Db.GetUserByEmail(r.Context(), email)
+		if err == nil {
+			userID = uuid.NullUUID{UUID: user.ID, Valid: true}
+			credentials, err := c.Db.ListWebAuthnCredentialsByUser(r.Context(), user.ID)
+			if err != nil {
+				fmt.Println("Error listing passkeys: ", err)
+				respondWithError(w, http.StatusInternalServerError, "Error starting passkey login")
+				return
+			}
+			allowed = credentialDescriptors(credentials)
+		}
+	}
+
+	challenge, err := createWebAuthnChallenge(c, r, userID, ceremonyLogin)
+	if err != nil {
+		fmt.Println("Error creating WebAuthn challenge: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error starting passkey login")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, PasskeyChallenge{
+		ChallengeID: challenge.ID,
+		PublicKey: PasskeyLoginOptions{
+			Challenge:        challenge.Challenge,
+			RPID:             c.WebAuthn.ID,
+			Timeout:          webauthnChallengeExpiry.Milliseconds(),
+			UserVerification: "preferred",
+			AllowCredentials: allowed,
+		},
+	})
+}
+
+// handleFinishPasskeyLogin verifies an assertion and logs the user in exactly
+// like a password login would. Accounts with TOTP enabled must use a passkey
+// that verified the user (PIN or biometrics), which stands in for the second
+// factor.
+func handleFinishPasskeyLogin(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	var requestData struct {
+		ChallengeID uuid.UUID `json:"challenge_id"`
+		Credential  struct {
+			ID       webauthn.Base64URL `json:"id"`
+			Response struct {
+				ClientDataJSON    webauthn.Base64URL `json:"clientDataJSON"`
+				AuthenticatorData webauthn.Base64URL `json:"authenticatorData"`
+				Signature         webauthn.Base64URL `json:"signature"`
+			} `json:"response"`
+		} `json:"credential"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&requestData)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	defer r.Body.Close()
+
+	challenge, err := c.Db.ConsumeWebAuthnChallenge(r.Context(), database.ConsumeWebAuthnChallengeParams{
+		ID:        requestData.ChallengeID,
+		Ceremony:  ceremonyLogin,
+		ExpiresAt: time.Now(),
+	})
+	if err != nil {
+		if err != sql.ErrNoRows {
+			fmt.Println("Error consuming WebAuthn challenge: ", err)
+		}
+		respondWithError(w, http.StatusUnauthorized, "Invalid or expired challenge")
+		return
+	}
+
+	stored, err := c.Db.GetWebAuthnCredentialByCredentialID(r.Context(), requestData.Credential.ID)
+	if err != nil || (challenge.UserID.Valid && challenge.UserID.UUID != stored.UserID) {
+		respondWithError(w, http.StatusUnauthorized, "Passkey could not be verified")
+		return
+	}
+	user, err := c.Db.GetUserByID(r.Context(), stored.UserID)
+	if err != nil {
+		fmt.Println("Error fetching user: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error logging in")
+		return
+	}
+
+	signCount, err := c.WebAuthn.VerifyAssertion(
+		challenge.Challenge,
+		webauthn.Credential{
+			ID:        stored.CredentialID,
+			PublicKey: stored.PublicKey,
+			SignCount: uint32(stored.SignCount),
+		},
+		requestData.Credential.Response.ClientDataJSON,
+		requestData.Credential.Response.AuthenticatorData,
+		requestData.Credential.Response.Signature,
+		user.TotpEnabledAt.Valid,
+	)
+	if errors.Is(err, webauthn.ErrSignCountRegressed) {
+		fmt.Println("Possible cloned passkey ", stored.ID, " for user ", user.ID)
+	}
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Passkey could not be verified")
+		return
+	}
+
+	// The conditional update catches two assertions racing with the same
+	// counter value.
+	updated, err := c.Db.UpdateWebAuthnSignCount(r.Context(), database.UpdateWebAuthnSignCountParams{
+		ID:         stored.ID,
+		SignCount:  int64(signCount),
+		LastUsedAt: sql.NullTime{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		fmt.Println("Error updating passkey sign count: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error logging in")
+		return
+	}
+	if updated == 0 {
+		fmt.Println("Possible cloned passkey ", stored.ID, " for user ", user.ID)
+		respondWithError(w, http.StatusUnauthorized, "Passkey could not be verified")
+		return
+	}
+
+	completeLogin(c, w, r, user)
+}
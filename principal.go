@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ablanchetMD/chirpy/internal/auth"
+	"github.com/ablanchetMD/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	authMethodJWT     = "jwt"
+	authMethodAPIKey  = "api_key"
+	authMethodSession = "session"
+	authMethodOAuth   = "oauth"
+
+	// apiKeyTouchInterval limits how often last_used_at is written for a busy
+	// key.
+	apiKeyTouchInterval = time.Minute
+
+	// reauthenticateInterval is how often long-lived connections check that
+	// their credentials are still good.
+	reauthenticateInterval = time.Minute
+)
+
+// Principal is whoever is behind an authenticated request, however they
+// authenticated.
+type Principal struct {
+	UserID    uuid.UUID
+	Method    string
+	Scopes    []string
+	APIKeyID  uuid.UUID
+	SessionID uuid.UUID
+	// ClientID is the OAuth client acting for the user, in which case
+	// SessionID is the OAuth grant.
+	ClientID uuid.UUID
+	// ExpiresAt is when the credentials stop being accepted, or zero if they
+	// do not expire.
+	ExpiresAt time.Time
+}
+
+// HasScope reports whether the principal may act within scope. Users who
+// authenticated with their own credentials may do anything; API keys and
+// OAuth clients are limited to the scopes they were granted.
+func (p Principal) HasScope(scope string) bool {
+	if p.Method == authMethodAPIKey || p.Method == authMethodOAuth {
+		return contains(p.Scopes, scope)
+	}
+	return true
+}
+
+type principalContextKey struct{}
+
+func principalFromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalContextKey{}).(Principal)
+	return principal, ok
+}
+
+var (
+	errUnauthenticated = errors.New("invalid or missing credentials")
+	errCSRF            = errors.New("missing or invalid CSRF token")
+)
+
+// authenticate resolves the request's credentials: a bearer access token, an
+// API key sent either as a bearer token or with the ApiKey scheme, or, when
+// there is no Authorization header at all, a browser session cookie.
+func authenticate(c *apiConfig, r *http.Request) (Principal, error) {
+	if r.Header.Get("Authorization") == "" {
+		return authenticateSession(c, r)
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		token, err = auth.GetAPIKey(r.Header)
+		if err != nil {
+			return Principal{}, errUnauthenticated
+		}
+	}
+
+	if !auth.IsAPIKey(token) {
+		claims, err := c.Keys.ValidateAccessToken(token)
+		if err != nil {
+			return Principal{}, errUnauthenticated
+		}
+		user, err := c.Db.GetUserByID(r.Context(), claims.UserID())
+		if err == sql.ErrNoRows {
+			return Principal{}, errUnauthenticated
+		}
+		if err != nil {
+			return Principal{}, err
+		}
+		if claims.TokenVersion != user.TokenVersion {
+			return Principal{}, errUnauthenticated
+		}
+		sessionID, _ := uuid.Parse(claims.SessionID)
+		if claims.ClientID != "" {
+			return authenticateOAuthToken(c, r, claims, sessionID)
+		}
+		// Access tokens die with the session they were issued for, so that
+		// revoking a session logs that device out straight away.
+		if sessionID != uuid.Nil {
+			session, err := c.Db.GetRefreshToken(r.Context(), sessionID)
+			if err == sql.ErrNoRows {
+				return Principal{}, errUnauthenticated
+			}
+			if err != nil {
+				return Principal{}, err
+			}
+			if session.RevokedAt.Valid || session.UserID != user.ID {
+				return Principal{}, errUnauthenticated
+			}
+		}
+		return Principal{UserID: user.ID, Method: authMethodJWT, SessionID: sessionID, ExpiresAt: claims.ExpiresAt.Time}, nil
+	}
+
+	key, err := c.Db.GetAPIKeyByHash(r.Context(), auth.HashToken(token))
+	if err == sql.ErrNoRows {
+		return Principal{}, errUnauthenticated
+	}
+	if err != nil {
+		return Principal{}, err
+	}
+	now := time.Now()
+	if key.RevokedAt.Valid || (key.ExpiresAt.Valid && now.After(key.ExpiresAt.Time)) {
+		return Principal{}, errUnauthenticated
+	}
+	if !key.LastUsedAt.Valid || now.Sub(key.LastUsedAt.Time) > apiKeyTouchInterval {
+		err = c.Db.TouchAPIKey(r.Context(), database.TouchAPIKeyParams{
+			ID:         key.ID,
+			LastUsedAt: sql.NullTime{Time: now, Valid: true},
+		})
+		if err != nil {
+			fmt.Println("Error updating API key last use: ", err)
+		}
+	}
+	principal := Principal{UserID: key.UserID, Method: authMethodAPIKey, Scopes: key.Scopes, APIKeyID: key.ID}
+	if key.ExpiresAt.Valid {
+		principal.ExpiresAt = key.ExpiresAt.Time
+	}
+	return principal, nil
+}
+
+// optionalPrincipal authenticates the request if it carries valid
+// credentials, for public endpoints whose answer depends on who is asking.
+// Anonymous callers get a zero Principal.
+func optionalPrincipal(c *apiConfig, r *http.Request) (Principal, error) {
+	principal, err := authenticate(c, r)
+	if err == errUnauthenticated || err == errCSRF {
+		return Principal{}, nil
+	}
+	return principal, err
+}
+
+// reauthenticate checks that the credentials a long-lived request, such as a
+// WebSocket or an event stream, was opened with still identify principal, so
+// that logging out, revoking the session or changing the password ends it.
+func reauthenticate(c *apiConfig, r *http.Request, principal Principal) (bool, error) {
+	current, err := authenticate(c, r)
+	if err == errUnauthenticated || err == errCSRF {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return current.UserID == principal.UserID, nil
+}
+
+// expiryTimer returns a channel that receives once principal's credentials
+// expire, and a function to release it. The channel never receives for
+// credentials that do not expire.
+func expiryTimer(principal Principal) (<-chan time.Time, func() bool) {
+	if principal.ExpiresAt.IsZero() {
+		return nil, func() bool { return false }
+	}
+	timer := time.NewTimer(time.Until(principal.ExpiresAt))
+	return timer.C, timer.Stop
+}
+
+// middlewareAuth rejects requests without valid credentials or without scope
+// and puts the Principal on the request context for next.
+func (cfg *apiConfig) middlewareAuth(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, err := authenticate(cfg, r)
+		if err == errCSRF {
+			respondWithError(w, http.StatusForbidden, "Missing or invalid CSRF token")
+			return
+		}
+		if err != nil {
+			if err != errUnauthenticated {
+				fmt.Println("Error authenticating request: ", err)
+				respondWithError(w, http.StatusInternalServerError, "Error authenticating request")
+				return
+			}
+			respondWithError(w, http.StatusUnauthorized, "Invalid or missing credentials")
+			return
+		}
+		if !principal.HasScope(scope) {
+			respondWithError(w, http.StatusForbidden, "Credentials do not grant the "+scope+" scope")
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), principalContextKey{}, principal)))
+	}
+}
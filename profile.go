@@ -0,0 +1,232 @@
+package main
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ablanchetMD/chirpy/internal/database"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	maxDisplayNameLength = 50
+	maxBioLength         = 160
+	maxAvatarURLLength   = 2048
+
+	handleConstraint = "users_handle_lower_idx"
+)
+
+var handlePattern = regexp.MustCompile(`^[A-Za-z0-9_]{3,30}$`)
+
+// reservedHandles can't be claimed, either because they would shadow a
+// route under /api/users or because they could be used to impersonate staff.
+var reservedHandles = []string{
+	"about", "admin", "administrator", "api", "app", "chirpy", "help",
+	"login", "logout", "me", "mfa", "moderator", "null", "oauth", "passkeys",
+	"password", "root", "security", "settings", "signup", "staff", "support",
+	"system", "undefined", "verify", "www",
+}
+
+// PublicUser is what anyone may see about a user. It must never carry the
+// email address or anything else from the account itself.
+type PublicUser struct {
+	ID          uuid.UUID `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	Handle      string    `json:"handle"`
+	DisplayName string    `json:"display_name"`
+	Bio         string    `json:"bio"`
+	AvatarURL   string    `json:"avatar_url"`
+	IsPrivate   bool      `json:"is_private"`
+}
+
+type Profile struct {
+	PublicUser
+	ChirpCount     int64 `json:"chirp_count"`
+	FollowerCount  int32 `json:"follower_count"`
+	FollowingCount int32 `json:"following_count"`
+}
+
+func mapPublicUserStruct(src database.User) PublicUser {
+	return PublicUser{
+		ID:          src.ID,
+		CreatedAt:   src.CreatedAt,
+		Handle:      src.Handle,
+		DisplayName: src.DisplayName,
+		Bio:         src.Bio,
+		AvatarURL:   src.AvatarUrl,
+		IsPrivate:   src.IsPrivate,
+	}
+}
+
+// validateHandle checks the format of a handle. Uniqueness is left to the
+// database, which compares handles case-insensitively.
+func validateHandle(handle string) *FieldError {
+	if !handlePattern.MatchString(handle) {
+		return &FieldError{Field: "handle", Code: "invalid", Message: "Handle must be 3 to 30 letters, digits or underscores"}
+	}
+	if contains(reservedHandles, strings.ToLower(handle)) {
+		return &FieldError{Field: "handle", Code: "reserved", Message: "That handle is reserved"}
+	}
+	return nil
+}
+
+// generateHandle picks a placeholder handle for users who sign up without
+// one.
+func generateHandle() (string, error) {
+	suffix := make([]byte, 5)
+	_, err := rand.Read(suffix)
+	if err != nil {
+		return "", err
+	}
+	return "user_" + hex.EncodeToString(suffix), nil
+}
+
+func isUniqueViolation(err error, constraint string) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == constraint
+}
+
+func validateProfileFields(displayName, bio, avatarURL string) []FieldError {
+	fieldErrors := []FieldError{}
+	if utf8.RuneCountInString(displayName) > maxDisplayNameLength {
+		fieldErrors = append(fieldErrors, FieldError{Field: "display_name", Code: "too_long", Message: fmt.Sprintf("Display name must be at most %d characters", maxDisplayNameLength)})
+	}
+	if utf8.RuneCountInString(bio) > maxBioLength {
+		fieldErrors = append(fieldErrors, FieldError{Field: "bio", Code: "too_long", Message: fmt.Sprintf("Bio must be at most %d characters", maxBioLength)})
+	}
+	if avatarURL != "" {
+		u, err := url.Parse(avatarURL)
+		if err != nil || u.Scheme != "https" || u.Host == "" || len(avatarURL) > maxAvatarURLLength {
+			fieldErrors = append(fieldErrors, FieldError{Field: "avatar_url", Code: "invalid", Message: "Avatar URL must be an https URL"})
+		}
+	}
+	return fieldErrors
+}
+
+// handleGetProfile is the public profile behind /api/users/{handle}.
+func handleGetProfile(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	user, err := c.Db.GetUserByHandle(r.Context(), strings.TrimPrefix(r.PathValue("handle"), "@"))
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "No user with that handle")
+		return
+	}
+	if err != nil {
+		fmt.Println("Error getting user: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error getting profile")
+		return
+	}
+	chirpCount, err := c.Db.CountChirpsByUser(r.Context(), user.ID)
+	if err != nil {
+		fmt.Println("Error counting chirps: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error getting profile")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, Profile{
+		PublicUser:     mapPublicUserStruct(user),
+		ChirpCount:     chirpCount,
+		FollowerCount:  user.FollowerCount,
+		FollowingCount: user.FollowingCount,
+	})
+}
+
+// handleUpdateProfile changes the caller's profile. Fields left out of the
+// request keep their current value.
+func handleUpdateProfile(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	principal, _ := principalFromContext(r.Context())
+
+	var requestData struct {
+		Handle      *string `json:"handle"`
+		DisplayName *string `json:"display_name"`
+		Bio         *string `json:"bio"`
+		AvatarURL   *string `json:"avatar_url"`
+		IsPrivate   *bool   `json:"is_private"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&requestData)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	defer r.Body.Close()
+
+	user, err := c.Db.GetUserByID(r.Context(), principal.UserID)
+	if err != nil {
+		fmt.Println("Error fetching user: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error updating profile")
+		return
+	}
+	params := database.UpdateUserProfileParams{
+		ID:          user.ID,
+		Handle:      user.Handle,
+		DisplayName: user.DisplayName,
+		Bio:         user.Bio,
+		AvatarUrl:   user.AvatarUrl,
+		IsPrivate:   user.IsPrivate,
+		UpdatedAt:   time.Now(),
+	}
+	if requestData.Handle != nil {
+		params.Handle = strings.TrimPrefix(*requestData.Handle, "@")
+	}
+	if requestData.DisplayName != nil {
+		params.DisplayName = strings.TrimSpace(*requestData.DisplayName)
+	}
+	if requestData.Bio != nil {
+		params.Bio = strings.TrimSpace(*requestData.Bio)
+	}
+	if requestData.AvatarURL != nil {
+		params.AvatarUrl = strings.TrimSpace(*requestData.AvatarURL)
+	}
+	if requestData.IsPrivate != nil {
+		params.IsPrivate = *requestData.IsPrivate
+	}
+
+	fieldErrors := validateProfileFields(params.DisplayName, params.Bio, params.AvatarUrl)
+	if params.Handle != user.Handle {
+		if fieldError := validateHandle(params.Handle); fieldError != nil {
+			fieldErrors = append(fieldErrors, *fieldError)
+		}
+	}
+	if len(fieldErrors) > 0 {
+		respondWithFieldErrors(w, fieldErrors)
+		return
+	}
+
+	wasPrivate := user.IsPrivate
+	user, err = c.Db.UpdateUserProfile(r.Context(), params)
+	if isUniqueViolation(err, handleConstraint) {
+		respondWithFieldErrors(w, []FieldError{{Field: "handle", Code: "taken", Message: "That handle is already taken"}})
+		return
+	}
+	if err != nil {
+		fmt.Println("Error updating profile: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error updating profile")
+		return
+	}
+	// Going public lets everyone who asked in.
+	if wasPrivate && !user.IsPrivate {
+		err = c.Db.AcceptAllFollowRequests(r.Context(), database.AcceptAllFollowRequestsParams{
+			FolloweeID: user.ID,
+			AcceptedAt: sql.NullTime{Time: params.UpdatedAt, Valid: true},
+		})
+		if err != nil {
+			fmt.Println("Error accepting follow requests: ", err)
+		}
+		user, err = c.Db.GetUserByID(r.Context(), user.ID)
+		if err != nil {
+			fmt.Println("Error fetching user: ", err)
+			respondWithError(w, http.StatusInternalServerError, "Error updating profile")
+			return
+		}
+	}
+	respondWithJSON(w, http.StatusOK, mapUserStruct(user))
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ablanchetMD/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// likeReaction is what POST /likes records without a reaction of its own,
+// and is always allowed.
+const likeReaction = "like"
+
+var defaultReactions = []string{"👍", "❤️", "😂", "😮", "😢", "🔥"}
+
+// newReactionSet reads the allowed emoji reactions from CHIRP_REACTIONS as a
+// comma-separated list.
+func newReactionSet() map[string]bool {
+	reactions := defaultReactions
+	if s := os.Getenv("CHIRP_REACTIONS"); s != "" {
+		reactions = strings.Split(s, ",")
+	}
+	set := map[string]bool{likeReaction: true}
+	for _, reaction := range reactions {
+		if reaction = strings.TrimSpace(reaction); reaction != "" {
+			set[reaction] = true
+		}
+	}
+	return set
+}
+
+// setViewerReactions fills in viewer's own reaction on each chirp. Anonymous
+// viewers have none, and a failed lookup leaves them unset.
+func setViewerReactions(c *apiConfig, r *http.Request, viewer uuid.UUID, chirps []*Chirp) {
+	if viewer == uuid.Nil || len(chirps) == 0 {
+		return
+	}
+	ids := make([]uuid.UUID, 0, len(chirps))
+	for _, chirp := range chirps {
+		ids = append(ids, chirp.ID)
+	}
+	rows, err := c.Db.ListViewerReactions(r.Context(), database.ListViewerReactionsParams{
+		UserID:   viewer,
+		ChirpIds: ids,
+	})
+	if err != nil {
+		fmt.Println("Error listing reactions: ", err)
+		return
+	}
+	reactions := map[uuid.UUID]string{}
+	for _, row := range rows {
+		reactions[row.ChirpID] = row.Reaction
+	}
+	for _, chirp := range chirps {
+		if reaction, ok := reactions[chirp.ID]; ok {
+			chirp.ViewerReaction = &reaction
+		}
+	}
+}
+
+func chirpRefs(chirps []Chirp) []*Chirp {
+	refs := make([]*Chirp, 0, len(chirps))
+	for i := range chirps {
+		refs = append(refs, &chirps[i])
+	}
+	return refs
+}
+
+// handleReact likes a chirp, or reacts to it with {"reaction": "..."}.
+// Reacting again replaces the caller's previous reaction.
+func handleReact(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	principal, _ := principalFromContext(r.Context())
+
+	var requestData struct {
+		Reaction string `json:"reaction"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&requestData)
+	if err != nil && err != io.EOF {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	reaction := requestData.Reaction
+	if reaction == "" {
+		reaction = likeReaction
+	}
+	if !c.Reactions[reaction] {
+		respondWithFieldErrors(w, []FieldError{{Field: "reaction", Code: "unsupported", Message: "That reaction is not available"}})
+		return
+	}
+
+	chirp, ok := chirpFromPath(c, w, r)
+	if !ok {
+		return
+	}
+	// Deleted chirps and chirps the caller may not see cannot be reacted to,
+	// which also keeps private authors from being notified by strangers.
+	_, visible, err := visibleChirp(c, r, principal.UserID, chirp.ID)
+	if err != nil {
+		fmt.Println("Error getting chirp: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error getting chirp")
+		return
+	}
+	if !visible {
+		respondWithError(w, http.StatusNotFound, "No Chirp with that id")
+		return
+	}
+	err = c.Db.UpsertReaction(r.Context(), database.UpsertReactionParams{
+		ChirpID:   chirp.ID,
+		UserID:    principal.UserID,
+		Reaction:  reaction,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		fmt.Println("Error saving reaction: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error saving reaction")
+		return
+	}
+	notify(c, r.Context(), chirp.UserID, principal.UserID, notifyLike, chirp.ID)
+
+	chirp, err = c.Db.GetChirp(r.Context(), chirp.ID)
+	if err != nil {
+		fmt.Println("Error getting chirp: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error getting chirp")
+		return
+	}
+	response := mapChirpStruct(chirp)
+	response.ViewerReaction = &reaction
+	setMentions(c, r, []*Chirp{&response})
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// handleUnreact removes the caller's like or reaction.
+func handleUnreact(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	principal, _ := principalFromContext(r.Context())
+
+	chirp, ok := chirpFromPath(c, w, r)
+	if !ok {
+		return
+	}
+	deleted, err := c.Db.DeleteReaction(r.Context(), database.DeleteReactionParams{
+		ChirpID: chirp.ID,
+		UserID:  principal.UserID,
+	})
+	if err != nil {
+		fmt.Println("Error removing reaction: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error removing reaction")
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "You have not reacted to that chirp")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ablanchetMD/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+type Rechirp struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UserID    uuid.UUID `json:"user_id"`
+	ChirpID   uuid.UUID `json:"chirp_id"`
+}
+
+func mapRechirpStruct(src database.Rechirp) Rechirp {
+	return Rechirp{
+		ID:        src.ID,
+		CreatedAt: src.CreatedAt,
+		UserID:    src.UserID,
+		ChirpID:   src.ChirpID,
+	}
+}
+
+// visibleChirp loads a chirp if viewer may see it: it is not deleted and its
+// author's account is public, followed by viewer, or viewer's own.
+func visibleChirp(c *apiConfig, r *http.Request, viewer, id uuid.UUID) (database.Chirp, bool, error) {
+	rows, err := c.Db.ListChirpsForViewer(r.Context(), database.ListChirpsForViewerParams{
+		ViewerID: viewer,
+		Ids:      []uuid.UUID{id},
+	})
+	if err != nil || len(rows) == 0 || !rows[0].Visible {
+		return database.Chirp{}, false, err
+	}
+	return rows[0].Chirp, true, nil
+}
+
+// quotedChirp loads the chirp a new chirp quotes, responding with 404 when
+// the author cannot see it.
+func quotedChirp(c *apiConfig, w http.ResponseWriter, r *http.Request, author uuid.UUID, id string) (database.Chirp, bool) {
+	quotedID, err := uuid.Parse(id)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid quoted_chirp_id field")
+		return database.Chirp{}, false
+	}
+	chirp, visible, err := visibleChirp(c, r, author, quotedID)
+	if err != nil {
+		fmt.Println("Error getting chirp: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error getting chirp")
+		return chirp, false
+	}
+	if !visible {
+		respondWithError(w, http.StatusNotFound, "The chirp being quoted does not exist")
+		return chirp, false
+	}
+	return chirp, true
+}
+
+// setQuotedChirps embeds the chirp each quote refers to. Quotes of chirps
+// that have since been deleted, or that viewer is not allowed to see, are
+// marked unavailable instead.
+func setQuotedChirps(c *apiConfig, r *http.Request, viewer uuid.UUID, chirps []*Chirp) {
+	ids := []uuid.UUID{}
+	for _, chirp := range chirps {
+		if chirp.QuotedChirpID != nil {
+			ids = append(ids, *chirp.QuotedChirpID)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+	rows, err := c.Db.ListChirpsForViewer(r.Context(), database.ListChirpsForViewerParams{
+		ViewerID: viewer,
+		Ids:      ids,
+	})
+	if err != nil {
+		fmt.Println("Error listing quoted chirps: ", err)
+	}
+	quoted := map[uuid.UUID]database.Chirp{}
+	for _, row := range rows {
+		if row.Visible {
+			quoted[row.Chirp.ID] = row.Chirp
+		}
+	}
+	for _, chirp := range chirps {
+		if chirp.QuotedChirpID == nil {
+			continue
+		}
+		original, ok := quoted[*chirp.QuotedChirpID]
+		if !ok {
+			chirp.QuotedChirpUnavailable = true
+			continue
+		}
+		embedded := mapChirpStruct(original)
+		chirp.QuotedChirp = &embedded
+	}
+}
+
+// decorateChirps adds what depends on the viewer or on other chirps to
+// chirps about to be returned.
+func decorateChirps(c *apiConfig, r *http.Request, viewer uuid.UUID, chirps []*Chirp) {
+	setViewerReactions(c, r, viewer, chirps)
+	setQuotedChirps(c, r, viewer, chirps)
+	setMentions(c, r, chirps)
+}
+
+// fanOutRechirp pushes a rechirp to the rechirper's followers in the same
+// way as fanOutChirp.
+func fanOutRechirp(c *apiConfig, r *http.Request, rechirp database.Rechirp) {
+	if c.FanoutThreshold == 0 {
+		return
+	}
+	_, err := c.Db.FanOutRechirp(r.Context(), database.FanOutRechirpParams{
+		ID:            rechirp.ID,
+		FollowerCount: int32(c.FanoutThreshold),
+	})
+	if err != nil {
+		fmt.Println("Error fanning out rechirp: ", err)
+	}
+}
+
+// handleRechirp shares a chirp with the caller's followers. Chirps from
+// private accounts cannot be rechirped, and rechirping twice is not an error.
+// Chirps the caller may not see are reported as missing.
+func handleRechirp(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	principal, _ := principalFromContext(r.Context())
+
+	chirp, ok := chirpFromPath(c, w, r)
+	if !ok {
+		return
+	}
+	_, visible, err := visibleChirp(c, r, principal.UserID, chirp.ID)
+	if err != nil {
+		fmt.Println("Error getting chirp: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error getting chirp")
+		return
+	}
+	if !visible {
+		respondWithError(w, http.StatusNotFound, "No Chirp with that id")
+		return
+	}
+	author, err := c.Db.GetUserByID(r.Context(), chirp.UserID)
+	if err != nil {
+		fmt.Println("Error getting user: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error rechirping")
+		return
+	}
+	if author.IsPrivate && author.ID != principal.UserID {
+		respondWithError(w, http.StatusForbidden, "Chirps from private accounts cannot be rechirped")
+		return
+	}
+
+	rechirp, err := c.Db.CreateRechirp(r.Context(), database.CreateRechirpParams{
+		CreatedAt: time.Now(),
+		UserID:    principal.UserID,
+		ChirpID:   chirp.ID,
+	})
+	if err == sql.ErrNoRows {
+		rechirp, err = c.Db.GetRechirp(r.Context(), database.GetRechirpParams{UserID: principal.UserID, ChirpID: chirp.ID})
+		if err == nil {
+			respondWithJSON(w, http.StatusOK, mapRechirpStruct(rechirp))
+			return
+		}
+	}
+	if err != nil {
+		fmt.Println("Error rechirping: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error rechirping")
+		return
+	}
+	fanOutRechirp(c, r, rechirp)
+	notify(c, r.Context(), chirp.UserID, principal.UserID, notifyRechirp, chirp.ID)
+	respondWithJSON(w, http.StatusCreated, mapRechirpStruct(rechirp))
+}
+
+// handleUndoRechirp removes the caller's rechirp of a chirp, and with it the
+// entries it added to timelines.
+func handleUndoRechirp(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	principal, _ := principalFromContext(r.Context())
+
+	chirp, ok := chirpFromPath(c, w, r)
+	if !ok {
+		return
+	}
+	deleted, err := c.Db.DeleteRechirp(r.Context(), database.DeleteRechirpParams{
+		UserID:  principal.UserID,
+		ChirpID: chirp.ID,
+	})
+	if err != nil {
+		fmt.Println("Error undoing rechirp: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error undoing rechirp")
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "You have not rechirped that chirp")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
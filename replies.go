@@ -0,0 +1,222 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ablanchetMD/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultThreadDepth = 10
+	maxThreadDepth     = 50
+)
+
+// ThreadChirp is a chirp within a thread. Depth is counted from the chirp
+// the thread was requested for; a chirp at the depth limit whose replies
+// were left out still reports how many it has.
+type ThreadChirp struct {
+	Chirp
+	Depth      int   `json:"depth"`
+	ReplyCount int64 `json:"reply_count"`
+}
+
+func nullUUIDPtr(id uuid.NullUUID) *uuid.UUID {
+	if !id.Valid {
+		return nil
+	}
+	return &id.UUID
+}
+
+func mapThreadChirp(src database.GetThreadRow) ThreadChirp {
+	return ThreadChirp{
+		Chirp: mapChirpStruct(database.Chirp{
+			ID:             src.ID,
+			CreatedAt:      src.CreatedAt,
+			UpdatedAt:      src.UpdatedAt,
+			Body:           src.Body,
+			UserID:         src.UserID,
+			FannedOut:      src.FannedOut,
+			InReplyToID:    src.InReplyToID,
+			ConversationID: src.ConversationID,
+			DeletedAt:      src.DeletedAt,
+			ReactionCount:  src.ReactionCount,
+			ReactionCounts: src.ReactionCounts,
+			QuotedChirpID:  src.QuotedChirpID,
+			SearchLanguage: src.SearchLanguage,
+		}),
+		Depth:      int(src.Depth),
+		ReplyCount: src.ReplyCount,
+	}
+}
+
+func threadChirpCursor(chirp ThreadChirp) pageCursor {
+	return pageCursor{CreatedAt: chirp.CreatedAt, ID: chirp.ID}
+}
+
+// chirpFromPath loads the chirp named by the {chirpID} path value, including
+// deleted ones, responding with an error when there is none.
+func chirpFromPath(c *apiConfig, w http.ResponseWriter, r *http.Request) (database.Chirp, bool) {
+	id, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid id field")
+		return database.Chirp{}, false
+	}
+	chirp, err := c.Db.GetChirp(r.Context(), id)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "No Chirp with that id")
+		return chirp, false
+	}
+	if err != nil {
+		fmt.Println("Error getting chirp: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error getting chirp")
+		return chirp, false
+	}
+	return chirp, true
+}
+
+// replyParent loads the chirp a new chirp replies to. Deleted chirps and
+// chirps the author cannot see cannot be replied to.
+func replyParent(c *apiConfig, w http.ResponseWriter, r *http.Request, author uuid.UUID, id string) (database.Chirp, bool) {
+	parentID, err := uuid.Parse(id)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid in_reply_to_id field")
+		return database.Chirp{}, false
+	}
+	parent, visible, err := visibleChirp(c, r, author, parentID)
+	if err != nil {
+		fmt.Println("Error getting chirp: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error getting chirp")
+		return parent, false
+	}
+	if !visible {
+		respondWithError(w, http.StatusNotFound, "The chirp being replied to does not exist")
+		return parent, false
+	}
+	return parent, true
+}
+
+// threadRoot loads the chirp named by the {chirpID} path value for a thread,
+// responding with 404 when viewer may not see its author's chirps. Deleted
+// chirps are kept, so the replies beneath them stay reachable.
+func threadRoot(c *apiConfig, w http.ResponseWriter, r *http.Request, viewer uuid.UUID) (database.Chirp, bool) {
+	root, ok := chirpFromPath(c, w, r)
+	if !ok {
+		return root, false
+	}
+	author, err := c.Db.GetUserByID(r.Context(), root.UserID)
+	if err != nil {
+		fmt.Println("Error getting chirp author: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error getting chirp")
+		return root, false
+	}
+	// Private accounts hide their chirps from the same people as their
+	// follow lists.
+	visible, err := canSeeFollowers(c, r, viewer, author)
+	if err != nil {
+		fmt.Println("Error checking chirp visibility: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error getting chirp")
+		return root, false
+	}
+	if !visible {
+		respondWithError(w, http.StatusNotFound, "No Chirp with that id")
+		return root, false
+	}
+	return root, true
+}
+
+// handleDeleteChirp deletes one of the caller's chirps. The row is kept as a
+// tombstone so that replies to it are not orphaned.
+func handleDeleteChirp(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	principal, _ := principalFromContext(r.Context())
+
+	chirp, ok := chirpFromPath(c, w, r)
+	if !ok {
+		return
+	}
+	if chirp.DeletedAt.Valid {
+		respondWithError(w, http.StatusNotFound, "No Chirp with that id")
+		return
+	}
+	if chirp.UserID != principal.UserID {
+		respondWithError(w, http.StatusForbidden, "You can only delete your own chirps")
+		return
+	}
+	deletedAt := time.Now()
+	_, err := c.Db.TombstoneChirp(r.Context(), database.TombstoneChirpParams{
+		ID:        chirp.ID,
+		DeletedAt: sql.NullTime{Time: deletedAt, Valid: true},
+	})
+	if err != nil {
+		fmt.Println("Error deleting chirp: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error deleting chirp")
+		return
+	}
+	enqueueWebhookEvent(c, r.Context(), webhookChirpDeleted, chirp.UserID, map[string]any{
+		"id":         chirp.ID,
+		"user_id":    chirp.UserID,
+		"deleted_at": deletedAt,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleGetThread returns a chirp and the replies beneath it, oldest first.
+// Replies always come after what they reply to, so clients can build the
+// tree page by page. ?depth= limits how many levels are followed. Replies
+// from private accounts the caller may not see are left out, along with
+// everything beneath them.
+func handleGetThread(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	viewer, err := optionalPrincipal(c, r)
+	if err != nil {
+		fmt.Println("Error authenticating request: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error authenticating request")
+		return
+	}
+	root, ok := threadRoot(c, w, r, viewer.UserID)
+	if !ok {
+		return
+	}
+	depth := defaultThreadDepth
+	if s := r.URL.Query().Get("depth"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			respondWithError(w, http.StatusBadRequest, "Invalid depth")
+			return
+		}
+		depth = min(n, maxThreadDepth)
+	}
+	cursor, limit, err := parseAscendingPagination(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid cursor or limit")
+		return
+	}
+
+	rows, err := c.Db.GetThread(r.Context(), database.GetThreadParams{
+		ID:        root.ID,
+		Depth:     int32(depth),
+		ViewerID:  viewer.UserID,
+		CreatedAt: cursor.CreatedAt,
+		CursorID:  cursor.ID,
+		Limit:     int32(limit + 1),
+	})
+	if err != nil {
+		fmt.Println("Error getting thread: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error getting thread")
+		return
+	}
+	chirps := []ThreadChirp{}
+	for _, row := range rows {
+		chirps = append(chirps, mapThreadChirp(row))
+	}
+	page := makePage(chirps, limit, threadChirpCursor)
+	ptrs := make([]*Chirp, 0, len(page.Items))
+	for i := range page.Items {
+		ptrs = append(ptrs, &page.Items[i].Chirp)
+	}
+	decorateChirps(c, r, viewer.UserID, ptrs)
+	respondWithJSON(w, http.StatusOK, page)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/ablanchetMD/chirpy/internal/auth"
+)
+
+// clientIP returns the address of the client that made the request. The
+// X-Forwarded-For header is only honoured when TRUST_PROXY is set, since
+// otherwise any client could pick its own address.
+func clientIP(c *apiConfig, r *http.Request) string {
+	if c.TrustProxy {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// isAdmin reports whether the request may use the /admin endpoints: either the
+// server runs on the dev platform or the request carries ADMIN_TOKEN.
+func isAdmin(c *apiConfig, r *http.Request) bool {
+	if c.Platform == "dev" {
+		return true
+	}
+	if c.AdminToken == "" {
+		return false
+	}
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(c.AdminToken)) == 1
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/ablanchetMD/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultSearchLanguage = "english"
+	// maxSearchOffset bounds how deep search results can be paged; past it
+	// the query should be narrowed instead.
+	maxSearchOffset = 1000
+	maxSearchTerms  = 16
+)
+
+// ts_headline marks matches with these control characters, which are
+// swapped for <mark> tags once the rest of the snippet has been escaped.
+const (
+	snippetStart = "\x02"
+	snippetStop  = "\x03"
+)
+
+// SearchResult is a chirp matching a search. Snippet is HTML: the chirp body
+// escaped, with matching words wrapped in <mark>.
+type SearchResult struct {
+	Chirp
+	Rank    float32 `json:"rank"`
+	Snippet string  `json:"snippet"`
+}
+
+// newSearchLanguage reads SEARCH_LANGUAGE, the Postgres text search
+// configuration (such as "english", "french" or "simple") used to index and
+// query chirps.
+func newSearchLanguage() string {
+	if language := strings.TrimSpace(os.Getenv("SEARCH_LANGUAGE")); language != "" {
+		return language
+	}
+	return defaultSearchLanguage
+}
+
+// checkSearchLanguage makes sure the configured search language exists, as
+// chirps could not be saved otherwise. A database that cannot be reached yet
+// is not an error here.
+func checkSearchLanguage(c *apiConfig) error {
+	_, err := c.Db.GetSearchConfig(context.Background(), c.SearchLanguage)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("unknown text search configuration %q", c.SearchLanguage)
+	}
+	if err != nil {
+		fmt.Println("Error checking search language: ", err)
+	}
+	return nil
+}
+
+// searchWords splits s into the words the text search parser would see.
+func searchWords(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// buildTSQuery turns a search box query into to_tsquery syntax. Words must
+// all match; "quoted words" must appear in that order, a trailing * matches
+// any word starting with what precedes it, and a leading - excludes a word.
+// Everything else is treated as a word separator, so the result is always
+// valid to_tsquery input. It returns "" when q contains no words.
+func buildTSQuery(q string) string {
+	var terms []string
+	add := func(term string) {
+		if len(terms) < maxSearchTerms {
+			terms = append(terms, term)
+		}
+	}
+
+	for i, part := range strings.Split(q, `"`) {
+		if i%2 == 1 {
+			if words := searchWords(part); len(words) > 0 {
+				add("(" + strings.Join(words, " <-> ") + ")")
+			}
+			continue
+		}
+		for _, field := range strings.Fields(part) {
+			negate := strings.HasPrefix(field, "-")
+			prefix := strings.HasSuffix(field, "*")
+			words := searchWords(field)
+			if len(words) == 0 {
+				continue
+			}
+			if prefix {
+				words[len(words)-1] += ":*"
+			}
+			term := strings.Join(words, " <-> ")
+			if len(words) > 1 {
+				term = "(" + term + ")"
+			}
+			if negate {
+				term = "!" + term
+			}
+			add(term)
+		}
+	}
+	return strings.Join(terms, " & ")
+}
+
+func highlightSnippet(snippet string) string {
+	escaped := html.EscapeString(snippet)
+	escaped = strings.ReplaceAll(escaped, snippetStart, "<mark>")
+	return strings.ReplaceAll(escaped, snippetStop, "</mark>")
+}
+
+// parseSearchDate accepts an RFC 3339 timestamp or a plain date.
+func parseSearchDate(s string) (sql.NullTime, error) {
+	if s == "" {
+		return sql.NullTime{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		t, err = time.Parse(time.DateOnly, s)
+	}
+	if err != nil {
+		return sql.NullTime{}, err
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}, nil
+}
+
+// Search results are ranked rather than ordered by time, so their cursor is
+// an opaque offset.
+func encodeSearchCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(offset)))
+}
+
+func decodeSearchCursor(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return 0, errInvalidCursor
+	}
+	offset, err := strconv.Atoi(string(raw))
+	if err != nil || offset < 0 || offset > maxSearchOffset {
+		return 0, errInvalidCursor
+	}
+	return offset, nil
+}
+
+// handleSearchChirps searches chirp bodies with ?q=, most relevant first.
+// Results can be narrowed with ?author= (a handle) and ?since= and ?until=.
+func handleSearchChirps(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	viewer, err := optionalPrincipal(c, r)
+	if err != nil {
+		fmt.Println("Error authenticating request: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error authenticating request")
+		return
+	}
+
+	query := r.URL.Query()
+	var fieldErrors []FieldError
+	tsquery := buildTSQuery(query.Get("q"))
+	if tsquery == "" {
+		fieldErrors = append(fieldErrors, FieldError{Field: "q", Code: "required", Message: "Enter something to search for"})
+	}
+	since, err := parseSearchDate(query.Get("since"))
+	if err != nil {
+		fieldErrors = append(fieldErrors, FieldError{Field: "since", Code: "invalid", Message: "Use a date such as 2024-05-01 or an RFC 3339 timestamp"})
+	}
+	until, err := parseSearchDate(query.Get("until"))
+	if err != nil {
+		fieldErrors = append(fieldErrors, FieldError{Field: "until", Code: "invalid", Message: "Use a date such as 2024-05-01 or an RFC 3339 timestamp"})
+	}
+	offset, err := decodeSearchCursor(query.Get("cursor"))
+	if err != nil {
+		fieldErrors = append(fieldErrors, FieldError{Field: "cursor", Code: "invalid", Message: "Invalid cursor"})
+	}
+	limit, err := parseLimit(r)
+	if err != nil {
+		fieldErrors = append(fieldErrors, FieldError{Field: "limit", Code: "invalid", Message: "Invalid limit"})
+	}
+	if len(fieldErrors) > 0 {
+		respondWithFieldErrors(w, fieldErrors)
+		return
+	}
+
+	var authorID uuid.NullUUID
+	if handle := query.Get("author"); handle != "" {
+		author, err := c.Db.GetUserByHandle(r.Context(), strings.TrimPrefix(handle, "@"))
+		if err == sql.ErrNoRows {
+			respondWithJSON(w, http.StatusOK, Page[SearchResult]{Items: []SearchResult{}})
+			return
+		}
+		if err != nil {
+			fmt.Println("Error getting user: ", err)
+			respondWithError(w, http.StatusInternalServerError, "Error searching chirps")
+			return
+		}
+		authorID = uuid.NullUUID{UUID: author.ID, Valid: true}
+	}
+
+	rows, err := c.Db.SearchChirps(r.Context(), database.SearchChirpsParams{
+		Language: c.SearchLanguage,
+		Query:    tsquery,
+		ViewerID: viewer.UserID,
+		AuthorID: authorID,
+		Since:    since,
+		Until:    until,
+		Limit:    int32(limit + 1),
+		Offset:   int32(offset),
+	})
+	if err != nil {
+		fmt.Println("Error searching chirps: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error searching chirps")
+		return
+	}
+
+	page := Page[SearchResult]{Items: []SearchResult{}}
+	for i, row := range rows {
+		if i == limit {
+			if next := offset + limit; next <= maxSearchOffset {
+				page.NextCursor = encodeSearchCursor(next)
+			}
+			break
+		}
+		page.Items = append(page.Items, SearchResult{
+			Chirp:   mapChirpStruct(row.Chirp),
+			Rank:    row.Rank,
+			Snippet: highlightSnippet(row.Snippet),
+		})
+	}
+	ptrs := make([]*Chirp, 0, len(page.Items))
+	for i := range page.Items {
+		ptrs = append(ptrs, &page.Items[i].Chirp)
+	}
+	decorateChirps(c, r, viewer.UserID, ptrs)
+	respondWithJSON(w, http.StatusOK, page)
+}
@@ -0,0 +1,366 @@
+package main
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/ablanchetMD/chirpy/internal/auth"
+	"github.com/ablanchetMD/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	sessionCookieName = "chirpy_session"
+	csrfCookieName    = "chirpy_csrf"
+	csrfHeaderName    = "X-CSRF-Token"
+
+	sessionExpiry        = 30 * 24 * time.Hour
+	sessionTouchInterval = time.Minute
+)
+
+type SessionResponse struct {
+	User
+	CSRFToken string `json:"csrf_token"`
+}
+
+// wantsCookieSession reports whether the client asked for a browser session
+// instead of bearer tokens, which the web app does with ?mode=cookie.
+func wantsCookieSession(r *http.Request) bool {
+	return r.URL.Query().Get("mode") == "cookie"
+}
+
+func setSessionCookies(w http.ResponseWriter, token, csrfToken string, expires time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	// The CSRF cookie must stay readable by JavaScript so the app can echo it
+	// back in the X-CSRF-Token header.
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookieName,
+		Value:    csrfToken,
+		Path:     "/",
+		Expires:  expires,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func clearSessionCookies(w http.ResponseWriter) {
+	for _, name := range []string{sessionCookieName, csrfCookieName} {
+		http.SetCookie(w, &http.Cookie{
+			Name:     name,
+			Value:    "",
+			Path:     "/",
+			MaxAge:   -1,
+			HttpOnly: name == sessionCookieName,
+			Secure:   true,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+}
+
+func startCookieSession(c *apiConfig, w http.ResponseWriter, r *http.Request, user database.User) {
+	token, err := auth.MakeRandomToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating session")
+		return
+	}
+	csrfToken, err := auth.MakeRandomToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating session")
+		return
+	}
+
+	now := time.Now()
+	session, err := c.Db.CreateSession(r.Context(), database.CreateSessionParams{
+		CreatedAt: now,
+		UserID:    user.ID,
+		TokenHash: auth.HashToken(token),
+		CsrfToken: csrfToken,
+		UserAgent: r.UserAgent(),
+		Ip:        clientIP(c, r),
+		ExpiresAt: now.Add(sessionExpiry),
+	})
+	if err != nil {
+		fmt.Println("Error creating session: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error creating session")
+		return
+	}
+
+	setSessionCookies(w, token, csrfToken, session.ExpiresAt)
+	respondWithJSON(w, http.StatusOK, SessionResponse{User: mapUserStruct(user), CSRFToken: csrfToken})
+}
+
+// authenticateSession resolves the session cookie. Unsafe methods must also
+// carry the CSRF token both in the header and in its cookie (double submit),
+// and it must be the token bound to this session.
+func authenticateSession(c *apiConfig, r *http.Request) (Principal, error) {
+	cookie, err := r.Cookie(sessionCookieName)
+	if err != nil || cookie.Value == "" {
+		return Principal{}, errUnauthenticated
+	}
+
+	session, err := c.Db.GetSessionByTokenHash(r.Context(), auth.HashToken(cookie.Value))
+	if err == sql.ErrNoRows {
+		return Principal{}, errUnauthenticated
+	}
+	if err != nil {
+		return Principal{}, err
+	}
+	now := time.Now()
+	if session.RevokedAt.Valid || now.After(session.ExpiresAt) {
+		return Principal{}, errUnauthenticated
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+	default:
+		header := r.Header.Get(csrfHeaderName)
+		csrfCookie, err := r.Cookie(csrfCookieName)
+		if err != nil || header == "" ||
+			subtle.ConstantTimeCompare([]byte(header), []byte(csrfCookie.Value)) != 1 ||
+			subtle.ConstantTimeCompare([]byte(header), []byte(session.CsrfToken)) != 1 {
+			return Principal{}, errCSRF
+		}
+	}
+
+	if now.Sub(session.LastUsedAt) > sessionTouchInterval {
+		err = c.Db.TouchSession(r.Context(), database.TouchSessionParams{
+			ID:         session.ID,
+			LastUsedAt: now,
+		})
+		if err != nil {
+			fmt.Println("Error updating session last use: ", err)
+		}
+	}
+	return Principal{UserID: session.UserID, Method: authMethodSession, SessionID: session.ID, ExpiresAt: session.ExpiresAt}, nil
+}
+
+func handleLogout(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	principal, _ := principalFromContext(r.Context())
+	if principal.Method != authMethodSession {
+		respondWithError(w, http.StatusBadRequest, "Only cookie sessions can be logged out")
+		return
+	}
+
+	err := c.Db.RevokeSession(r.Context(), database.RevokeSessionParams{
+		ID:        principal.SessionID,
+		RevokedAt: sql.NullTime{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		fmt.Println("Error revoking session: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error logging out")
+		return
+	}
+	clearSessionCookies(w)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleRefresh trades a refresh token, sent as a bearer token, for a new
+// access token.
+func handleRefresh(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing refresh token")
+		return
+	}
+	refreshToken, err := c.Db.GetRefreshTokenByHash(r.Context(), auth.HashToken(token))
+	if err != nil || refreshToken.RevokedAt.Valid || time.Now().After(refreshToken.ExpiresAt) {
+		respondWithError(w, http.StatusUnauthorized, "Invalid or expired refresh token")
+		return
+	}
+	user, err := c.Db.GetUserByID(r.Context(), refreshToken.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid or expired refresh token")
+		return
+	}
+
+	err = c.Db.TouchRefreshToken(r.Context(), database.TouchRefreshTokenParams{
+		ID:         refreshToken.ID,
+		LastUsedAt: time.Now(),
+	})
+	if err != nil {
+		fmt.Println("Error updating refresh token last use: ", err)
+	}
+	accessToken, err := c.Keys.MakeAccessToken(user.ID, user.TokenVersion, refreshToken.ID.String(), accessTokenExpiry)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating access token")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]string{"token": accessToken})
+}
+
+func handleRevoke(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing refresh token")
+		return
+	}
+	refreshToken, err := c.Db.GetRefreshTokenByHash(r.Context(), auth.HashToken(token))
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid refresh token")
+		return
+	}
+	_, err = c.Db.RevokeUserRefreshToken(r.Context(), database.RevokeUserRefreshTokenParams{
+		ID:        refreshToken.ID,
+		UserID:    refreshToken.UserID,
+		RevokedAt: sql.NullTime{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		fmt.Println("Error revoking refresh token: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error revoking refresh token")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ActiveSession describes one signed-in device: a refresh token held by an
+// API client or a browser cookie session.
+type ActiveSession struct {
+	ID         uuid.UUID `json:"id"`
+	Type       string    `json:"type"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
+
+func handleListSessions(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	principal, _ := principalFromContext(r.Context())
+	now := time.Now()
+
+	refreshTokens, err := c.Db.ListActiveRefreshTokensByUser(r.Context(), database.ListActiveRefreshTokensByUserParams{
+		UserID:    principal.UserID,
+		ExpiresAt: now,
+	})
+	if err != nil {
+		fmt.Println("Error listing refresh tokens: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error listing sessions")
+		return
+	}
+	sessions, err := c.Db.ListActiveSessionsByUser(r.Context(), database.ListActiveSessionsByUserParams{
+		UserID:    principal.UserID,
+		ExpiresAt: now,
+	})
+	if err != nil {
+		fmt.Println("Error listing sessions: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error listing sessions")
+		return
+	}
+
+	active := []ActiveSession{}
+	for _, token := range refreshTokens {
+		active = append(active, ActiveSession{
+			ID:         token.ID,
+			Type:       "refresh_token",
+			UserAgent:  token.UserAgent,
+			IP:         token.Ip,
+			CreatedAt:  token.CreatedAt,
+			LastUsedAt: token.LastUsedAt,
+			ExpiresAt:  token.ExpiresAt,
+			Current:    token.ID == principal.SessionID,
+		})
+	}
+	for _, session := range sessions {
+		active = append(active, ActiveSession{
+			ID:         session.ID,
+			Type:       "cookie",
+			UserAgent:  session.UserAgent,
+			IP:         session.Ip,
+			CreatedAt:  session.CreatedAt,
+			LastUsedAt: session.LastUsedAt,
+			ExpiresAt:  session.ExpiresAt,
+			Current:    session.ID == principal.SessionID,
+		})
+	}
+	sort.Slice(active, func(i, j int) bool {
+		return active[i].LastUsedAt.After(active[j].LastUsedAt)
+	})
+	respondWithJSON(w, http.StatusOK, active)
+}
+
+func handleDeleteSession(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	principal, _ := principalFromContext(r.Context())
+
+	sessionID, err := uuid.Parse(r.PathValue("sessionID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid id field")
+		return
+	}
+	revokedAt := sql.NullTime{Time: time.Now(), Valid: true}
+	revoked, err := c.Db.RevokeUserRefreshToken(r.Context(), database.RevokeUserRefreshTokenParams{
+		ID:        sessionID,
+		UserID:    principal.UserID,
+		RevokedAt: revokedAt,
+	})
+	if err == nil && revoked == 0 {
+		revoked, err = c.Db.RevokeUserSession(r.Context(), database.RevokeUserSessionParams{
+			ID:        sessionID,
+			UserID:    principal.UserID,
+			RevokedAt: revokedAt,
+		})
+	}
+	if err != nil {
+		fmt.Println("Error revoking session: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error revoking session")
+		return
+	}
+	if revoked == 0 {
+		respondWithError(w, http.StatusNotFound, "No session with that id")
+		return
+	}
+	if sessionID == principal.SessionID && principal.Method == authMethodSession {
+		clearSessionCookies(w)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleLogoutAll revokes every refresh token and cookie session of the user
+// and bumps their token version so access tokens already handed out stop
+// working too.
+func handleLogoutAll(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	principal, _ := principalFromContext(r.Context())
+	now := time.Now()
+
+	err := inTx(c, r.Context(), func(q *database.Queries) error {
+		_, err := q.IncrementUserTokenVersion(r.Context(), database.IncrementUserTokenVersionParams{
+			ID:        principal.UserID,
+			UpdatedAt: now,
+		})
+		if err != nil {
+			return err
+		}
+		err = q.RevokeAllUserRefreshTokens(r.Context(), database.RevokeAllUserRefreshTokensParams{
+			UserID:    principal.UserID,
+			RevokedAt: sql.NullTime{Time: now, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+		return q.RevokeAllUserSessions(r.Context(), database.RevokeAllUserSessionsParams{
+			UserID:    principal.UserID,
+			RevokedAt: sql.NullTime{Time: now, Valid: true},
+		})
+	})
+	if err != nil {
+		fmt.Println("Error logging out everywhere: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error logging out everywhere")
+		return
+	}
+	if principal.Method == authMethodSession {
+		clearSessionCookies(w)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,25 @@
+-- name: CreateBookmark :exec
+INSERT INTO bookmarks (user_id, chirp_id, created_at)
+VALUES (
+    $1,
+    $2,
+    $3
+)
+ON CONFLICT (user_id, chirp_id) DO NOTHING;
+
+-- name: DeleteBookmark :execrows
+DELETE FROM bookmarks WHERE user_id = $1 AND chirp_id = $2;
+
+-- name: ListBookmarks :many
+SELECT bookmarks.created_at AS bookmarked_at, sqlc.embed(chirps)
+FROM bookmarks
+JOIN chirps ON chirps.id = bookmarks.chirp_id
+JOIN users ON users.id = chirps.user_id
+WHERE bookmarks.user_id = $1
+  AND (NOT users.is_private OR users.id = $1 OR EXISTS (
+      SELECT 1 FROM follows
+      WHERE follows.follower_id = $1 AND follows.followee_id = users.id AND follows.status = 'accepted'
+  ))
+  AND (bookmarks.created_at < $2 OR (bookmarks.created_at = $2 AND bookmarks.chirp_id < $3))
+ORDER BY bookmarks.created_at DESC, bookmarks.chirp_id DESC
+LIMIT $4;
@@ -1,19 +1,68 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, in_reply_to_id, quoted_chirp_id, search_language)
 VALUES (
     gen_random_uuid(),
     $1,
     $2,
     $3,
-    $4
+    $4,
+    $5,
+    $6,
+    $7
 )
 RETURNING *;
 
 -- name: GetChirps :many
-SELECT * FROM chirps ORDER BY created_at ASC;
+SELECT chirps.* FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirps.deleted_at IS NULL
+  AND (NOT users.is_private OR users.id = sqlc.arg('viewer_id') OR EXISTS (
+    SELECT 1 FROM follows
+    WHERE follows.follower_id = sqlc.arg('viewer_id') AND follows.followee_id = users.id AND follows.status = 'accepted'
+  ))
+ORDER BY chirps.created_at ASC;
 
 -- name: GetChirp :one
 SELECT * FROM chirps WHERE id = $1;
 
 -- name: DeleteChirps :exec
-DELETE FROM chirps;
\ No newline at end of file
+DELETE FROM chirps;
+
+-- name: CountChirpsByUser :one
+SELECT COUNT(*) FROM chirps WHERE user_id = $1 AND deleted_at IS NULL;
+
+-- name: TombstoneChirp :execrows
+UPDATE chirps
+SET body = '', deleted_at = $2, updated_at = $2
+WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: GetThread :many
+WITH RECURSIVE thread AS (
+    SELECT chirps.*, 0 AS depth
+    FROM chirps
+    WHERE chirps.id = sqlc.arg('id')
+  UNION ALL
+    SELECT chirps.*, thread.depth + 1
+    FROM chirps
+    JOIN thread ON chirps.in_reply_to_id = thread.id
+    JOIN users ON users.id = chirps.user_id
+    WHERE thread.depth < sqlc.arg('depth')
+      AND (NOT users.is_private OR users.id = sqlc.arg('viewer_id') OR EXISTS (
+        SELECT 1 FROM follows
+        WHERE follows.follower_id = sqlc.arg('viewer_id') AND follows.followee_id = users.id AND follows.status = 'accepted'
+      ))
+)
+SELECT thread.id, thread.created_at, thread.updated_at, thread.body, thread.user_id, thread.fanned_out,
+    thread.in_reply_to_id, thread.conversation_id, thread.deleted_at, thread.reaction_count,
+    thread.reaction_counts, thread.quoted_chirp_id, thread.search_language, thread.depth,
+    (SELECT COUNT(*) FROM chirps AS replies
+     JOIN users AS repliers ON repliers.id = replies.user_id
+     WHERE replies.in_reply_to_id = thread.id
+       AND (NOT repliers.is_private OR repliers.id = sqlc.arg('viewer_id') OR EXISTS (
+         SELECT 1 FROM follows
+         WHERE follows.follower_id = sqlc.arg('viewer_id') AND follows.followee_id = repliers.id AND follows.status = 'accepted'
+       ))) AS reply_count
+FROM thread
+WHERE thread.created_at > sqlc.arg('created_at') OR (thread.created_at = sqlc.arg('created_at') AND thread.id > sqlc.arg('cursor_id'))
+ORDER BY thread.created_at, thread.id
+LIMIT sqlc.arg('limit');
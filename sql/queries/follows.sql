@@ -0,0 +1,52 @@
+-- name: CreateFollow :one
+INSERT INTO follows (follower_id, followee_id, created_at, status, accepted_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+ON CONFLICT (follower_id, followee_id) DO NOTHING
+RETURNING *;
+
+-- name: GetFollow :one
+SELECT * FROM follows WHERE follower_id = $1 AND followee_id = $2;
+
+-- name: DeleteFollow :execrows
+DELETE FROM follows WHERE follower_id = $1 AND followee_id = $2;
+
+-- name: AcceptFollowRequest :execrows
+UPDATE follows
+SET status = 'accepted', accepted_at = $3
+WHERE follower_id = $1 AND followee_id = $2 AND status = 'pending';
+
+-- name: DenyFollowRequest :execrows
+DELETE FROM follows
+WHERE follower_id = $1 AND followee_id = $2 AND status = 'pending';
+
+-- name: AcceptAllFollowRequests :exec
+UPDATE follows
+SET status = 'accepted', accepted_at = $2
+WHERE followee_id = $1 AND status = 'pending';
+
+-- name: ListFollowers :many
+SELECT sqlc.embed(users), follows.created_at AS followed_at
+FROM follows
+JOIN users ON users.id = follows.follower_id
+WHERE follows.followee_id = $1 AND follows.status = $2
+  AND (follows.created_at < $3 OR (follows.created_at = $3 AND follows.follower_id < $4))
+ORDER BY follows.created_at DESC, follows.follower_id DESC
+LIMIT $5;
+
+-- name: ListFollowing :many
+SELECT sqlc.embed(users), follows.created_at AS followed_at
+FROM follows
+JOIN users ON users.id = follows.followee_id
+WHERE follows.follower_id = $1 AND follows.status = 'accepted'
+  AND (follows.created_at < $2 OR (follows.created_at = $2 AND follows.followee_id < $3))
+ORDER BY follows.created_at DESC, follows.followee_id DESC
+LIMIT $4;
+
+-- name: ListFolloweeIDs :many
+SELECT followee_id FROM follows WHERE follower_id = $1 AND status = 'accepted';
@@ -0,0 +1,76 @@
+-- name: AddChirpHashtags :exec
+WITH tags AS (
+    INSERT INTO hashtags (tag)
+    SELECT unnest(sqlc.arg('tags')::text[])
+    ON CONFLICT (tag) DO UPDATE SET tag = EXCLUDED.tag
+    RETURNING id
+)
+INSERT INTO chirp_hashtags (chirp_id, hashtag_id, created_at)
+SELECT sqlc.arg('chirp_id')::uuid, tags.id, sqlc.arg('created_at')::timestamp
+FROM tags
+ON CONFLICT DO NOTHING;
+
+-- name: ListHashtagChirps :many
+SELECT sqlc.embed(chirps)
+FROM chirp_hashtags
+JOIN hashtags ON hashtags.id = chirp_hashtags.hashtag_id
+JOIN chirps ON chirps.id = chirp_hashtags.chirp_id
+JOIN users ON users.id = chirps.user_id
+WHERE hashtags.tag = sqlc.arg('tag') AND chirps.deleted_at IS NULL
+  AND (NOT users.is_private OR users.id = sqlc.arg('viewer_id') OR EXISTS (
+    SELECT 1 FROM follows
+    WHERE follows.follower_id = sqlc.arg('viewer_id') AND follows.followee_id = users.id AND follows.status = 'accepted'
+  ))
+  AND (chirp_hashtags.created_at < sqlc.arg('created_at') OR (chirp_hashtags.created_at = sqlc.arg('created_at') AND chirp_hashtags.chirp_id < sqlc.arg('chirp_id')))
+ORDER BY chirp_hashtags.created_at DESC, chirp_hashtags.chirp_id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: RefreshTrendingHashtags :exec
+WITH scores AS (
+    SELECT chirp_hashtags.hashtag_id,
+        SUM(power(0.5, EXTRACT(EPOCH FROM sqlc.arg('now')::timestamp - chirp_hashtags.created_at) / sqlc.arg('half_life_seconds')::float8))::float8 AS score,
+        COUNT(*) AS uses,
+        COUNT(DISTINCT chirps.user_id) AS authors
+    FROM chirp_hashtags
+    JOIN chirps ON chirps.id = chirp_hashtags.chirp_id
+    JOIN users ON users.id = chirps.user_id
+    WHERE chirp_hashtags.created_at >= sqlc.arg('window_start')
+      AND chirps.deleted_at IS NULL AND NOT users.is_private
+    GROUP BY chirp_hashtags.hashtag_id
+    HAVING COUNT(DISTINCT chirps.user_id) >= sqlc.arg('min_authors')::bigint
+    ORDER BY score DESC
+    LIMIT sqlc.arg('limit')
+),
+stale AS (
+    DELETE FROM trending_hashtags
+    WHERE hashtag_id NOT IN (SELECT hashtag_id FROM scores)
+)
+INSERT INTO trending_hashtags (hashtag_id, score, uses, authors, computed_at)
+SELECT hashtag_id, score, uses, authors, sqlc.arg('now')
+FROM scores
+ON CONFLICT (hashtag_id) DO UPDATE SET
+    score = EXCLUDED.score,
+    uses = EXCLUDED.uses,
+    authors = EXCLUDED.authors,
+    computed_at = EXCLUDED.computed_at;
+
+-- name: ListTrendingHashtags :many
+SELECT hashtags.tag, trending_hashtags.score, trending_hashtags.uses, trending_hashtags.authors, trending_hashtags.computed_at
+FROM trending_hashtags
+JOIN hashtags ON hashtags.id = trending_hashtags.hashtag_id
+ORDER BY trending_hashtags.score DESC
+LIMIT $1;
+
+-- name: GetHashtagBackfill :one
+SELECT * FROM hashtag_backfill;
+
+-- name: ListChirpsForHashtagBackfill :many
+SELECT * FROM chirps
+WHERE (body LIKE '%#%' OR body LIKE '%＃%')
+  AND (created_at > $1 OR (created_at = $1 AND id > $2))
+ORDER BY created_at, id
+LIMIT $3;
+
+-- name: UpdateHashtagBackfill :exec
+UPDATE hashtag_backfill
+SET cursor_created_at = $1, cursor_id = $2, completed_at = $3;
@@ -0,0 +1,38 @@
+-- name: GetLoginAttempt :one
+SELECT * FROM login_attempts WHERE key = $1;
+
+-- name: RecordLoginAttempt :one
+INSERT INTO login_attempts AS attempts (key, failures, last_failure_at, locked_until)
+VALUES (
+    sqlc.arg('key'),
+    1,
+    sqlc.arg('now'),
+    login_lock_until(1, sqlc.arg('now'), sqlc.arg('threshold')::integer, sqlc.arg('base_delay')::float8, sqlc.arg('max_delay')::float8)
+)
+ON CONFLICT (key) DO UPDATE
+SET failures = CASE
+        WHEN attempts.last_failure_at < sqlc.arg('reset_before') THEN 1
+        ELSE attempts.failures + 1
+    END,
+    last_failure_at = sqlc.arg('now'),
+    locked_until = login_lock_until(
+        CASE WHEN attempts.last_failure_at < sqlc.arg('reset_before') THEN 1 ELSE attempts.failures + 1 END,
+        sqlc.arg('now'), sqlc.arg('threshold')::integer, sqlc.arg('base_delay')::float8, sqlc.arg('max_delay')::float8
+    )
+WHERE attempts.locked_until IS NULL OR attempts.locked_until <= sqlc.arg('now')
+RETURNING *;
+
+-- name: RefundLoginAttempt :exec
+UPDATE login_attempts
+SET failures = GREATEST(failures - 1, 0),
+    locked_until = CASE WHEN failures - 1 < sqlc.arg('threshold')::integer THEN NULL ELSE locked_until END
+WHERE key = sqlc.arg('key');
+
+-- name: DeleteLoginAttempt :exec
+DELETE FROM login_attempts WHERE key = $1;
+
+-- name: PruneLoginAttempts :execrows
+DELETE FROM login_attempts
+WHERE starts_with(key, sqlc.arg('prefix'))
+  AND last_failure_at < sqlc.arg('before')
+  AND (locked_until IS NULL OR locked_until <= sqlc.arg('now'));
@@ -0,0 +1,15 @@
+-- name: CreateMagicLink :one
+INSERT INTO magic_links (id, created_at, user_id, expires_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3
+)
+RETURNING *;
+
+-- name: UseMagicLink :one
+UPDATE magic_links
+SET used_at = $2
+WHERE id = $1 AND used_at IS NULL AND expires_at > $2
+RETURNING *;
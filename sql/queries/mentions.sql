@@ -0,0 +1,20 @@
+-- name: AddChirpMentions :many
+WITH mentioned AS (
+    INSERT INTO chirp_mentions (chirp_id, user_id, handle)
+    SELECT sqlc.arg('chirp_id')::uuid, users.id, lower(users.handle)
+    FROM users
+    WHERE lower(users.handle) = ANY(sqlc.arg('handles')::text[])
+    ON CONFLICT DO NOTHING
+    RETURNING user_id
+)
+SELECT mentioned.user_id
+FROM mentioned
+JOIN users AS authors ON authors.id = sqlc.arg('author_id')::uuid
+WHERE NOT authors.is_private OR EXISTS (
+    SELECT 1 FROM follows
+    WHERE follows.follower_id = mentioned.user_id AND follows.followee_id = authors.id AND follows.status = 'accepted'
+);
+
+-- name: ListChirpMentions :many
+SELECT chirp_id, user_id, handle FROM chirp_mentions
+WHERE chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[]);
@@ -0,0 +1,63 @@
+-- name: CreateNotification :exec
+WITH notification AS (
+    INSERT INTO notifications (created_at, updated_at, user_id, actor_id, type, chirp_id, group_key)
+    SELECT sqlc.arg('created_at')::timestamp, sqlc.arg('created_at')::timestamp, sqlc.arg('user_id')::uuid, sqlc.arg('actor_id')::uuid, sqlc.arg('type')::text, sqlc.narg('chirp_id')::uuid, sqlc.narg('group_key')::text
+    WHERE NOT EXISTS (
+        SELECT 1 FROM notification_preferences
+        WHERE notification_preferences.user_id = sqlc.arg('user_id')::uuid AND notification_preferences.type = sqlc.arg('type')::text AND NOT notification_preferences.enabled
+    )
+    ON CONFLICT (user_id, group_key) WHERE read_at IS NULL
+    DO UPDATE SET updated_at = EXCLUDED.updated_at, actor_id = EXCLUDED.actor_id
+    RETURNING id
+)
+INSERT INTO notification_actors (notification_id, actor_id, created_at)
+SELECT notification.id, sqlc.arg('actor_id')::uuid, sqlc.arg('created_at')::timestamp
+FROM notification
+ON CONFLICT (notification_id, actor_id) DO UPDATE SET created_at = EXCLUDED.created_at;
+
+-- name: ListNotifications :many
+SELECT sqlc.embed(notifications),
+    (SELECT COUNT(*) FROM notification_actors WHERE notification_actors.notification_id = notifications.id) AS actor_count
+FROM notifications
+WHERE notifications.user_id = sqlc.arg('user_id')
+  AND (NOT sqlc.arg('unread_only')::boolean OR notifications.read_at IS NULL)
+  AND (notifications.updated_at < sqlc.arg('updated_at') OR (notifications.updated_at = sqlc.arg('updated_at') AND notifications.id < sqlc.arg('id')))
+ORDER BY notifications.updated_at DESC, notifications.id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: GetNotification :one
+SELECT sqlc.embed(notifications),
+    (SELECT COUNT(*) FROM notification_actors WHERE notification_actors.notification_id = notifications.id) AS actor_count
+FROM notifications
+WHERE notifications.id = $1 AND notifications.user_id = $2;
+
+-- name: ListNotificationActors :many
+SELECT ranked.notification_id, sqlc.embed(users)
+FROM (
+    SELECT notification_actors.notification_id, notification_actors.actor_id,
+        row_number() OVER (PARTITION BY notification_actors.notification_id ORDER BY notification_actors.created_at DESC) AS position
+    FROM notification_actors
+    WHERE notification_actors.notification_id = ANY(sqlc.arg('notification_ids')::uuid[])
+) AS ranked
+JOIN users ON users.id = ranked.actor_id
+WHERE ranked.position <= sqlc.arg('per_notification')::bigint
+ORDER BY ranked.notification_id, ranked.position;
+
+-- name: CountUnreadNotifications :one
+SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL;
+
+-- name: MarkNotificationRead :execrows
+UPDATE notifications SET read_at = COALESCE(read_at, $3::timestamp)
+WHERE id = $1 AND user_id = $2;
+
+-- name: MarkAllNotificationsRead :execrows
+UPDATE notifications SET read_at = $2::timestamp
+WHERE user_id = $1 AND read_at IS NULL;
+
+-- name: ListNotificationPreferences :many
+SELECT * FROM notification_preferences WHERE user_id = $1;
+
+-- name: SetNotificationPreference :exec
+INSERT INTO notification_preferences (user_id, type, enabled)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, type) DO UPDATE SET enabled = EXCLUDED.enabled;
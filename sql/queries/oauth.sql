@@ -0,0 +1,85 @@
+-- name: CreateOAuthClient :one
+INSERT INTO oauth_clients (id, created_at, owner_id, name, client_secret_hash, redirect_uris, scopes)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
+)
+RETURNING *;
+
+-- name: GetOAuthClient :one
+SELECT * FROM oauth_clients WHERE id = $1 AND revoked_at IS NULL;
+
+-- name: ListOAuthClientsByOwner :many
+SELECT * FROM oauth_clients
+WHERE owner_id = $1 AND revoked_at IS NULL
+ORDER BY created_at DESC;
+
+-- name: RevokeOAuthClient :execrows
+UPDATE oauth_clients
+SET revoked_at = $3
+WHERE id = $1 AND owner_id = $2 AND revoked_at IS NULL;
+
+-- name: CreateOAuthAuthorizationCode :one
+INSERT INTO oauth_authorization_codes (id, created_at, client_id, user_id, code_hash, redirect_uri, scopes, code_challenge, expires_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8
+)
+RETURNING *;
+
+-- name: GetOAuthAuthorizationCodeByHash :one
+SELECT * FROM oauth_authorization_codes WHERE code_hash = $1;
+
+-- name: UseOAuthAuthorizationCode :one
+UPDATE oauth_authorization_codes
+SET used_at = $2
+WHERE code_hash = $1 AND used_at IS NULL
+RETURNING *;
+
+-- name: CreateOAuthGrant :one
+INSERT INTO oauth_grants (id, created_at, client_id, user_id, code_id, scopes, refresh_token_hash, last_used_at, expires_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $1,
+    $7
+)
+RETURNING *;
+
+-- name: GetOAuthGrant :one
+SELECT * FROM oauth_grants WHERE id = $1;
+
+-- name: GetOAuthGrantByRefreshTokenHash :one
+SELECT * FROM oauth_grants WHERE refresh_token_hash = $1;
+
+-- name: RotateOAuthRefreshToken :one
+UPDATE oauth_grants
+SET refresh_token_hash = $2, last_used_at = $3
+WHERE refresh_token_hash = $1 AND revoked_at IS NULL AND expires_at > $3
+RETURNING *;
+
+-- name: RevokeOAuthGrant :exec
+UPDATE oauth_grants SET revoked_at = $2 WHERE id = $1 AND revoked_at IS NULL;
+
+-- name: RevokeOAuthGrantsByCode :exec
+UPDATE oauth_grants SET revoked_at = $2 WHERE code_id = $1 AND revoked_at IS NULL;
+
+-- name: RevokeOAuthGrantsByClient :exec
+UPDATE oauth_grants SET revoked_at = $2 WHERE client_id = $1 AND revoked_at IS NULL;
@@ -0,0 +1,18 @@
+-- name: UpsertReaction :exec
+INSERT INTO chirp_reactions (chirp_id, user_id, reaction, created_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4
+)
+ON CONFLICT (chirp_id, user_id) DO UPDATE
+SET reaction = EXCLUDED.reaction, created_at = EXCLUDED.created_at
+WHERE chirp_reactions.reaction <> EXCLUDED.reaction;
+
+-- name: DeleteReaction :execrows
+DELETE FROM chirp_reactions WHERE chirp_id = $1 AND user_id = $2;
+
+-- name: ListViewerReactions :many
+SELECT chirp_id, reaction FROM chirp_reactions
+WHERE user_id = sqlc.arg('user_id') AND chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[]);
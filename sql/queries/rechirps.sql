@@ -0,0 +1,26 @@
+-- name: CreateRechirp :one
+INSERT INTO rechirps (id, created_at, user_id, chirp_id)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3
+)
+ON CONFLICT (user_id, chirp_id) DO NOTHING
+RETURNING *;
+
+-- name: GetRechirp :one
+SELECT * FROM rechirps WHERE user_id = $1 AND chirp_id = $2;
+
+-- name: DeleteRechirp :execrows
+DELETE FROM rechirps WHERE user_id = $1 AND chirp_id = $2;
+
+-- name: ListChirpsForViewer :many
+SELECT sqlc.embed(chirps),
+    (chirps.deleted_at IS NULL AND (NOT users.is_private OR users.id = sqlc.arg('viewer_id') OR EXISTS (
+        SELECT 1 FROM follows
+        WHERE follows.follower_id = sqlc.arg('viewer_id') AND follows.followee_id = users.id AND follows.status = 'accepted'
+    )))::boolean AS visible
+FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirps.id = ANY(sqlc.arg('ids')::uuid[]);
@@ -0,0 +1,23 @@
+-- name: GetSearchConfig :one
+SELECT cfgname::text AS name FROM pg_ts_config WHERE cfgname = $1;
+
+-- name: SearchChirps :many
+SELECT sqlc.embed(chirps),
+    ts_rank_cd(to_tsvector(chirps.search_language, chirps.body), query)::real AS rank,
+    ts_headline(chirps.search_language, chirps.body, query,
+        format('StartSel=%s, StopSel=%s, MaxFragments=2, MinWords=5, MaxWords=20', chr(2), chr(3))) AS snippet
+FROM chirps
+JOIN users ON users.id = chirps.user_id,
+    to_tsquery(sqlc.arg('language')::regconfig, sqlc.arg('query')) AS query
+WHERE to_tsvector(chirps.search_language, chirps.body) @@ query
+  AND chirps.search_language = sqlc.arg('language')::regconfig
+  AND chirps.deleted_at IS NULL
+  AND (NOT users.is_private OR users.id = sqlc.arg('viewer_id') OR EXISTS (
+    SELECT 1 FROM follows
+    WHERE follows.follower_id = sqlc.arg('viewer_id') AND follows.followee_id = users.id AND follows.status = 'accepted'
+  ))
+  AND (sqlc.narg('author_id')::uuid IS NULL OR chirps.user_id = sqlc.narg('author_id'))
+  AND (sqlc.narg('since')::timestamp IS NULL OR chirps.created_at >= sqlc.narg('since'))
+  AND (sqlc.narg('until')::timestamp IS NULL OR chirps.created_at < sqlc.narg('until'))
+ORDER BY rank DESC, chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
@@ -0,0 +1,38 @@
+-- name: CreateSession :one
+INSERT INTO sessions (id, created_at, user_id, token_hash, csrf_token, user_agent, ip, last_used_at, expires_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $1,
+    $7
+)
+RETURNING *;
+
+-- name: GetSessionByTokenHash :one
+SELECT * FROM sessions WHERE token_hash = $1;
+
+-- name: TouchSession :exec
+UPDATE sessions SET last_used_at = $2 WHERE id = $1;
+
+-- name: RevokeSession :exec
+UPDATE sessions SET revoked_at = $2 WHERE id = $1 AND revoked_at IS NULL;
+
+-- name: ListActiveSessionsByUser :many
+SELECT * FROM sessions
+WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > $2
+ORDER BY last_used_at DESC;
+
+-- name: RevokeUserSession :execrows
+UPDATE sessions
+SET revoked_at = $3
+WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL;
+
+-- name: RevokeAllUserSessions :exec
+UPDATE sessions
+SET revoked_at = $2
+WHERE user_id = $1 AND revoked_at IS NULL;
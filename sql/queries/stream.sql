@@ -0,0 +1,13 @@
+-- name: ListStreamChirps :many
+SELECT sqlc.embed(chirps)
+FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirps.deleted_at IS NULL
+  AND (NOT sqlc.arg('filter_authors')::boolean OR chirps.user_id = ANY(sqlc.arg('author_ids')::uuid[]))
+  AND (NOT users.is_private OR users.id = sqlc.arg('viewer_id') OR EXISTS (
+    SELECT 1 FROM follows
+    WHERE follows.follower_id = sqlc.arg('viewer_id') AND follows.followee_id = users.id AND follows.status = 'accepted'
+  ))
+  AND (chirps.created_at > sqlc.arg('created_at') OR (chirps.created_at = sqlc.arg('created_at') AND chirps.id > sqlc.arg('id')))
+ORDER BY chirps.created_at ASC, chirps.id ASC
+LIMIT sqlc.arg('limit');
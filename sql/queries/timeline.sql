@@ -0,0 +1,74 @@
+-- name: FanOutChirp :execrows
+WITH pushed AS (
+    UPDATE chirps
+    SET fanned_out = true
+    FROM users
+    WHERE chirps.id = $1 AND users.id = chirps.user_id AND users.follower_count < $2
+    RETURNING chirps.id, chirps.user_id, chirps.created_at
+)
+INSERT INTO timeline_entries (user_id, id, chirp_id, author_id, created_at)
+SELECT follows.follower_id, pushed.id, pushed.id, pushed.user_id, pushed.created_at
+FROM pushed
+JOIN follows ON follows.followee_id = pushed.user_id AND follows.status = 'accepted'
+UNION ALL
+SELECT pushed.user_id, pushed.id, pushed.id, pushed.user_id, pushed.created_at
+FROM pushed;
+
+-- name: FanOutRechirp :execrows
+WITH pushed AS (
+    UPDATE rechirps
+    SET fanned_out = true
+    FROM users
+    WHERE rechirps.id = $1 AND users.id = rechirps.user_id AND users.follower_count < $2
+    RETURNING rechirps.id, rechirps.chirp_id, rechirps.user_id, rechirps.created_at
+)
+INSERT INTO timeline_entries (user_id, id, chirp_id, rechirp_id, author_id, created_at)
+SELECT follows.follower_id, pushed.id, pushed.chirp_id, pushed.id, pushed.user_id, pushed.created_at
+FROM pushed
+JOIN follows ON follows.followee_id = pushed.user_id AND follows.status = 'accepted'
+UNION ALL
+SELECT pushed.user_id, pushed.id, pushed.chirp_id, pushed.id, pushed.user_id, pushed.created_at
+FROM pushed;
+
+-- name: GetTimeline :many
+SELECT timeline_entries.id AS item_id, timeline_entries.created_at AS item_created_at,
+    rechirps.id AS rechirp_id, rechirps.user_id AS rechirped_by, sqlc.embed(chirps)
+FROM timeline_entries
+JOIN chirps ON chirps.id = timeline_entries.chirp_id
+JOIN users ON users.id = chirps.user_id
+LEFT JOIN rechirps ON rechirps.id = timeline_entries.rechirp_id
+WHERE timeline_entries.user_id = $1 AND chirps.deleted_at IS NULL
+  AND (NOT users.is_private OR users.id = $1 OR EXISTS (
+    SELECT 1 FROM follows
+    WHERE follows.follower_id = $1 AND follows.followee_id = users.id AND follows.status = 'accepted'
+  ))
+  AND (timeline_entries.created_at < $2 OR (timeline_entries.created_at = $2 AND timeline_entries.id < $3))
+UNION ALL
+SELECT chirps.id, chirps.created_at, NULL, NULL, sqlc.embed(chirps)
+FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE NOT chirps.fanned_out AND chirps.deleted_at IS NULL
+  AND (NOT users.is_private OR users.id = $1 OR EXISTS (
+    SELECT 1 FROM follows
+    WHERE follows.follower_id = $1 AND follows.followee_id = users.id AND follows.status = 'accepted'
+  ))
+  AND (chirps.user_id = $1 OR chirps.user_id IN (
+    SELECT followee_id FROM follows WHERE follower_id = $1 AND status = 'accepted'
+  ))
+  AND (chirps.created_at < $2 OR (chirps.created_at = $2 AND chirps.id < $3))
+UNION ALL
+SELECT rechirps.id, rechirps.created_at, rechirps.id, rechirps.user_id, sqlc.embed(chirps)
+FROM rechirps
+JOIN chirps ON chirps.id = rechirps.chirp_id
+JOIN users ON users.id = chirps.user_id
+WHERE NOT rechirps.fanned_out AND chirps.deleted_at IS NULL
+  AND (NOT users.is_private OR users.id = $1 OR EXISTS (
+    SELECT 1 FROM follows
+    WHERE follows.follower_id = $1 AND follows.followee_id = users.id AND follows.status = 'accepted'
+  ))
+  AND (rechirps.user_id = $1 OR rechirps.user_id IN (
+    SELECT followee_id FROM follows WHERE follower_id = $1 AND status = 'accepted'
+  ))
+  AND (rechirps.created_at < $2 OR (rechirps.created_at = $2 AND rechirps.id < $3))
+ORDER BY item_created_at DESC, item_id DESC
+LIMIT $4;
@@ -1,16 +1,67 @@
 -- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email, password)
+INSERT INTO users (id, created_at, updated_at, email, password, handle)
 VALUES (
     gen_random_uuid(),
     $1,
     $2,
     $3,
-    $4
+    $4,
+    $5
 )
 RETURNING *;
 
 -- name: GetUserByEmail :one
-SELECT * FROM users WHERE email = $1;
+SELECT * FROM users WHERE lower(email) = lower($1);
+
+-- name: GetUserByID :one
+SELECT * FROM users WHERE id = $1;
+
+-- name: GetUserByHandle :one
+SELECT * FROM users WHERE lower(handle) = lower($1);
+
+-- name: VerifyUserEmail :one
+UPDATE users
+SET email_verified_at = $3, updated_at = $3
+WHERE id = $1 AND email = $2
+RETURNING *;
 
 -- name: DeleteUsers :exec
 DELETE FROM users;
+
+-- name: SetUserTOTPSecret :exec
+UPDATE users
+SET totp_secret = $2, totp_enabled_at = NULL, updated_at = $3
+WHERE id = $1;
+
+-- name: EnableUserTOTP :exec
+UPDATE users
+SET totp_enabled_at = $2, updated_at = $2
+WHERE id = $1;
+
+-- name: UseUserTOTPCounter :execrows
+UPDATE users
+SET totp_last_counter = sqlc.arg('counter')
+WHERE id = sqlc.arg('id') AND (totp_last_counter IS NULL OR totp_last_counter < sqlc.arg('counter'));
+
+-- name: DisableUserTOTP :exec
+UPDATE users
+SET totp_secret = NULL, totp_enabled_at = NULL, updated_at = $2
+WHERE id = $1;
+
+-- name: IncrementUserTokenVersion :one
+UPDATE users
+SET token_version = token_version + 1, updated_at = $2
+WHERE id = $1
+RETURNING token_version;
+
+-- name: UpdateUserPassword :one
+UPDATE users
+SET password = $2, updated_at = $3, token_version = token_version + 1
+WHERE id = $1
+RETURNING *;
+
+-- name: UpdateUserProfile :one
+UPDATE users
+SET handle = $2, display_name = $3, bio = $4, avatar_url = $5, is_private = $6, updated_at = $7
+WHERE id = $1
+RETURNING *;
@@ -0,0 +1,46 @@
+-- name: CreateWebAuthnCredential :one
+INSERT INTO webauthn_credentials (id, created_at, user_id, name, credential_id, public_key, sign_count, aaguid)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7
+)
+RETURNING *;
+
+-- name: GetWebAuthnCredentialByCredentialID :one
+SELECT * FROM webauthn_credentials WHERE credential_id = $1;
+
+-- name: ListWebAuthnCredentialsByUser :many
+SELECT * FROM webauthn_credentials
+WHERE user_id = $1
+ORDER BY created_at DESC;
+
+-- name: UpdateWebAuthnSignCount :execrows
+UPDATE webauthn_credentials
+SET sign_count = $2, last_used_at = $3
+WHERE id = $1 AND (sign_count < $2 OR $2 = 0);
+
+-- name: DeleteWebAuthnCredential :execrows
+DELETE FROM webauthn_credentials WHERE id = $1 AND user_id = $2;
+
+-- name: CreateWebAuthnChallenge :one
+INSERT INTO webauthn_challenges (id, created_at, user_id, ceremony, challenge, expires_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+RETURNING *;
+
+-- name: ConsumeWebAuthnChallenge :one
+DELETE FROM webauthn_challenges
+WHERE id = $1 AND ceremony = $2 AND expires_at > $3
+RETURNING *;
@@ -0,0 +1,101 @@
+-- name: CreateWebhookEndpoint :one
+INSERT INTO webhook_endpoints (created_at, updated_at, user_id, url, secret, event_types)
+VALUES (
+    sqlc.arg('created_at'),
+    sqlc.arg('created_at'),
+    sqlc.narg('user_id'),
+    sqlc.arg('url'),
+    sqlc.arg('secret'),
+    sqlc.arg('event_types')
+)
+RETURNING *;
+
+-- name: ListWebhookEndpoints :many
+SELECT * FROM webhook_endpoints
+WHERE user_id IS NOT DISTINCT FROM sqlc.narg('user_id')
+ORDER BY created_at DESC;
+
+-- name: GetWebhookEndpoint :one
+SELECT * FROM webhook_endpoints
+WHERE id = sqlc.arg('id') AND user_id IS NOT DISTINCT FROM sqlc.narg('user_id');
+
+-- name: UpdateWebhookEndpoint :one
+UPDATE webhook_endpoints
+SET url = sqlc.arg('url'),
+    event_types = sqlc.arg('event_types'),
+    enabled = sqlc.arg('enabled'),
+    consecutive_failures = CASE WHEN sqlc.arg('enabled') AND NOT enabled THEN 0 ELSE consecutive_failures END,
+    failing_since = CASE WHEN sqlc.arg('enabled') AND NOT enabled THEN NULL ELSE failing_since END,
+    disabled_at = CASE WHEN sqlc.arg('enabled') THEN NULL ELSE COALESCE(disabled_at, sqlc.arg('updated_at')) END,
+    updated_at = sqlc.arg('updated_at')
+WHERE id = sqlc.arg('id') AND user_id IS NOT DISTINCT FROM sqlc.narg('user_id')
+RETURNING *;
+
+-- name: DeleteWebhookEndpoint :execrows
+DELETE FROM webhook_endpoints
+WHERE id = sqlc.arg('id') AND user_id IS NOT DISTINCT FROM sqlc.narg('user_id');
+
+-- name: EnqueueWebhookEvent :exec
+INSERT INTO webhook_deliveries (created_at, endpoint_id, event_id, event_type, payload, next_attempt_at)
+SELECT sqlc.arg('created_at')::timestamp, id, sqlc.arg('event_id')::uuid, sqlc.arg('event_type')::text, sqlc.arg('payload')::text, sqlc.arg('created_at')::timestamp
+FROM webhook_endpoints
+WHERE enabled AND sqlc.arg('event_type')::text = ANY(event_types)
+  AND (user_id IS NULL OR user_id = sqlc.narg('owner_id')::uuid);
+
+-- name: ClaimWebhookDeliveries :many
+UPDATE webhook_deliveries
+SET next_attempt_at = sqlc.arg('lease_until')
+FROM webhook_endpoints
+WHERE webhook_endpoints.id = webhook_deliveries.endpoint_id
+  AND webhook_deliveries.id IN (
+    SELECT pending.id FROM webhook_deliveries AS pending
+    JOIN webhook_endpoints AS endpoints ON endpoints.id = pending.endpoint_id
+    WHERE pending.status = 'pending' AND pending.next_attempt_at <= sqlc.arg('now') AND endpoints.enabled
+    ORDER BY pending.next_attempt_at
+    LIMIT sqlc.arg('limit')
+    FOR UPDATE OF pending SKIP LOCKED
+  )
+RETURNING webhook_deliveries.id, webhook_deliveries.endpoint_id, webhook_deliveries.event_type, webhook_deliveries.payload, webhook_deliveries.attempts, webhook_endpoints.url, webhook_endpoints.secret;
+
+-- name: RecordWebhookAttempt :exec
+UPDATE webhook_deliveries
+SET status = sqlc.arg('status'),
+    attempts = attempts + 1,
+    next_attempt_at = sqlc.arg('next_attempt_at'),
+    last_attempt_at = sqlc.arg('attempted_at'),
+    response_status = sqlc.narg('response_status'),
+    response_body = sqlc.arg('response_body'),
+    error = sqlc.arg('error'),
+    completed_at = sqlc.narg('completed_at')
+WHERE id = sqlc.arg('id');
+
+-- name: RecordWebhookEndpointSuccess :exec
+UPDATE webhook_endpoints
+SET consecutive_failures = 0, failing_since = NULL
+WHERE id = $1;
+
+-- name: RecordWebhookEndpointFailure :one
+UPDATE webhook_endpoints
+SET consecutive_failures = consecutive_failures + 1,
+    failing_since = COALESCE(failing_since, sqlc.arg('now')),
+    enabled = enabled AND COALESCE(failing_since, sqlc.arg('now')) > sqlc.arg('disable_before'),
+    disabled_at = CASE
+      WHEN enabled AND COALESCE(failing_since, sqlc.arg('now')) <= sqlc.arg('disable_before') THEN sqlc.arg('now')
+      ELSE disabled_at
+    END
+WHERE id = sqlc.arg('id')
+RETURNING enabled;
+
+-- name: ListWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE endpoint_id = sqlc.arg('endpoint_id')
+  AND (created_at < sqlc.arg('created_at') OR (created_at = sqlc.arg('created_at') AND id < sqlc.arg('id')))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: RedeliverWebhook :one
+INSERT INTO webhook_deliveries (created_at, endpoint_id, event_id, event_type, payload, next_attempt_at)
+SELECT sqlc.arg('created_at')::timestamp, endpoint_id, event_id, event_type, payload, sqlc.arg('created_at')::timestamp
+FROM webhook_deliveries
+WHERE webhook_deliveries.id = sqlc.arg('id') AND webhook_deliveries.endpoint_id = sqlc.arg('endpoint_id')
+RETURNING *;
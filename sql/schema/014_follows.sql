@@ -0,0 +1,55 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN is_private BOOLEAN NOT NULL DEFAULT false;
+
+CREATE TABLE follows (
+  follower_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  followee_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  status TEXT NOT NULL DEFAULT 'accepted',
+  accepted_at TIMESTAMP,
+  PRIMARY KEY (follower_id, followee_id),
+  CHECK (follower_id <> followee_id),
+  CHECK (status IN ('accepted', 'pending'))
+);
+
+CREATE INDEX follows_followee_idx ON follows (followee_id, status, created_at DESC);
+CREATE INDEX follows_follower_idx ON follows (follower_id, status, created_at DESC);
+
+-- Follow counts on users are kept in step by a trigger so that every way a
+-- follow can appear or disappear, including cascading deletes, is counted.
+-- +goose StatementBegin
+CREATE FUNCTION update_follow_counts() RETURNS TRIGGER AS $$
+BEGIN
+  IF TG_OP = 'DELETE' THEN
+    IF OLD.status = 'accepted' THEN
+      UPDATE users SET follower_count = follower_count - 1 WHERE id = OLD.followee_id;
+      UPDATE users SET following_count = following_count - 1 WHERE id = OLD.follower_id;
+    END IF;
+    RETURN NULL;
+  END IF;
+  IF TG_OP = 'UPDATE' THEN
+    IF OLD.status = 'accepted' THEN
+      RETURN NULL;
+    END IF;
+  END IF;
+  IF NEW.status = 'accepted' THEN
+    UPDATE users SET follower_count = follower_count + 1 WHERE id = NEW.followee_id;
+    UPDATE users SET following_count = following_count + 1 WHERE id = NEW.follower_id;
+  END IF;
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER follows_update_counts
+AFTER INSERT OR UPDATE OF status OR DELETE ON follows
+FOR EACH ROW EXECUTE FUNCTION update_follow_counts();
+
+-- +goose Down
+DROP TRIGGER follows_update_counts ON follows;
+DROP FUNCTION update_follow_counts();
+DROP TABLE follows;
+
+ALTER TABLE users
+DROP COLUMN is_private;
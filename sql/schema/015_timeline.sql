@@ -0,0 +1,61 @@
+-- +goose Up
+-- Chirps from accounts below the fan-out threshold are copied into their
+-- followers' timelines when posted (push); fanned_out records which ones so
+-- that the rest can be gathered at read time (pull).
+ALTER TABLE chirps
+ADD COLUMN fanned_out BOOLEAN NOT NULL DEFAULT false;
+
+CREATE INDEX chirps_pull_idx ON chirps (user_id, created_at DESC, id DESC) WHERE NOT fanned_out;
+
+CREATE TABLE timeline_entries (
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+  author_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  created_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (user_id, chirp_id)
+);
+
+CREATE INDEX timeline_entries_user_idx ON timeline_entries (user_id, created_at DESC, chirp_id DESC);
+CREATE INDEX timeline_entries_author_idx ON timeline_entries (user_id, author_id);
+
+-- Pushed chirps only reach followers who were following when they were
+-- posted, so timelines are backfilled when a follow is accepted and pruned
+-- when it goes away.
+-- +goose StatementBegin
+CREATE FUNCTION update_follow_timeline() RETURNS TRIGGER AS $$
+BEGIN
+  IF TG_OP = 'DELETE' THEN
+    IF OLD.status = 'accepted' THEN
+      DELETE FROM timeline_entries WHERE user_id = OLD.follower_id AND author_id = OLD.followee_id;
+    END IF;
+    RETURN NULL;
+  END IF;
+  IF TG_OP = 'UPDATE' AND OLD.status = 'accepted' THEN
+    RETURN NULL;
+  END IF;
+  IF NEW.status = 'accepted' THEN
+    INSERT INTO timeline_entries (user_id, chirp_id, author_id, created_at)
+    SELECT NEW.follower_id, id, user_id, created_at
+    FROM chirps
+    WHERE user_id = NEW.followee_id AND fanned_out
+    ORDER BY created_at DESC
+    LIMIT 200
+    ON CONFLICT DO NOTHING;
+  END IF;
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER follows_update_timeline
+AFTER INSERT OR UPDATE OF status OR DELETE ON follows
+FOR EACH ROW EXECUTE FUNCTION update_follow_timeline();
+
+-- +goose Down
+DROP TRIGGER follows_update_timeline ON follows;
+DROP FUNCTION update_follow_timeline();
+DROP TABLE timeline_entries;
+DROP INDEX chirps_pull_idx;
+
+ALTER TABLE chirps
+DROP COLUMN fanned_out;
@@ -0,0 +1,41 @@
+-- +goose Up
+-- Deleted chirps keep their row, with the body cleared and deleted_at set,
+-- so that replies to them still hang together.
+ALTER TABLE chirps
+ADD COLUMN in_reply_to_id UUID REFERENCES chirps(id) ON DELETE SET NULL,
+ADD COLUMN conversation_id UUID,
+ADD COLUMN deleted_at TIMESTAMP;
+
+UPDATE chirps SET conversation_id = id;
+
+ALTER TABLE chirps
+ALTER COLUMN conversation_id SET NOT NULL;
+
+CREATE INDEX chirps_in_reply_to_idx ON chirps (in_reply_to_id, created_at, id);
+CREATE INDEX chirps_conversation_idx ON chirps (conversation_id);
+
+-- A reply joins its parent's conversation; anything else starts its own.
+-- +goose StatementBegin
+CREATE FUNCTION set_chirp_conversation() RETURNS TRIGGER AS $$
+BEGIN
+  NEW.conversation_id := COALESCE(
+    (SELECT conversation_id FROM chirps WHERE id = NEW.in_reply_to_id),
+    NEW.id
+  );
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER chirps_set_conversation
+BEFORE INSERT ON chirps
+FOR EACH ROW EXECUTE FUNCTION set_chirp_conversation();
+
+-- +goose Down
+DROP TRIGGER chirps_set_conversation ON chirps;
+DROP FUNCTION set_chirp_conversation();
+
+ALTER TABLE chirps
+DROP COLUMN deleted_at,
+DROP COLUMN conversation_id,
+DROP COLUMN in_reply_to_id;
@@ -0,0 +1,54 @@
+-- +goose Up
+-- A like is the "like" reaction; each user has at most one reaction per
+-- chirp.
+CREATE TABLE chirp_reactions (
+  chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  reaction TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (chirp_id, user_id)
+);
+
+CREATE INDEX chirp_reactions_user_idx ON chirp_reactions (user_id, created_at DESC);
+
+ALTER TABLE chirps
+ADD COLUMN reaction_count INTEGER NOT NULL DEFAULT 0,
+ADD COLUMN reaction_counts JSONB NOT NULL DEFAULT '{}';
+
+-- Each UPDATE recomputes the counts from the latest row version under the
+-- row lock, so concurrent reactions to the same chirp are not lost.
+-- +goose StatementBegin
+CREATE FUNCTION update_reaction_counts() RETURNS TRIGGER AS $$
+BEGIN
+  IF TG_OP IN ('DELETE', 'UPDATE') THEN
+    UPDATE chirps
+    SET reaction_count = reaction_count - 1,
+        reaction_counts = CASE
+          WHEN COALESCE((reaction_counts->>OLD.reaction)::int, 0) <= 1 THEN reaction_counts - OLD.reaction
+          ELSE jsonb_set(reaction_counts, ARRAY[OLD.reaction], to_jsonb((reaction_counts->>OLD.reaction)::int - 1))
+        END
+    WHERE id = OLD.chirp_id;
+  END IF;
+  IF TG_OP IN ('INSERT', 'UPDATE') THEN
+    UPDATE chirps
+    SET reaction_count = reaction_count + 1,
+        reaction_counts = jsonb_set(reaction_counts, ARRAY[NEW.reaction], to_jsonb(COALESCE((reaction_counts->>NEW.reaction)::int, 0) + 1))
+    WHERE id = NEW.chirp_id;
+  END IF;
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER chirp_reactions_update_counts
+AFTER INSERT OR UPDATE OF reaction OR DELETE ON chirp_reactions
+FOR EACH ROW EXECUTE FUNCTION update_reaction_counts();
+
+-- +goose Down
+DROP TRIGGER chirp_reactions_update_counts ON chirp_reactions;
+DROP FUNCTION update_reaction_counts();
+DROP TABLE chirp_reactions;
+
+ALTER TABLE chirps
+DROP COLUMN reaction_counts,
+DROP COLUMN reaction_count;
@@ -0,0 +1,106 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN quoted_chirp_id UUID REFERENCES chirps(id) ON DELETE SET NULL;
+
+CREATE TABLE rechirps (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+  fanned_out BOOLEAN NOT NULL DEFAULT false,
+  UNIQUE (user_id, chirp_id)
+);
+
+CREATE INDEX rechirps_pull_idx ON rechirps (user_id, created_at DESC, id DESC) WHERE NOT fanned_out;
+
+-- Timeline entries are now keyed by the item they show: the chirp itself,
+-- or the rechirp that brought it there.
+ALTER TABLE timeline_entries
+ADD COLUMN id UUID,
+ADD COLUMN rechirp_id UUID REFERENCES rechirps(id) ON DELETE CASCADE;
+
+UPDATE timeline_entries SET id = chirp_id;
+
+ALTER TABLE timeline_entries
+ALTER COLUMN id SET NOT NULL,
+DROP CONSTRAINT timeline_entries_pkey,
+ADD PRIMARY KEY (user_id, id);
+
+DROP INDEX timeline_entries_user_idx;
+CREATE INDEX timeline_entries_user_idx ON timeline_entries (user_id, created_at DESC, id DESC);
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION update_follow_timeline() RETURNS TRIGGER AS $$
+BEGIN
+  IF TG_OP = 'DELETE' THEN
+    IF OLD.status = 'accepted' THEN
+      DELETE FROM timeline_entries WHERE user_id = OLD.follower_id AND author_id = OLD.followee_id;
+    END IF;
+    RETURN NULL;
+  END IF;
+  IF TG_OP = 'UPDATE' AND OLD.status = 'accepted' THEN
+    RETURN NULL;
+  END IF;
+  IF NEW.status = 'accepted' THEN
+    INSERT INTO timeline_entries (user_id, id, chirp_id, author_id, created_at)
+    SELECT NEW.follower_id, id, id, user_id, created_at
+    FROM chirps
+    WHERE user_id = NEW.followee_id AND fanned_out
+    ORDER BY created_at DESC
+    LIMIT 200
+    ON CONFLICT DO NOTHING;
+    INSERT INTO timeline_entries (user_id, id, chirp_id, rechirp_id, author_id, created_at)
+    SELECT NEW.follower_id, id, chirp_id, id, user_id, created_at
+    FROM rechirps
+    WHERE user_id = NEW.followee_id AND fanned_out
+    ORDER BY created_at DESC
+    LIMIT 200
+    ON CONFLICT DO NOTHING;
+  END IF;
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION update_follow_timeline() RETURNS TRIGGER AS $$
+BEGIN
+  IF TG_OP = 'DELETE' THEN
+    IF OLD.status = 'accepted' THEN
+      DELETE FROM timeline_entries WHERE user_id = OLD.follower_id AND author_id = OLD.followee_id;
+    END IF;
+    RETURN NULL;
+  END IF;
+  IF TG_OP = 'UPDATE' AND OLD.status = 'accepted' THEN
+    RETURN NULL;
+  END IF;
+  IF NEW.status = 'accepted' THEN
+    INSERT INTO timeline_entries (user_id, chirp_id, author_id, created_at)
+    SELECT NEW.follower_id, id, user_id, created_at
+    FROM chirps
+    WHERE user_id = NEW.followee_id AND fanned_out
+    ORDER BY created_at DESC
+    LIMIT 200
+    ON CONFLICT DO NOTHING;
+  END IF;
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+DELETE FROM timeline_entries WHERE rechirp_id IS NOT NULL;
+
+DROP INDEX timeline_entries_user_idx;
+CREATE INDEX timeline_entries_user_idx ON timeline_entries (user_id, created_at DESC, chirp_id DESC);
+
+ALTER TABLE timeline_entries
+DROP CONSTRAINT timeline_entries_pkey,
+ADD PRIMARY KEY (user_id, chirp_id),
+DROP COLUMN rechirp_id,
+DROP COLUMN id;
+
+DROP TABLE rechirps;
+
+ALTER TABLE chirps
+DROP COLUMN quoted_chirp_id;
@@ -0,0 +1,30 @@
+-- +goose Up
+CREATE TABLE bookmarks (
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (user_id, chirp_id)
+);
+
+CREATE INDEX bookmarks_user_idx ON bookmarks (user_id, created_at DESC, chirp_id DESC);
+
+-- Deleted chirps stay behind as tombstones, which nobody wants bookmarked.
+-- +goose StatementBegin
+CREATE FUNCTION delete_tombstone_bookmarks() RETURNS TRIGGER AS $$
+BEGIN
+  IF NEW.deleted_at IS NOT NULL AND OLD.deleted_at IS NULL THEN
+    DELETE FROM bookmarks WHERE chirp_id = NEW.id;
+  END IF;
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER chirps_delete_bookmarks
+AFTER UPDATE OF deleted_at ON chirps
+FOR EACH ROW EXECUTE FUNCTION delete_tombstone_bookmarks();
+
+-- +goose Down
+DROP TRIGGER chirps_delete_bookmarks ON chirps;
+DROP FUNCTION delete_tombstone_bookmarks();
+DROP TABLE bookmarks;
@@ -0,0 +1,17 @@
+-- +goose Up
+-- Each chirp records the text search configuration it was indexed with, set
+-- from SEARCH_LANGUAGE when it is posted. After changing that setting,
+-- reindex older chirps with:
+--   UPDATE chirps SET search_language = '<new language>';
+ALTER TABLE chirps
+ADD COLUMN search_language REGCONFIG NOT NULL DEFAULT 'english',
+ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (to_tsvector(search_language, body)) STORED;
+
+CREATE INDEX chirps_search_idx ON chirps USING GIN (search_vector);
+
+-- +goose Down
+DROP INDEX chirps_search_idx;
+
+ALTER TABLE chirps
+DROP COLUMN search_vector,
+DROP COLUMN search_language;
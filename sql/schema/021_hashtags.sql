@@ -0,0 +1,30 @@
+-- +goose Up
+CREATE TABLE hashtags (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  tag TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE chirp_hashtags (
+  chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+  hashtag_id UUID NOT NULL REFERENCES hashtags(id) ON DELETE CASCADE,
+  created_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (chirp_id, hashtag_id)
+);
+
+CREATE INDEX chirp_hashtags_hashtag_idx ON chirp_hashtags (hashtag_id, created_at DESC, chirp_id DESC);
+CREATE INDEX chirp_hashtags_created_at_idx ON chirp_hashtags (created_at);
+
+-- Replaced by the trending job on every run.
+CREATE TABLE trending_hashtags (
+  hashtag_id UUID PRIMARY KEY REFERENCES hashtags(id) ON DELETE CASCADE,
+  score DOUBLE PRECISION NOT NULL,
+  uses BIGINT NOT NULL,
+  authors BIGINT NOT NULL,
+  computed_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE trending_hashtags;
+DROP TABLE chirp_hashtags;
+DROP TABLE hashtags;
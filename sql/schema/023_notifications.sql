@@ -0,0 +1,46 @@
+-- +goose Up
+-- Repeated events of the same kind, such as likes of one chirp, are grouped
+-- into one unread notification by group_key. updated_at is the latest event
+-- and actor_id its actor; notification_actors lists everyone involved.
+ALTER TABLE notifications
+ADD COLUMN updated_at TIMESTAMP,
+ADD COLUMN group_key TEXT;
+
+UPDATE notifications SET updated_at = created_at;
+
+ALTER TABLE notifications
+ALTER COLUMN updated_at SET NOT NULL;
+
+DROP INDEX notifications_user_idx;
+CREATE INDEX notifications_user_idx ON notifications (user_id, updated_at DESC, id DESC);
+CREATE INDEX notifications_unread_idx ON notifications (user_id) WHERE read_at IS NULL;
+CREATE UNIQUE INDEX notifications_group_idx ON notifications (user_id, group_key) WHERE read_at IS NULL;
+
+CREATE TABLE notification_actors (
+  notification_id UUID NOT NULL REFERENCES notifications(id) ON DELETE CASCADE,
+  actor_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  created_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (notification_id, actor_id)
+);
+
+INSERT INTO notification_actors (notification_id, actor_id, created_at)
+SELECT id, actor_id, created_at FROM notifications;
+
+-- Types are enabled unless a row here turns them off.
+CREATE TABLE notification_preferences (
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  type TEXT NOT NULL,
+  enabled BOOLEAN NOT NULL,
+  PRIMARY KEY (user_id, type)
+);
+
+-- +goose Down
+DROP TABLE notification_preferences;
+DROP TABLE notification_actors;
+DROP INDEX notifications_group_idx;
+DROP INDEX notifications_unread_idx;
+DROP INDEX notifications_user_idx;
+CREATE INDEX notifications_user_idx ON notifications (user_id, created_at DESC, id DESC);
+ALTER TABLE notifications
+DROP COLUMN group_key,
+DROP COLUMN updated_at;
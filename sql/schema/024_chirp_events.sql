@@ -0,0 +1,19 @@
+-- +goose Up
+-- Tells every server instance listening on chirp_created about new chirps,
+-- once the inserting transaction commits. The payload is the chirp id.
+-- +goose StatementBegin
+CREATE FUNCTION notify_chirp_created() RETURNS TRIGGER AS $$
+BEGIN
+  PERFORM pg_notify('chirp_created', NEW.id::text);
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER chirps_notify_created
+AFTER INSERT ON chirps
+FOR EACH ROW EXECUTE FUNCTION notify_chirp_created();
+
+-- +goose Down
+DROP TRIGGER chirps_notify_created ON chirps;
+DROP FUNCTION notify_chirp_created();
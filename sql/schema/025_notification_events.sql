@@ -0,0 +1,20 @@
+-- +goose Up
+-- Like chirps_notify_created, announces new notifications, and grouped ones
+-- gaining an actor, to every server instance. The payload is
+-- "<user id>:<notification id>".
+-- +goose StatementBegin
+CREATE FUNCTION notify_notification_changed() RETURNS TRIGGER AS $$
+BEGIN
+  PERFORM pg_notify('notification_changed', NEW.user_id::text || ':' || NEW.id::text);
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER notifications_notify_changed
+AFTER INSERT OR UPDATE OF updated_at ON notifications
+FOR EACH ROW EXECUTE FUNCTION notify_notification_changed();
+
+-- +goose Down
+DROP TRIGGER notifications_notify_changed ON notifications;
+DROP FUNCTION notify_notification_changed();
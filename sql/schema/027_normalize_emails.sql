@@ -0,0 +1,11 @@
+-- +goose Up
+-- Signup and login normalize emails (see auth.NormalizeEmail), so accounts
+-- created before then are brought in line and lookups match on lower(email).
+-- If two accounts differ only by case this migration fails; merge them by
+-- hand first.
+UPDATE users SET email = lower(trim(email)) WHERE email <> lower(trim(email));
+
+CREATE UNIQUE INDEX users_email_lower_idx ON users (lower(email));
+
+-- +goose Down
+DROP INDEX users_email_lower_idx;
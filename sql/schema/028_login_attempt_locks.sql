@@ -0,0 +1,18 @@
+-- +goose Up
+-- login_lock_until mirrors lockout.Policy so that an attempt can be counted
+-- and the lock it triggers set in one statement.
+-- +goose StatementBegin
+CREATE FUNCTION login_lock_until(failures INTEGER, at_time TIMESTAMP, threshold INTEGER, base_delay FLOAT8, max_delay FLOAT8)
+RETURNS TIMESTAMP LANGUAGE sql IMMUTABLE AS $$
+  SELECT CASE
+    WHEN failures >= threshold
+    THEN at_time + make_interval(secs => LEAST(max_delay, base_delay * power(2, failures - threshold)))
+  END
+$$;
+-- +goose StatementEnd
+
+CREATE INDEX login_attempts_last_failure_idx ON login_attempts (last_failure_at);
+
+-- +goose Down
+DROP INDEX login_attempts_last_failure_idx;
+DROP FUNCTION login_lock_until;
@@ -0,0 +1,9 @@
+-- +goose Up
+-- totp_last_counter is the time step of the last TOTP code accepted, so a
+-- code cannot be used twice (RFC 6238 section 5.2).
+ALTER TABLE users
+ADD COLUMN totp_last_counter BIGINT;
+
+-- +goose Down
+ALTER TABLE users
+DROP COLUMN totp_last_counter;
@@ -0,0 +1,18 @@
+-- +goose Up
+-- Chirps used to carry their tsvector as a stored column, so every query
+-- that loaded a chirp also loaded its search vector. Index the expression
+-- instead; SearchChirps repeats it so the planner can use the index.
+DROP INDEX chirps_search_idx;
+
+ALTER TABLE chirps
+DROP COLUMN search_vector;
+
+CREATE INDEX chirps_search_idx ON chirps USING GIN (to_tsvector(search_language, body));
+
+-- +goose Down
+DROP INDEX chirps_search_idx;
+
+ALTER TABLE chirps
+ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (to_tsvector(search_language, body)) STORED;
+
+CREATE INDEX chirps_search_idx ON chirps USING GIN (search_vector);
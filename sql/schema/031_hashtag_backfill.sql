@@ -0,0 +1,15 @@
+-- +goose Up
+-- Chirps posted before 021_hashtags have no chirp_hashtags rows. Tags are
+-- normalised in Go, so the server fills them in at startup, walking chirps
+-- oldest first and recording how far it got here.
+CREATE TABLE hashtag_backfill (
+  id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+  cursor_created_at TIMESTAMP NOT NULL DEFAULT '0001-01-01 00:00:00',
+  cursor_id UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000000',
+  completed_at TIMESTAMP
+);
+
+INSERT INTO hashtag_backfill DEFAULT VALUES;
+
+-- +goose Down
+DROP TABLE hashtag_backfill;
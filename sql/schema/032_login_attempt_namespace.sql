@@ -0,0 +1,11 @@
+-- +goose Up
+-- Login attempts used to be stored without a namespace, so pruning them also
+-- matched magic link attempts. They now live under 'login:'.
+UPDATE login_attempts
+SET key = 'login:' || key
+WHERE starts_with(key, 'account:') OR starts_with(key, 'address:');
+
+-- +goose Down
+UPDATE login_attempts
+SET key = substr(key, length('login:') + 1)
+WHERE starts_with(key, 'login:');
@@ -0,0 +1,409 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ablanchetMD/chirpy/internal/database"
+	"github.com/ablanchetMD/chirpy/internal/entities"
+	"github.com/ablanchetMD/chirpy/internal/realtime"
+	"github.com/google/uuid"
+)
+
+// Postgres channels events are announced on; see the chirps_notify_created
+// and notifications_notify_changed triggers.
+const (
+	chirpCreatedChannel        = "chirp_created"
+	notificationChangedChannel = "notification_changed"
+)
+
+const (
+	// streamBuffer is how many events a stream may fall behind by before it
+	// is dropped. Dropped clients reconnect and catch up with Last-Event-ID.
+	streamBuffer       = 64
+	streamHeartbeat    = 15 * time.Second
+	streamWriteTimeout = 10 * time.Second
+	streamRetry        = 3 * time.Second
+	streamReplayBatch  = 100
+	maxStreamReplay    = 1000
+	// publicChirpCacheSize is how many recent chirps keep their public
+	// rendering; it only needs to cover how far behind a stream can fall.
+	publicChirpCacheSize = 4 * streamBuffer
+)
+
+// Hub topics. Every new chirp is published on topicChirps, its author's
+// topic, its conversation's topic and the topic of each of its hashtags.
+// Notifications are published on their recipient's notifications topic.
+const topicChirps = "chirps"
+
+func authorTopic(id uuid.UUID) string {
+	return "users/" + id.String() + "/chirps"
+}
+
+func threadTopic(conversationID uuid.UUID) string {
+	return "threads/" + conversationID.String()
+}
+
+func hashtagTopic(tag string) string {
+	return "hashtags/" + tag
+}
+
+func notificationsTopic(user uuid.UUID) string {
+	return "users/" + user.String() + "/notifications"
+}
+
+// chirpEvent is the hub payload for a new chirp. Subscribers load the chirp
+// themselves, as what they may see of it depends on who they are.
+type chirpEvent struct {
+	ID        uuid.UUID `json:"id"`
+	UserID    uuid.UUID `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// notificationEvent is the hub payload for a new or updated notification.
+type notificationEvent struct {
+	ID     uuid.UUID `json:"id"`
+	UserID uuid.UUID `json:"user_id"`
+}
+
+// startRealtime relays chirps and notifications created through any server
+// instance to this instance's hub until ctx is done.
+func startRealtime(c *apiConfig, ctx context.Context, dsn string) {
+	channels := []string{chirpCreatedChannel, notificationChangedChannel}
+	go realtime.Listen(ctx, dsn, channels, func(channel, payload string) {
+		switch channel {
+		case chirpCreatedChannel:
+			relayChirpCreated(c, ctx, payload)
+		case notificationChangedChannel:
+			relayNotificationChanged(c, payload)
+		}
+	}, func(err error) {
+		fmt.Println("Error listening for database events: ", err)
+	})
+}
+
+func relayChirpCreated(c *apiConfig, ctx context.Context, payload string) {
+	id, err := uuid.Parse(payload)
+	if err != nil {
+		fmt.Println("Error parsing chirp event: ", err)
+		return
+	}
+	chirp, err := c.Db.GetChirp(ctx, id)
+	if err != nil {
+		fmt.Println("Error getting chirp: ", err)
+		return
+	}
+	data, err := json.Marshal(chirpEvent{ID: chirp.ID, UserID: chirp.UserID, CreatedAt: chirp.CreatedAt})
+	if err != nil {
+		fmt.Println("Error encoding chirp event: ", err)
+		return
+	}
+	c.Hub.Publish(topicChirps, data)
+	c.Hub.Publish(authorTopic(chirp.UserID), data)
+	c.Hub.Publish(threadTopic(chirp.ConversationID), data)
+	for _, tag := range entities.UniqueTags(entities.Hashtags(chirp.Body)) {
+		c.Hub.Publish(hashtagTopic(tag), data)
+	}
+}
+
+func relayNotificationChanged(c *apiConfig, payload string) {
+	user, id, _ := strings.Cut(payload, ":")
+	event := notificationEvent{}
+	var err error
+	if event.UserID, err = uuid.Parse(user); err == nil {
+		event.ID, err = uuid.Parse(id)
+	}
+	if err != nil {
+		fmt.Println("Error parsing notification event: ", err)
+		return
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		fmt.Println("Error encoding notification event: ", err)
+		return
+	}
+	c.Hub.Publish(notificationsTopic(event.UserID), data)
+}
+
+// renderChirp prepares a chirp from an event for viewer.
+func renderChirp(c *apiConfig, r *http.Request, viewer uuid.UUID, src database.Chirp) Chirp {
+	chirp := mapChirpStruct(src)
+	decorateChirps(c, r, viewer, []*Chirp{&chirp})
+	return chirp
+}
+
+// publicChirp is a chirp rendered for anonymous viewers. ready is closed
+// once it has been loaded.
+type publicChirp struct {
+	ready   chan struct{}
+	chirp   Chirp
+	visible bool
+	err     error
+}
+
+// publicChirpCache shares the rendering of live chirps between anonymous
+// viewers, who all see the same thing, so that a chirp costs the same
+// queries however many of them are streaming.
+type publicChirpCache struct {
+	mu      sync.Mutex
+	entries map[uuid.UUID]*publicChirp
+	order   []uuid.UUID
+}
+
+func newPublicChirpCache() *publicChirpCache {
+	return &publicChirpCache{entries: map[uuid.UUID]*publicChirp{}}
+}
+
+// get returns the public rendering of chirp id, calling load if no other
+// viewer has. Failed loads are not kept, so the next viewer tries again.
+func (p *publicChirpCache) get(id uuid.UUID, load func() (Chirp, bool, error)) (Chirp, bool, error) {
+	p.mu.Lock()
+	entry, ok := p.entries[id]
+	if ok {
+		p.mu.Unlock()
+		<-entry.ready
+		return entry.chirp, entry.visible, entry.err
+	}
+	entry = &publicChirp{ready: make(chan struct{})}
+	p.entries[id] = entry
+	p.order = append(p.order, id)
+	if len(p.order) > publicChirpCacheSize {
+		delete(p.entries, p.order[0])
+		p.order = p.order[1:]
+	}
+	p.mu.Unlock()
+
+	entry.chirp, entry.visible, entry.err = load()
+	if entry.err != nil {
+		p.mu.Lock()
+		if p.entries[id] == entry {
+			delete(p.entries, id)
+		}
+		p.mu.Unlock()
+	}
+	close(entry.ready)
+	return entry.chirp, entry.visible, entry.err
+}
+
+// liveChirp loads and renders the chirp of a live event for viewer, or
+// reports that viewer may not see it.
+func liveChirp(c *apiConfig, r *http.Request, viewer, id uuid.UUID) (Chirp, bool, error) {
+	load := func(r *http.Request) (Chirp, bool, error) {
+		src, visible, err := visibleChirp(c, r, viewer, id)
+		if err != nil || !visible {
+			return Chirp{}, false, err
+		}
+		return renderChirp(c, r, viewer, src), true, nil
+	}
+	if viewer != uuid.Nil {
+		return load(r)
+	}
+	// Other viewers wait on this load, so it must outlive this request.
+	shared := r.WithContext(context.WithoutCancel(r.Context()))
+	return c.PublicChirps.get(id, func() (Chirp, bool, error) { return load(shared) })
+}
+
+// eventStream writes Server-Sent Events, giving up on a client that does not
+// take each one within streamWriteTimeout.
+type eventStream struct {
+	w  io.Writer
+	rc *http.ResponseController
+}
+
+func (s eventStream) write(text string) error {
+	// Not every ResponseWriter supports deadlines; the stream still works
+	// without one.
+	_ = s.rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+	if _, err := io.WriteString(s.w, text); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+func (s eventStream) send(id, event string, data []byte) error {
+	return s.write("id: " + id + "\nevent: " + event + "\ndata: " + string(data) + "\n\n")
+}
+
+func (s eventStream) sendChirp(chirp Chirp) error {
+	data, err := json.Marshal(chirp)
+	if err != nil {
+		return err
+	}
+	return s.send(chirpCursor(chirp).String(), "chirp", data)
+}
+
+// handleStream pushes new chirps as Server-Sent Events. ?feed= picks which:
+// "global" (the default) for every chirp the caller may see, "author" with
+// ?author= for one user's, or "timeline" for the caller's own and those of
+// the users they follow. Each event id is a cursor: reconnecting with
+// Last-Event-ID (or ?last_event_id=) first replays what was missed, up to
+// maxStreamReplay chirps, after which a "resync" event tells the client to
+// reload instead. Authenticated streams end once the caller's credentials
+// expire or are revoked.
+func handleStream(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	viewer, err := optionalPrincipal(c, r)
+	if err != nil {
+		fmt.Println("Error authenticating request: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error authenticating request")
+		return
+	}
+
+	query := r.URL.Query()
+	topics := []string{topicChirps}
+	var authors []uuid.UUID
+	switch query.Get("feed") {
+	case "", "global":
+	case "author":
+		author, err := c.Db.GetUserByHandle(r.Context(), strings.TrimPrefix(query.Get("author"), "@"))
+		if err != nil {
+			respondWithError(w, http.StatusNotFound, "No user with that handle")
+			return
+		}
+		authors = []uuid.UUID{author.ID}
+		topics = []string{authorTopic(author.ID)}
+	case "timeline":
+		if viewer.UserID == uuid.Nil {
+			respondWithError(w, http.StatusUnauthorized, "Invalid or missing credentials")
+			return
+		}
+		authors, err = c.Db.ListFolloweeIDs(r.Context(), viewer.UserID)
+		if err != nil {
+			fmt.Println("Error listing followees: ", err)
+			respondWithError(w, http.StatusInternalServerError, "Error opening stream")
+			return
+		}
+		authors = append(authors, viewer.UserID)
+		topics = topics[:0]
+		for _, author := range authors {
+			topics = append(topics, authorTopic(author))
+		}
+	default:
+		respondWithFieldErrors(w, []FieldError{{Field: "feed", Code: "unsupported", Message: "Use global, author or timeline"}})
+		return
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = query.Get("last_event_id")
+	}
+	var cursor pageCursor
+	if lastEventID != "" {
+		cursor, err = decodeCursor(lastEventID)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid Last-Event-ID")
+			return
+		}
+	}
+
+	// Subscribe before replaying so nothing created in between is missed;
+	// live events the replay already sent are skipped by id. Chirps are
+	// stamped when posted, not when committed, so a live chirp can sort
+	// before one already replayed and still be new to the client.
+	sub := c.Hub.Subscribe(streamBuffer, topics...)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	stream := eventStream{w: w, rc: http.NewResponseController(w)}
+	if err := stream.write(fmt.Sprintf("retry: %d\n\n", streamRetry.Milliseconds())); err != nil {
+		return
+	}
+
+	replayed := map[uuid.UUID]bool{}
+	if lastEventID != "" {
+		for len(replayed) < maxStreamReplay {
+			rows, err := c.Db.ListStreamChirps(r.Context(), database.ListStreamChirpsParams{
+				FilterAuthors: authors != nil,
+				AuthorIds:     authors,
+				ViewerID:      viewer.UserID,
+				CreatedAt:     cursor.CreatedAt,
+				ID:            cursor.ID,
+				Limit:         streamReplayBatch,
+			})
+			if err != nil {
+				fmt.Println("Error replaying stream: ", err)
+				return
+			}
+			for _, row := range rows {
+				if err := stream.sendChirp(renderChirp(c, r, viewer.UserID, row.Chirp)); err != nil {
+					return
+				}
+				cursor = pageCursor{CreatedAt: row.Chirp.CreatedAt, ID: row.Chirp.ID}
+				replayed[row.Chirp.ID] = true
+			}
+			if len(rows) < streamReplayBatch {
+				break
+			}
+		}
+		if len(replayed) >= maxStreamReplay {
+			if err := stream.send(cursor.String(), "resync", []byte("{}")); err != nil {
+				return
+			}
+		}
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	// Authenticated streams end when their credentials expire or are
+	// revoked; anonymous ones have nothing to check.
+	expired, stop := expiryTimer(viewer)
+	defer stop()
+	var recheck <-chan time.Time
+	if viewer.UserID != uuid.Nil {
+		ticker := time.NewTicker(reauthenticateInterval)
+		defer ticker.Stop()
+		recheck = ticker.C
+	}
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-sub.Done():
+			// Too slow to keep up; the client reconnects and replays.
+			return
+		case <-expired:
+			return
+		case <-recheck:
+			ok, err := reauthenticate(c, r, viewer)
+			if err != nil {
+				fmt.Println("Error authenticating stream: ", err)
+				continue
+			}
+			if !ok {
+				return
+			}
+		case <-heartbeat.C:
+			if err := stream.write(": heartbeat\n\n"); err != nil {
+				return
+			}
+		case m := <-sub.C():
+			var event chirpEvent
+			if err := json.Unmarshal(m.Payload, &event); err != nil {
+				continue
+			}
+			if replayed[event.ID] {
+				delete(replayed, event.ID)
+				continue
+			}
+			chirp, visible, err := liveChirp(c, r, viewer.UserID, event.ID)
+			if err != nil {
+				fmt.Println("Error getting chirp: ", err)
+				continue
+			}
+			if !visible {
+				continue
+			}
+			if err := stream.sendChirp(chirp); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/ablanchetMD/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// defaultFanoutThreshold is the follower count from which an author's chirps
+// are no longer pushed to every follower's timeline but pulled at read time.
+const defaultFanoutThreshold = 1000
+
+// newFanoutThreshold reads TIMELINE_FANOUT_THRESHOLD. Zero turns push off,
+// so every timeline is assembled at read time.
+func newFanoutThreshold() int {
+	if n, err := strconv.Atoi(os.Getenv("TIMELINE_FANOUT_THRESHOLD")); err == nil && n >= 0 {
+		return n
+	}
+	return defaultFanoutThreshold
+}
+
+// fanOutChirp pushes a new chirp to its author's followers, unless the
+// author has too many of them. A chirp that is not pushed stays visible
+// through the pull side of the timeline, so failures are only logged.
+func fanOutChirp(c *apiConfig, r *http.Request, chirp database.Chirp) {
+	if c.FanoutThreshold == 0 {
+		return
+	}
+	_, err := c.Db.FanOutChirp(r.Context(), database.FanOutChirpParams{
+		ID:            chirp.ID,
+		FollowerCount: int32(c.FanoutThreshold),
+	})
+	if err != nil {
+		fmt.Println("Error fanning out chirp: ", err)
+	}
+}
+
+// TimelineItem is a chirp in a timeline. Chirps brought there by a rechirp
+// carry who rechirped them and when, and are ordered by that time.
+type TimelineItem struct {
+	Chirp
+	RechirpID   *uuid.UUID `json:"rechirp_id,omitempty"`
+	RechirpedBy *uuid.UUID `json:"rechirped_by,omitempty"`
+	RechirpedAt *time.Time `json:"rechirped_at,omitempty"`
+}
+
+func mapTimelineItem(src database.GetTimelineRow) TimelineItem {
+	item := TimelineItem{
+		Chirp:       mapChirpStruct(src.Chirp),
+		RechirpID:   nullUUIDPtr(src.RechirpID),
+		RechirpedBy: nullUUIDPtr(src.RechirpedBy),
+	}
+	if src.RechirpID.Valid {
+		item.RechirpedAt = &src.ItemCreatedAt
+	}
+	return item
+}
+
+func timelineItemCursor(item TimelineItem) pageCursor {
+	if item.RechirpID != nil {
+		return pageCursor{CreatedAt: *item.RechirpedAt, ID: *item.RechirpID}
+	}
+	return pageCursor{CreatedAt: item.CreatedAt, ID: item.ID}
+}
+
+// handleGetTimeline returns the caller's chirps and rechirps and those of
+// the accounts they follow, newest first.
+func handleGetTimeline(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	principal, _ := principalFromContext(r.Context())
+
+	cursor, limit, err := parsePagination(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid cursor or limit")
+		return
+	}
+	rows, err := c.Db.GetTimeline(r.Context(), database.GetTimelineParams{
+		UserID:    principal.UserID,
+		CreatedAt: cursor.CreatedAt,
+		ID:        cursor.ID,
+		Limit:     int32(limit + 1),
+	})
+	if err != nil {
+		fmt.Println("Error getting timeline: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error getting timeline")
+		return
+	}
+	items := []TimelineItem{}
+	for _, row := range rows {
+		items = append(items, mapTimelineItem(row))
+	}
+	page := makePage(items, limit, timelineItemCursor)
+	ptrs := make([]*Chirp, 0, len(page.Items))
+	for i := range page.Items {
+		ptrs = append(ptrs, &page.Items[i].Chirp)
+	}
+	decorateChirps(c, r, principal.UserID, ptrs)
+	respondWithJSON(w, http.StatusOK, page)
+}
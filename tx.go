@@ -0,0 +1,21 @@
+package main
+
+import (
+	"context"
+
+	"github.com/ablanchetMD/chirpy/internal/database"
+)
+
+// inTx runs fn with queries bound to a transaction, committing if fn
+// succeeds and rolling back otherwise.
+func inTx(c *apiConfig, ctx context.Context, fn func(q *database.Queries) error) error {
+	tx, err := c.DBPool.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(c.Db.WithTx(tx)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -1,32 +1,79 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/ablanchetMD/chirpy/internal/auth"
 	"github.com/google/uuid"
 
 	"github.com/ablanchetMD/chirpy/internal/database"
+	"github.com/ablanchetMD/chirpy/internal/lockout"
+	"github.com/ablanchetMD/chirpy/internal/mailer"
 )
 
+// User is the private view of an account, only ever sent to the account
+// owner. Anything public goes through PublicUser instead.
 type User struct {
 	ID        uuid.UUID `json:"id"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 	Email     string    `json:"email"`
+	IsEmailVerified bool `json:"is_email_verified"`
+	Handle      string `json:"handle"`
+	DisplayName string `json:"display_name"`
+	Bio         string `json:"bio"`
+	AvatarURL   string `json:"avatar_url"`
+	IsPrivate   bool   `json:"is_private"`
 }
 
+type LoginResponse struct {
+	User
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+const (
+	emailVerificationExpiry = 48 * time.Hour
+	accessTokenExpiry       = time.Hour
+	refreshTokenExpiry      = 60 * 24 * time.Hour
+)
+
 func mapUserStruct(src database.User) User {
 	return User{
 		ID:        src.ID,
 		CreatedAt: src.CreatedAt,
 		UpdatedAt: src.UpdatedAt,
 		Email:     src.Email,
+		IsEmailVerified: src.EmailVerifiedAt.Valid,
+		Handle:      src.Handle,
+		DisplayName: src.DisplayName,
+		Bio:         src.Bio,
+		AvatarURL:   src.AvatarUrl,
+		IsPrivate:   src.IsPrivate,
+	}
+}
+
+func sendVerificationEmail(ctx context.Context, c *apiConfig, user database.User) error {
+	token, err := auth.MakeEmailVerificationToken(user.ID, user.Email, c.JWTSecret, emailVerificationExpiry)
+	if err != nil {
+		return err
 	}
+	link := c.BaseURL + "/api/users/verify?token=" + url.QueryEscape(token)
+	return c.Mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your Chirpy email address",
+		Body:    "Welcome to Chirpy! Confirm your email address by opening this link:\n\n" + link + "\n",
+	})
 }
 
 func handleCreateUser(c *apiConfig, w http.ResponseWriter, r *http.Request) {
@@ -44,14 +91,34 @@ func handleCreateUser(c *apiConfig, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	fieldErrors := []FieldError{}
 	email, ok := requestData["email"]
 	if !ok {
-		respondWithError(w, http.StatusBadRequest, "Missing email field")
-		return
+		fieldErrors = append(fieldErrors, FieldError{Field: "email", Code: "required", Message: "Missing email field"})
+	} else if email, err = auth.NormalizeEmail(email); err != nil {
+		fieldErrors = append(fieldErrors, FieldError{Field: "email", Code: "invalid", Message: "Invalid email address"})
 	}
 	password, ok := requestData["password"]
 	if !ok {
-		respondWithError(w, http.StatusBadRequest, "Missing password field")
+		fieldErrors = append(fieldErrors, FieldError{Field: "password", Code: "required", Message: "Missing password field"})
+	} else {
+		fieldErrors = append(fieldErrors, checkPassword(c, r, "password", password, email)...)
+	}
+	handle, ok := requestData["handle"]
+	if ok {
+		handle = strings.TrimPrefix(handle, "@")
+		if fieldError := validateHandle(handle); fieldError != nil {
+			fieldErrors = append(fieldErrors, *fieldError)
+		}
+	} else {
+		handle, err = generateHandle()
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error creating user")
+			return
+		}
+	}
+	if len(fieldErrors) > 0 {
+		respondWithFieldErrors(w, fieldErrors)
 		return
 	}
 
@@ -67,15 +134,195 @@ func handleCreateUser(c *apiConfig, w http.ResponseWriter, r *http.Request) {
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 		Password:  hashedPassword,
+		Handle:    handle,
 	})
+	if isUniqueViolation(err, handleConstraint) {
+		respondWithFieldErrors(w, []FieldError{{Field: "handle", Code: "taken", Message: "That handle is already taken"}})
+		return
+	}
 	if err != nil {
 		fmt.Println("Error creating user: ", err)
 		respondWithError(w, http.StatusInternalServerError, "Error creating user")
 		return
 	}
+	err = sendVerificationEmail(r.Context(), c, user)
+	if err != nil {
+		fmt.Println("Error sending verification email: ", err)
+	}
+	enqueueWebhookEvent(c, r.Context(), webhookUserCreated, uuid.Nil, mapUserStruct(user))
 	respondWithJSON(w, http.StatusCreated, mapUserStruct(user))
 }
 
+// handleChangePassword replaces the password after checking the current one.
+// Every other session is logged out; the caller gets fresh credentials of the
+// kind it used.
+func handleChangePassword(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	principal, _ := principalFromContext(r.Context())
+
+	requestData, err := decodeStringFields(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	user, err := c.Db.GetUserByID(r.Context(), principal.UserID)
+	if err != nil {
+		fmt.Println("Error fetching user: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error changing password")
+		return
+	}
+	// Guesses at the current password share the login budget, so a stolen
+	// token cannot be used to brute-force it.
+	ip := clientIP(c, r)
+	wait, locked, err := c.LoginLimiter.Attempt(r.Context(), user.Email, ip)
+	if err != nil {
+		fmt.Println("Error checking login attempts: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error changing password")
+		return
+	}
+	if wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		respondWithError(w, http.StatusTooManyRequests, "Too many failed attempts, try again later.")
+		return
+	}
+	if auth.CheckPasswordHash(requestData["current_password"], user.Password) != nil {
+		recordLoginFailure(c, user.Email, ip, locked)
+		respondWithFieldErrors(w, []FieldError{{Field: "current_password", Code: "incorrect", Message: "Current password is incorrect"}})
+		return
+	}
+	err = c.LoginLimiter.Succeed(r.Context(), user.Email, ip)
+	if err != nil {
+		fmt.Println("Error clearing login attempts: ", err)
+	}
+	newPassword := requestData["new_password"]
+	fieldErrors := checkPassword(c, r, "new_password", newPassword, user.Email)
+	if newPassword == requestData["current_password"] {
+		fieldErrors = append(fieldErrors, FieldError{Field: "new_password", Code: "unchanged", Message: "New password must differ from the current one"})
+	}
+	if len(fieldErrors) > 0 {
+		respondWithFieldErrors(w, fieldErrors)
+		return
+	}
+
+	hashedPassword, err := auth.HashPassword(newPassword)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error hashing password")
+		return
+	}
+	// The new password and the revocations land together, so a failure
+	// cannot leave old sessions alive next to a changed password.
+	now := time.Now()
+	err = inTx(c, r.Context(), func(q *database.Queries) error {
+		var err error
+		user, err = q.UpdateUserPassword(r.Context(), database.UpdateUserPasswordParams{
+			ID:        user.ID,
+			Password:  hashedPassword,
+			UpdatedAt: now,
+		})
+		if err != nil {
+			return err
+		}
+		err = q.RevokeAllUserRefreshTokens(r.Context(), database.RevokeAllUserRefreshTokensParams{
+			UserID:    user.ID,
+			RevokedAt: sql.NullTime{Time: now, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+		return q.RevokeAllUserSessions(r.Context(), database.RevokeAllUserSessionsParams{
+			UserID:    user.ID,
+			RevokedAt: sql.NullTime{Time: now, Valid: true},
+		})
+	})
+	if err != nil {
+		fmt.Println("Error changing password: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error changing password")
+		return
+	}
+
+	if principal.Method == authMethodSession {
+		startCookieSession(c, w, r, user)
+		return
+	}
+	respondWithTokens(c, w, r, user)
+}
+
+func handleVerifyEmail(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		respondWithError(w, http.StatusBadRequest, "Missing token parameter")
+		return
+	}
+
+	userID, email, err := auth.ValidateEmailVerificationToken(token, c.JWTSecret)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid or expired verification link")
+		return
+	}
+
+	user, err := c.Db.VerifyUserEmail(r.Context(), database.VerifyUserEmailParams{
+		ID:              userID,
+		Email:           email,
+		EmailVerifiedAt: sql.NullTime{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusBadRequest, "Invalid or expired verification link")
+			return
+		}
+		fmt.Println("Error verifying email: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error verifying email")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, mapUserStruct(user))
+}
+
+// recordLoginFailure updates the login metrics after a failed attempt, which
+// LoginLimiter.Attempt has already counted. locked is what Attempt reported.
+func recordLoginFailure(c *apiConfig, email, ip string, locked bool) {
+	atomic.AddUint64(&c.failedLogins, 1)
+	if locked {
+		atomic.AddUint64(&c.loginLockouts, 1)
+		fmt.Println("Login locked for ", email, " from ", ip)
+	}
+}
+
+func handleUnlock(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	if !isAdmin(c, r) {
+		respondWithError(w, http.StatusForbidden, "You are not authorized to use this function.")
+		return
+	}
+
+	var requestData map[string]string
+	err := json.NewDecoder(r.Body).Decode(&requestData)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	defer r.Body.Close()
+
+	email := requestData["email"]
+	if email != "" {
+		email, err = auth.NormalizeEmail(email)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid email field")
+			return
+		}
+	}
+	ip := requestData["ip"]
+	if email == "" && ip == "" {
+		respondWithError(w, http.StatusBadRequest, "Please include an email or ip field")
+		return
+	}
+
+	err = c.LoginLimiter.Unlock(r.Context(), email, ip)
+	if err != nil {
+		fmt.Println("Error unlocking login: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error unlocking login")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, "Login unlocked")
+}
+
 func handleReset(c *apiConfig, w http.ResponseWriter, r *http.Request) {
 	if c.Platform != "dev" {
 		respondWithError(w, http.StatusForbidden, "You are not authorized to use this function.")
@@ -90,6 +337,34 @@ func handleReset(c *apiConfig, w http.ResponseWriter, r *http.Request) {
 	respondWithJSON(w, http.StatusOK, "Users deleted")
 }
 
+// dummyPasswordHash is checked against when a login names no account.
+var dummyPasswordHash, _ = auth.HashPassword("not a real password")
+
+// loginPruneInterval is how often attempts too old to count are forgotten.
+const loginPruneInterval = 10 * time.Minute
+
+// startLoginPruning forgets old login and magic link attempts every
+// loginPruneInterval until ctx is done, so that attempts at made-up emails
+// and addresses do not pile up.
+func startLoginPruning(c *apiConfig, ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(loginPruneInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			for _, limiter := range []*lockout.Limiter{c.LoginLimiter, c.MagicLinkLimiter} {
+				if err := limiter.Prune(ctx); err != nil {
+					fmt.Println("Error pruning login attempts: ", err)
+				}
+			}
+		}
+	}()
+}
+
 func handleLogin(c *apiConfig, w http.ResponseWriter, r *http.Request) {
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
@@ -110,27 +385,105 @@ func handleLogin(c *apiConfig, w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusBadRequest, "Please include an email field")
 		return
 	}
+	email, err = auth.NormalizeEmail(email)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Password or email is invalid.")
+		return
+	}
 	password, ok := requestData["password"]
 	if !ok {
 		respondWithError(w, http.StatusBadRequest, "Please include a password field")
 		return
 	}
-	user, err := c.Db.GetUserByEmail(r.Context(), email)
+	ip := clientIP(c, r)
+	wait, locked, err := c.LoginLimiter.Attempt(r.Context(), email, ip)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Password or email is invalid.")
+		fmt.Println("Error checking login attempts: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error logging in")
+		return
+	}
+	if wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		respondWithError(w, http.StatusTooManyRequests, "Too many failed login attempts, try again later.")
 		return
 	}
-	err = auth.CheckPasswordHash(password, user.Password)
 
+	user, err := c.Db.GetUserByEmail(r.Context(), email)
+	if err == sql.ErrNoRows {
+		// Unknown emails take as long to turn down as wrong passwords.
+		auth.CheckPasswordHash(password, dummyPasswordHash)
+	} else if err == nil {
+		err = auth.CheckPasswordHash(password, user.Password)
+	}
 	if err != nil {
+		recordLoginFailure(c, email, ip, locked)
 		respondWithError(w, http.StatusUnauthorized, "Password or email is invalid.")
 		return
 	}
 
-	// user.Password = nil
+	// Accounts with an authenticator are only cleared once the second step
+	// succeeds, in handleLoginMFA, which counts an attempt of its own; this
+	// one is taken back so a full login costs the address nothing.
+	if user.TotpEnabledAt.Valid {
+		mfaToken, err := auth.MakeMFAToken(user.ID, c.JWTSecret, mfaChallengeExpiry)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error creating MFA challenge")
+			return
+		}
+		err = c.LoginLimiter.Refund(r.Context(), email, ip)
+		if err != nil {
+			fmt.Println("Error refunding login attempt: ", err)
+		}
+		respondWithJSON(w, http.StatusOK, MFAChallenge{MFARequired: true, MFAToken: mfaToken})
+		return
+	}
 
-	respondWithJSON(w, http.StatusOK, mapUserStruct(user))
+	err = c.LoginLimiter.Succeed(r.Context(), email, ip)
+	if err != nil {
+		fmt.Println("Error clearing login attempts: ", err)
+	}
+	completeLogin(c, w, r, user)
+
+}
 
+// completeLogin finishes a successful login, either with a browser session
+// cookie or by issuing an access token.
+func completeLogin(c *apiConfig, w http.ResponseWriter, r *http.Request, user database.User) {
+	if wantsCookieSession(r) {
+		startCookieSession(c, w, r, user)
+		return
+	}
+	respondWithTokens(c, w, r, user)
+}
+
+// respondWithTokens issues a refresh token and an access token for user.
+func respondWithTokens(c *apiConfig, w http.ResponseWriter, r *http.Request, user database.User) {
+	refreshToken, err := auth.MakeRandomToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating refresh token")
+		return
+	}
+	now := time.Now()
+	stored, err := c.Db.CreateRefreshToken(r.Context(), database.CreateRefreshTokenParams{
+		CreatedAt: now,
+		UserID:    user.ID,
+		TokenHash: auth.HashToken(refreshToken),
+		UserAgent: r.UserAgent(),
+		Ip:        clientIP(c, r),
+		ExpiresAt: now.Add(refreshTokenExpiry),
+	})
+	if err != nil {
+		fmt.Println("Error creating refresh token: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error creating refresh token")
+		return
+	}
+
+	token, err := c.Keys.MakeAccessToken(user.ID, user.TokenVersion, stored.ID.String(), accessTokenExpiry)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating access token")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, LoginResponse{User: mapUserStruct(user), Token: token, RefreshToken: refreshToken})
 }
 
 // func (db *DB) findEmail(email string) (User, error) {
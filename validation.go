@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/ablanchetMD/chirpy/internal/auth"
+)
+
+// FieldError is a validation failure tied to one field of the request body.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type ValidationErrorResponse struct {
+	Error  string       `json:"error"`
+	Fields []FieldError `json:"fields"`
+}
+
+func respondWithFieldErrors(w http.ResponseWriter, fields []FieldError) {
+	respondWithJSON(w, http.StatusBadRequest, ValidationErrorResponse{Error: "Validation failed", Fields: fields})
+}
+
+// newPasswordPolicy reads PASSWORD_MIN_LENGTH, PASSWORD_MAX_BYTES (capped at
+// bcrypt's 72), PASSWORD_MIN_STRENGTH (0-4) and PASSWORD_BREACH_DIR, a local
+// copy of the Pwned Passwords range files.
+func newPasswordPolicy() auth.PasswordPolicy {
+	policy := auth.DefaultPasswordPolicy
+	if n, err := strconv.Atoi(os.Getenv("PASSWORD_MIN_LENGTH")); err == nil {
+		policy.MinLength = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("PASSWORD_MAX_BYTES")); err == nil {
+		policy.MaxBytes = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("PASSWORD_MIN_STRENGTH")); err == nil {
+		policy.MinStrength = n
+	}
+	if dir := os.Getenv("PASSWORD_BREACH_DIR"); dir != "" {
+		policy.Breaches = auth.RangeDirectory{Dir: dir}
+	}
+	return policy
+}
+
+// checkPassword applies the password policy to the named field. A breach
+// corpus that cannot be read is logged and otherwise ignored.
+func checkPassword(c *apiConfig, r *http.Request, field, password string, userInputs ...string) []FieldError {
+	violations, err := c.PasswordPolicy.Check(r.Context(), password, userInputs...)
+	if err != nil {
+		fmt.Println("Error checking password against breach corpus: ", err)
+	}
+	fields := []FieldError{}
+	for _, violation := range violations {
+		fields = append(fields, FieldError{Field: field, Code: violation.Code, Message: violation.Message})
+	}
+	return fields
+}
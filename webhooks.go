@@ -0,0 +1,576 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ablanchetMD/chirpy/internal/auth"
+	"github.com/ablanchetMD/chirpy/internal/database"
+	"github.com/ablanchetMD/chirpy/internal/webhooks"
+	"github.com/google/uuid"
+)
+
+const (
+	webhookChirpCreated = "chirp.created"
+	webhookChirpDeleted = "chirp.deleted"
+	webhookUserCreated  = "user.created"
+)
+
+// userWebhookEvents are the events users may subscribe to, which are only
+// sent for their own chirps. Admin endpoints may subscribe to any event and
+// receive it for everyone.
+var (
+	userWebhookEvents  = []string{webhookChirpCreated, webhookChirpDeleted}
+	adminWebhookEvents = []string{webhookChirpCreated, webhookChirpDeleted, webhookUserCreated}
+)
+
+const (
+	webhookPending   = "pending"
+	webhookSucceeded = "succeeded"
+	webhookFailed    = "failed"
+)
+
+// Deliveries are retried with exponential backoff until they succeed or
+// maxWebhookAttempts have failed. An endpoint whose deliveries have failed
+// for WEBHOOK_DISABLE_AFTER without a success in between is disabled.
+const (
+	defaultWebhookInterval     = 5 * time.Second
+	defaultWebhookDisableAfter = 24 * time.Hour
+	webhookBatch               = 20
+	webhookLease               = 2 * time.Minute
+	webhookBackoffBase         = 30 * time.Second
+	webhookBackoffLimit        = 6 * time.Hour
+	maxWebhookAttempts         = 10
+	maxUserWebhookEndpoints    = 10
+)
+
+// WebhookConfig controls delivery, from WEBHOOK_INTERVAL and
+// WEBHOOK_DISABLE_AFTER. Endpoints may use http and private addresses on the
+// dev platform only.
+type WebhookConfig struct {
+	Interval     time.Duration
+	DisableAfter time.Duration
+	Insecure     bool
+	Client       *http.Client
+}
+
+func newWebhookConfig(platform string) WebhookConfig {
+	config := WebhookConfig{
+		Interval:     defaultWebhookInterval,
+		DisableAfter: defaultWebhookDisableAfter,
+		Insecure:     platform == "dev",
+	}
+	for name, value := range map[string]*time.Duration{
+		"WEBHOOK_INTERVAL":      &config.Interval,
+		"WEBHOOK_DISABLE_AFTER": &config.DisableAfter,
+	} {
+		if d, err := time.ParseDuration(os.Getenv(name)); err == nil && d > 0 {
+			*value = d
+		}
+	}
+	config.Client = webhooks.NewClient(config.Insecure)
+	return config
+}
+
+type WebhookEndpoint struct {
+	ID                  uuid.UUID  `json:"id"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+	URL                 string     `json:"url"`
+	EventTypes          []string   `json:"event_types"`
+	Enabled             bool       `json:"enabled"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	FailingSince        *time.Time `json:"failing_since"`
+	DisabledAt          *time.Time `json:"disabled_at"`
+	// Secret is only set in the response that creates the endpoint.
+	Secret string `json:"secret,omitempty"`
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID       `json:"id"`
+	CreatedAt      time.Time       `json:"created_at"`
+	EventID        uuid.UUID       `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at"`
+	LastAttemptAt  *time.Time      `json:"last_attempt_at"`
+	ResponseStatus *int            `json:"response_status"`
+	ResponseBody   string          `json:"response_body"`
+	Error          string          `json:"error"`
+	CompletedAt    *time.Time      `json:"completed_at"`
+}
+
+// webhookEvent is the body of every delivery. Redeliveries send the same
+// event again, so receivers can use ID to ignore duplicates.
+type webhookEvent struct {
+	ID        uuid.UUID `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+func mapWebhookEndpointStruct(src database.WebhookEndpoint) WebhookEndpoint {
+	return WebhookEndpoint{
+		ID:                  src.ID,
+		CreatedAt:           src.CreatedAt,
+		UpdatedAt:           src.UpdatedAt,
+		URL:                 src.Url,
+		EventTypes:          src.EventTypes,
+		Enabled:             src.Enabled,
+		ConsecutiveFailures: int(src.ConsecutiveFailures),
+		FailingSince:        nullTimePtr(src.FailingSince),
+		DisabledAt:          nullTimePtr(src.DisabledAt),
+	}
+}
+
+func mapWebhookDeliveryStruct(src database.WebhookDelivery) WebhookDelivery {
+	delivery := WebhookDelivery{
+		ID:            src.ID,
+		CreatedAt:     src.CreatedAt,
+		EventID:       src.EventID,
+		EventType:     src.EventType,
+		Payload:       json.RawMessage(src.Payload),
+		Status:        src.Status,
+		Attempts:      int(src.Attempts),
+		LastAttemptAt: nullTimePtr(src.LastAttemptAt),
+		ResponseBody:  src.ResponseBody,
+		Error:         src.Error,
+		CompletedAt:   nullTimePtr(src.CompletedAt),
+	}
+	if src.Status == webhookPending {
+		delivery.NextAttemptAt = &src.NextAttemptAt
+	}
+	if src.ResponseStatus.Valid {
+		status := int(src.ResponseStatus.Int32)
+		delivery.ResponseStatus = &status
+	}
+	return delivery
+}
+
+func webhookDeliveryCursor(delivery WebhookDelivery) pageCursor {
+	return pageCursor{CreatedAt: delivery.CreatedAt, ID: delivery.ID}
+}
+
+// enqueueWebhookEvent queues an event for every enabled endpoint subscribed
+// to it: admin endpoints, and owner's if the event is about a user. Failures
+// are logged, as the action itself has already succeeded.
+func enqueueWebhookEvent(c *apiConfig, ctx context.Context, kind string, owner uuid.UUID, data any) {
+	event := webhookEvent{
+		ID:        uuid.New(),
+		Type:      kind,
+		CreatedAt: time.Now(),
+		Data:      data,
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		fmt.Println("Error encoding webhook event: ", err)
+		return
+	}
+	params := database.EnqueueWebhookEventParams{
+		CreatedAt: event.CreatedAt,
+		EventID:   event.ID,
+		EventType: kind,
+		Payload:   string(payload),
+	}
+	if owner != uuid.Nil {
+		params.OwnerID = uuid.NullUUID{UUID: owner, Valid: true}
+	}
+	if err := c.Db.EnqueueWebhookEvent(ctx, params); err != nil {
+		fmt.Println("Error queueing webhook event: ", err)
+	}
+}
+
+// startWebhookWorker sends due deliveries every interval until ctx is done.
+// Deliveries are claimed with a lease, so several server instances can share
+// the queue and a delivery whose instance died is retried once it expires.
+func startWebhookWorker(c *apiConfig, ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(c.Webhooks.Interval)
+		defer ticker.Stop()
+		for {
+			if err := sendDueWebhooks(c, ctx); err != nil {
+				fmt.Println("Error sending webhooks: ", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// sendDueWebhooks sends one batch of due deliveries. A full batch means more
+// may be waiting, so it carries on until the queue is drained.
+func sendDueWebhooks(c *apiConfig, ctx context.Context) error {
+	for {
+		now := time.Now()
+		rows, err := c.Db.ClaimWebhookDeliveries(ctx, database.ClaimWebhookDeliveriesParams{
+			LeaseUntil: now.Add(webhookLease),
+			Now:        now,
+			Limit:      webhookBatch,
+		})
+		if err != nil {
+			return err
+		}
+		var wg sync.WaitGroup
+		for _, row := range rows {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				sendWebhook(c, ctx, row)
+			}()
+		}
+		wg.Wait()
+		if len(rows) < webhookBatch || ctx.Err() != nil {
+			return nil
+		}
+	}
+}
+
+// sendWebhook makes one attempt at a delivery and records the outcome.
+func sendWebhook(c *apiConfig, ctx context.Context, row database.ClaimWebhookDeliveriesRow) {
+	result, err := webhooks.Send(ctx, c.Webhooks.Client, row.Url, row.Secret, row.EventType, row.ID.String(), []byte(row.Payload))
+	now := time.Now()
+	attempt := int(row.Attempts) + 1
+	params := database.RecordWebhookAttemptParams{
+		ID:            row.ID,
+		Status:        webhookPending,
+		NextAttemptAt: now,
+		AttemptedAt:   now,
+		ResponseBody:  result.Body,
+	}
+	if result.StatusCode != 0 {
+		params.ResponseStatus = sql.NullInt32{Int32: int32(result.StatusCode), Valid: true}
+	}
+	ok := err == nil && result.OK()
+	switch {
+	case err != nil:
+		params.Error = err.Error()
+	case !ok:
+		params.Error = fmt.Sprintf("endpoint responded with status %d", result.StatusCode)
+	}
+	switch {
+	case ok:
+		params.Status = webhookSucceeded
+		params.CompletedAt = sql.NullTime{Time: now, Valid: true}
+	case attempt >= maxWebhookAttempts:
+		params.Status = webhookFailed
+		params.CompletedAt = sql.NullTime{Time: now, Valid: true}
+	default:
+		params.NextAttemptAt = now.Add(webhooks.Backoff(attempt, webhookBackoffBase, webhookBackoffLimit))
+	}
+	if err := c.Db.RecordWebhookAttempt(ctx, params); err != nil {
+		fmt.Println("Error recording webhook attempt: ", err)
+	}
+
+	if ok {
+		if err := c.Db.RecordWebhookEndpointSuccess(ctx, row.EndpointID); err != nil {
+			fmt.Println("Error recording webhook success: ", err)
+		}
+		return
+	}
+	enabled, err := c.Db.RecordWebhookEndpointFailure(ctx, database.RecordWebhookEndpointFailureParams{
+		ID:            row.EndpointID,
+		Now:           now,
+		DisableBefore: now.Add(-c.Webhooks.DisableAfter),
+	})
+	if err != nil {
+		fmt.Println("Error recording webhook failure: ", err)
+		return
+	}
+	if !enabled {
+		fmt.Println("Disabled failing webhook endpoint: ", row.EndpointID)
+	}
+}
+
+// webhookOwner returns whose endpoints the request manages: the caller's on
+// /api/webhooks, or the admin endpoints, which have no owner, on
+// /admin/webhooks.
+func webhookOwner(c *apiConfig, w http.ResponseWriter, r *http.Request) (uuid.NullUUID, bool) {
+	if principal, ok := principalFromContext(r.Context()); ok {
+		return uuid.NullUUID{UUID: principal.UserID, Valid: true}, true
+	}
+	if !isAdmin(c, r) {
+		respondWithError(w, http.StatusForbidden, "You are not authorized to use this function.")
+		return uuid.NullUUID{}, false
+	}
+	return uuid.NullUUID{}, true
+}
+
+// checkWebhookFields validates an endpoint's URL and event types.
+func checkWebhookFields(c *apiConfig, owner uuid.NullUUID, url string, eventTypes []string) []FieldError {
+	var fieldErrors []FieldError
+	if url == "" {
+		fieldErrors = append(fieldErrors, FieldError{Field: "url", Code: "required", Message: "Missing url field"})
+	} else if err := webhooks.ValidateURL(url, c.Webhooks.Insecure); err != nil {
+		fieldErrors = append(fieldErrors, FieldError{Field: "url", Code: "invalid", Message: "Invalid url: " + err.Error()})
+	}
+	allowed := adminWebhookEvents
+	if owner.Valid {
+		allowed = userWebhookEvents
+	}
+	if len(eventTypes) == 0 {
+		fieldErrors = append(fieldErrors, FieldError{Field: "event_types", Code: "required", Message: "Missing event_types field"})
+	}
+	for _, kind := range eventTypes {
+		if !contains(allowed, kind) {
+			fieldErrors = append(fieldErrors, FieldError{Field: "event_types", Code: "unsupported", Message: "Unknown event type: " + kind})
+		}
+	}
+	return fieldErrors
+}
+
+// webhookEndpointFromPath loads the {webhookID} endpoint if it belongs to
+// owner, responding with an error otherwise.
+func webhookEndpointFromPath(c *apiConfig, w http.ResponseWriter, r *http.Request, owner uuid.NullUUID) (database.WebhookEndpoint, bool) {
+	id, err := uuid.Parse(r.PathValue("webhookID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid webhook id")
+		return database.WebhookEndpoint{}, false
+	}
+	endpoint, err := c.Db.GetWebhookEndpoint(r.Context(), database.GetWebhookEndpointParams{ID: id, UserID: owner})
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "No webhook with that id")
+		return database.WebhookEndpoint{}, false
+	}
+	if err != nil {
+		fmt.Println("Error getting webhook: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error getting webhook")
+		return database.WebhookEndpoint{}, false
+	}
+	return endpoint, true
+}
+
+// handleCreateWebhook registers an endpoint. The response includes the
+// signing secret, which is not shown again.
+func handleCreateWebhook(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	owner, ok := webhookOwner(c, w, r)
+	if !ok {
+		return
+	}
+	var requestData struct {
+		URL        string   `json:"url"`
+		EventTypes []string `json:"event_types"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&requestData)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	defer r.Body.Close()
+
+	if fieldErrors := checkWebhookFields(c, owner, requestData.URL, requestData.EventTypes); len(fieldErrors) > 0 {
+		respondWithFieldErrors(w, fieldErrors)
+		return
+	}
+	if owner.Valid {
+		existing, err := c.Db.ListWebhookEndpoints(r.Context(), owner)
+		if err != nil {
+			fmt.Println("Error listing webhooks: ", err)
+			respondWithError(w, http.StatusInternalServerError, "Error creating webhook")
+			return
+		}
+		if len(existing) >= maxUserWebhookEndpoints {
+			respondWithError(w, http.StatusConflict, fmt.Sprintf("You can register at most %d webhooks", maxUserWebhookEndpoints))
+			return
+		}
+	}
+
+	secret, err := auth.MakeRandomToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error generating webhook secret")
+		return
+	}
+	endpoint, err := c.Db.CreateWebhookEndpoint(r.Context(), database.CreateWebhookEndpointParams{
+		CreatedAt:  time.Now(),
+		UserID:     owner,
+		Url:        requestData.URL,
+		Secret:     secret,
+		EventTypes: requestData.EventTypes,
+	})
+	if err != nil {
+		fmt.Println("Error creating webhook: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error creating webhook")
+		return
+	}
+	response := mapWebhookEndpointStruct(endpoint)
+	response.Secret = secret
+	respondWithJSON(w, http.StatusCreated, response)
+}
+
+func handleListWebhooks(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	owner, ok := webhookOwner(c, w, r)
+	if !ok {
+		return
+	}
+	endpoints, err := c.Db.ListWebhookEndpoints(r.Context(), owner)
+	if err != nil {
+		fmt.Println("Error listing webhooks: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error listing webhooks")
+		return
+	}
+	response := []WebhookEndpoint{}
+	for _, endpoint := range endpoints {
+		response = append(response, mapWebhookEndpointStruct(endpoint))
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// handleUpdateWebhook changes an endpoint's url, event_types or enabled;
+// fields left out are unchanged. Re-enabling a disabled endpoint resumes its
+// pending deliveries and clears its failure count.
+func handleUpdateWebhook(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	owner, ok := webhookOwner(c, w, r)
+	if !ok {
+		return
+	}
+	endpoint, ok := webhookEndpointFromPath(c, w, r, owner)
+	if !ok {
+		return
+	}
+	var requestData struct {
+		URL        *string  `json:"url"`
+		EventTypes []string `json:"event_types"`
+		Enabled    *bool    `json:"enabled"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&requestData)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	defer r.Body.Close()
+
+	params := database.UpdateWebhookEndpointParams{
+		ID:         endpoint.ID,
+		UserID:     owner,
+		Url:        endpoint.Url,
+		EventTypes: endpoint.EventTypes,
+		Enabled:    endpoint.Enabled,
+		UpdatedAt:  time.Now(),
+	}
+	if requestData.URL != nil {
+		params.Url = *requestData.URL
+	}
+	if requestData.EventTypes != nil {
+		params.EventTypes = requestData.EventTypes
+	}
+	if requestData.Enabled != nil {
+		params.Enabled = *requestData.Enabled
+	}
+	if fieldErrors := checkWebhookFields(c, owner, params.Url, params.EventTypes); len(fieldErrors) > 0 {
+		respondWithFieldErrors(w, fieldErrors)
+		return
+	}
+
+	updated, err := c.Db.UpdateWebhookEndpoint(r.Context(), params)
+	if err != nil {
+		fmt.Println("Error updating webhook: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error updating webhook")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, mapWebhookEndpointStruct(updated))
+}
+
+// handleDeleteWebhook removes an endpoint along with its delivery log.
+func handleDeleteWebhook(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	owner, ok := webhookOwner(c, w, r)
+	if !ok {
+		return
+	}
+	id, err := uuid.Parse(r.PathValue("webhookID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid webhook id")
+		return
+	}
+	deleted, err := c.Db.DeleteWebhookEndpoint(r.Context(), database.DeleteWebhookEndpointParams{ID: id, UserID: owner})
+	if err != nil {
+		fmt.Println("Error deleting webhook: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error deleting webhook")
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "No webhook with that id")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleListWebhookDeliveries returns an endpoint's delivery log, newest
+// first.
+func handleListWebhookDeliveries(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	owner, ok := webhookOwner(c, w, r)
+	if !ok {
+		return
+	}
+	endpoint, ok := webhookEndpointFromPath(c, w, r, owner)
+	if !ok {
+		return
+	}
+	cursor, limit, err := parsePagination(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid cursor or limit")
+		return
+	}
+	rows, err := c.Db.ListWebhookDeliveries(r.Context(), database.ListWebhookDeliveriesParams{
+		EndpointID: endpoint.ID,
+		CreatedAt:  cursor.CreatedAt,
+		ID:         cursor.ID,
+		Limit:      int32(limit + 1),
+	})
+	if err != nil {
+		fmt.Println("Error listing webhook deliveries: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error listing webhook deliveries")
+		return
+	}
+	deliveries := []WebhookDelivery{}
+	for _, row := range rows {
+		deliveries = append(deliveries, mapWebhookDeliveryStruct(row))
+	}
+	respondWithJSON(w, http.StatusOK, makePage(deliveries, limit, webhookDeliveryCursor))
+}
+
+// handleRedeliverWebhook queues a past delivery's event to be sent again, as
+// a new delivery with its own attempts.
+func handleRedeliverWebhook(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	owner, ok := webhookOwner(c, w, r)
+	if !ok {
+		return
+	}
+	endpoint, ok := webhookEndpointFromPath(c, w, r, owner)
+	if !ok {
+		return
+	}
+	if !endpoint.Enabled {
+		respondWithError(w, http.StatusConflict, "Webhook is disabled; enable it before redelivering")
+		return
+	}
+	deliveryID, err := uuid.Parse(r.PathValue("deliveryID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid delivery id")
+		return
+	}
+	delivery, err := c.Db.RedeliverWebhook(r.Context(), database.RedeliverWebhookParams{
+		ID:         deliveryID,
+		EndpointID: endpoint.ID,
+		CreatedAt:  time.Now(),
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "No delivery with that id")
+		return
+	}
+	if err != nil {
+		fmt.Println("Error redelivering webhook: ", err)
+		respondWithError(w, http.StatusInternalServerError, "Error redelivering webhook")
+		return
+	}
+	respondWithJSON(w, http.StatusAccepted, mapWebhookDeliveryStruct(delivery))
+}
@@ -0,0 +1,391 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ablanchetMD/chirpy/internal/auth"
+	"github.com/ablanchetMD/chirpy/internal/database"
+	"github.com/ablanchetMD/chirpy/internal/entities"
+	"github.com/ablanchetMD/chirpy/internal/realtime"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// wsSendBuffer is how many messages a connection may fall behind by
+	// before it is closed; clients reconnect and reload what they missed.
+	wsSendBuffer     = 64
+	wsWriteWait      = 10 * time.Second
+	wsPongWait       = 60 * time.Second
+	wsPingInterval   = wsPongWait * 9 / 10
+	wsMaxMessageSize = 4096
+	wsMaxChannels    = 50
+)
+
+// The default origin check turns away cross-site pages, which would
+// otherwise be able to connect with the user's session cookie.
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// wsClientMessage is sent by clients: {"type": "subscribe", "channel":
+// "timeline"}, "unsubscribe" likewise, or {"type": "ping"}. An id, if
+// given, is echoed in the reply.
+type wsClientMessage struct {
+	Type    string `json:"type"`
+	ID      string `json:"id,omitempty"`
+	Channel string `json:"channel,omitempty"`
+}
+
+// wsServerMessage is sent to clients: replies ("subscribed",
+// "unsubscribed", "pong" and "error") and events, which carry the channel
+// they were subscribed on, their kind ("chirp" or "notification") and data.
+type wsServerMessage struct {
+	Type    string `json:"type"`
+	ID      string `json:"id,omitempty"`
+	Channel string `json:"channel,omitempty"`
+	Event   string `json:"event,omitempty"`
+	Data    any    `json:"data,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// wsConn is one client connection. Its reader runs in the handler, a pump
+// turns hub messages into events and a writer owns all writes to the socket.
+type wsConn struct {
+	c         *apiConfig
+	r         *http.Request
+	conn      *websocket.Conn
+	principal Principal
+	sub       *realtime.Subscription
+	send      chan wsServerMessage
+	done      chan struct{}
+	closeOnce sync.Once
+
+	mu sync.Mutex
+	// channels maps each subscribed channel to its hub topics, and
+	// topicChannels the other way round: two channels, such as two chirps
+	// of the same thread, can share a topic.
+	channels      map[string][]string
+	topicChannels map[string]map[string]bool
+}
+
+// tokenFromQuery lets browsers, which cannot set headers on a WebSocket
+// handshake, pass an access token as ?access_token=. API keys are not
+// accepted there, as URLs end up in logs.
+func tokenFromQuery(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := r.URL.Query().Get("access_token")
+		if token != "" && r.Header.Get("Authorization") == "" {
+			if auth.IsAPIKey(token) {
+				respondWithError(w, http.StatusUnauthorized, "API keys must be sent in the Authorization header")
+				return
+			}
+			r = r.Clone(r.Context())
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		next(w, r)
+	}
+}
+
+// handleWebSocket upgrades an authenticated request to a WebSocket over
+// which the client subscribes to channels: "timeline", "notifications",
+// "hashtag:<tag>" and "thread:<chirp id>". The connection is closed with
+// 1008 (policy violation) once its credentials expire or are revoked.
+func handleWebSocket(c *apiConfig, w http.ResponseWriter, r *http.Request) {
+	principal, _ := principalFromContext(r.Context())
+
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already responded.
+		return
+	}
+	ws := &wsConn{
+		c:             c,
+		r:             r,
+		conn:          conn,
+		principal:     principal,
+		sub:           c.Hub.Subscribe(wsSendBuffer),
+		send:          make(chan wsServerMessage, wsSendBuffer),
+		done:          make(chan struct{}),
+		channels:      map[string][]string{},
+		topicChannels: map[string]map[string]bool{},
+	}
+	defer ws.close()
+	go ws.writePump()
+	go ws.eventPump()
+	ws.readPump()
+}
+
+func (ws *wsConn) close() {
+	ws.closeOnce.Do(func() {
+		close(ws.done)
+		ws.sub.Close()
+		ws.conn.Close()
+	})
+}
+
+// closeWith tells the client why the connection is closing, then closes it.
+func (ws *wsConn) closeWith(code int, reason string) {
+	ws.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(wsWriteWait))
+	ws.close()
+}
+
+// queue hands msg to the writer, closing connections too slow to keep up.
+func (ws *wsConn) queue(msg wsServerMessage) {
+	select {
+	case ws.send <- msg:
+	case <-ws.done:
+	default:
+		ws.closeWith(websocket.CloseTryAgainLater, "Too slow to keep up")
+	}
+}
+
+func (ws *wsConn) readPump() {
+	ws.conn.SetReadLimit(wsMaxMessageSize)
+	ws.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	ws.conn.SetPongHandler(func(string) error {
+		return ws.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+	for {
+		_, data, err := ws.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		ws.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+
+		var msg wsClientMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			ws.queue(wsServerMessage{Type: "error", Error: "Invalid message"})
+			continue
+		}
+		switch msg.Type {
+		case "subscribe":
+			ws.subscribe(msg)
+		case "unsubscribe":
+			ws.unsubscribe(msg)
+		case "ping":
+			ws.queue(wsServerMessage{Type: "pong", ID: msg.ID})
+		default:
+			ws.queue(wsServerMessage{Type: "error", ID: msg.ID, Error: "Unknown message type"})
+		}
+	}
+}
+
+func (ws *wsConn) writePump() {
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+	defer ws.close()
+	for {
+		select {
+		case <-ws.done:
+			return
+		case msg := <-ws.send:
+			ws.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := ws.conn.WriteJSON(msg); err != nil {
+				return
+			}
+		case <-ping.C:
+			ws.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := ws.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// eventPump delivers hub messages until the connection closes. It also
+// closes the connection once its credentials expire or are revoked.
+func (ws *wsConn) eventPump() {
+	expired, stop := expiryTimer(ws.principal)
+	defer stop()
+	recheck := time.NewTicker(reauthenticateInterval)
+	defer recheck.Stop()
+	for {
+		select {
+		case <-ws.done:
+			return
+		case <-expired:
+			ws.closeWith(websocket.ClosePolicyViolation, "Credentials expired")
+			return
+		case <-recheck.C:
+			ok, err := reauthenticate(ws.c, ws.r, ws.principal)
+			if err != nil {
+				fmt.Println("Error authenticating WebSocket: ", err)
+				continue
+			}
+			if !ok {
+				ws.closeWith(websocket.ClosePolicyViolation, "Credentials no longer valid")
+				return
+			}
+		case <-ws.sub.Done():
+			if ws.sub.Overflowed() {
+				ws.closeWith(websocket.CloseTryAgainLater, "Too slow to keep up")
+			}
+			return
+		case m := <-ws.sub.C():
+			ws.deliver(m)
+		}
+	}
+}
+
+// deliver sends a hub message as an event on each channel subscribed to its
+// topic, if the client may see what it refers to.
+func (ws *wsConn) deliver(m realtime.Message) {
+	ws.mu.Lock()
+	channels := make([]string, 0, len(ws.topicChannels[m.Topic]))
+	for channel := range ws.topicChannels[m.Topic] {
+		channels = append(channels, channel)
+	}
+	ws.mu.Unlock()
+	if len(channels) == 0 {
+		return
+	}
+
+	var event string
+	var data any
+	if m.Topic == notificationsTopic(ws.principal.UserID) {
+		var e notificationEvent
+		if err := json.Unmarshal(m.Payload, &e); err != nil {
+			return
+		}
+		row, err := ws.c.Db.GetNotification(ws.r.Context(), database.GetNotificationParams{ID: e.ID, UserID: ws.principal.UserID})
+		if err != nil {
+			fmt.Println("Error getting notification: ", err)
+			return
+		}
+		notifications := []Notification{mapNotificationStruct(row.Notification, row.ActorCount)}
+		if err := setNotificationDetails(ws.c, ws.r, ws.principal.UserID, notifications); err != nil {
+			fmt.Println("Error getting notification details: ", err)
+			return
+		}
+		event, data = "notification", notifications[0]
+	} else {
+		var e chirpEvent
+		if err := json.Unmarshal(m.Payload, &e); err != nil {
+			return
+		}
+		chirp, visible, err := visibleChirp(ws.c, ws.r, ws.principal.UserID, e.ID)
+		if err != nil {
+			fmt.Println("Error getting chirp: ", err)
+			return
+		}
+		if !visible {
+			return
+		}
+		event, data = "chirp", renderChirp(ws.c, ws.r, ws.principal.UserID, chirp)
+	}
+	for _, channel := range channels {
+		ws.queue(wsServerMessage{Type: "event", Channel: channel, Event: event, Data: data})
+	}
+}
+
+// topicsFor resolves a channel name to hub topics, or to a message saying
+// why the client cannot subscribe to it.
+func (ws *wsConn) topicsFor(channel string) ([]string, string) {
+	kind, arg, _ := strings.Cut(channel, ":")
+	scope := auth.ScopeChirpsRead
+	if kind == "notifications" {
+		scope = auth.ScopeAccount
+	}
+	if !ws.principal.HasScope(scope) {
+		return nil, "Credentials do not grant the " + scope + " scope"
+	}
+
+	switch kind {
+	case "timeline":
+		authors, err := ws.c.Db.ListFolloweeIDs(ws.r.Context(), ws.principal.UserID)
+		if err != nil {
+			fmt.Println("Error listing followees: ", err)
+			return nil, "Error subscribing"
+		}
+		topics := []string{authorTopic(ws.principal.UserID)}
+		for _, author := range authors {
+			topics = append(topics, authorTopic(author))
+		}
+		return topics, ""
+	case "notifications":
+		return []string{notificationsTopic(ws.principal.UserID)}, ""
+	case "hashtag":
+		tag := entities.NormalizeTag(arg)
+		if tag == "" {
+			return nil, "Hashtag not provided"
+		}
+		return []string{hashtagTopic(tag)}, ""
+	case "thread":
+		id, err := uuid.Parse(arg)
+		if err != nil {
+			return nil, "Invalid chirp id"
+		}
+		chirp, visible, err := visibleChirp(ws.c, ws.r, ws.principal.UserID, id)
+		if err != nil {
+			fmt.Println("Error getting chirp: ", err)
+			return nil, "Error subscribing"
+		}
+		if !visible {
+			return nil, "No Chirp with that id"
+		}
+		return []string{threadTopic(chirp.ConversationID)}, ""
+	}
+	return nil, "Unknown channel"
+}
+
+// subscribe starts sending events on a channel. Subscribing twice is not an
+// error. The timeline covers the users followed when it was subscribed to.
+func (ws *wsConn) subscribe(msg wsClientMessage) {
+	ws.mu.Lock()
+	_, subscribed := ws.channels[msg.Channel]
+	full := len(ws.channels) >= wsMaxChannels
+	ws.mu.Unlock()
+	if subscribed {
+		ws.queue(wsServerMessage{Type: "subscribed", ID: msg.ID, Channel: msg.Channel})
+		return
+	}
+	if full {
+		ws.queue(wsServerMessage{Type: "error", ID: msg.ID, Channel: msg.Channel, Error: "Too many subscriptions"})
+		return
+	}
+	topics, problem := ws.topicsFor(msg.Channel)
+	if problem != "" {
+		ws.queue(wsServerMessage{Type: "error", ID: msg.ID, Channel: msg.Channel, Error: problem})
+		return
+	}
+
+	ws.mu.Lock()
+	ws.channels[msg.Channel] = topics
+	for _, topic := range topics {
+		if ws.topicChannels[topic] == nil {
+			ws.topicChannels[topic] = map[string]bool{}
+		}
+		ws.topicChannels[topic][msg.Channel] = true
+	}
+	ws.mu.Unlock()
+	ws.sub.Add(topics...)
+	ws.queue(wsServerMessage{Type: "subscribed", ID: msg.ID, Channel: msg.Channel})
+}
+
+func (ws *wsConn) unsubscribe(msg wsClientMessage) {
+	ws.mu.Lock()
+	topics, ok := ws.channels[msg.Channel]
+	delete(ws.channels, msg.Channel)
+	unused := []string{}
+	for _, topic := range topics {
+		delete(ws.topicChannels[topic], msg.Channel)
+		if len(ws.topicChannels[topic]) == 0 {
+			delete(ws.topicChannels, topic)
+			unused = append(unused, topic)
+		}
+	}
+	ws.mu.Unlock()
+	if !ok {
+		ws.queue(wsServerMessage{Type: "error", ID: msg.ID, Channel: msg.Channel, Error: "Not subscribed to that channel"})
+		return
+	}
+	ws.sub.Remove(unused...)
+	ws.queue(wsServerMessage{Type: "unsubscribed", ID: msg.ID, Channel: msg.Channel})
+}